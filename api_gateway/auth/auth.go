@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Уровни аутентификации маршрутов
+const (
+	// LevelNone - токен не проверяется
+	LevelNone = "none"
+	// LevelOptional - токен проверяется, если передан
+	LevelOptional = "optional"
+	// LevelUser - нужен действительный токен
+	LevelUser = "user"
+	// LevelModerator - нужна роль moderator или admin
+	LevelModerator = "moderator"
+	// LevelAdmin - нужна роль admin
+	LevelAdmin = "admin"
+)
+
+// Заголовки, в которых шлюз передает сервисам данные пользователя.
+// Значения, пришедшие от клиента, всегда удаляются
+const (
+	HeaderUserID   = "X-User-ID"
+	HeaderUserName = "X-User-Name"
+	HeaderUserRole = "X-User-Role"
+)
+
+// issuer - ожидаемое значение iss, его выставляет сервис пользователей
+const issuer = "news_aggregator"
+
+// Identity - аутентифицированный пользователь
+type Identity struct {
+	UserID   int
+	Username string
+	Role     string
+}
+
+// Claims - содержимое access-токена сервиса пользователей
+type Claims struct {
+	Username string `json:"name"`
+	Role     string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+type contextKey struct{}
+
+// FromContext возвращает пользователя, если запрос аутентифицирован
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(contextKey{}).(*Identity)
+	return id, ok
+}
+
+// SetHeaders передает данные пользователя в заголовках запроса к сервису
+func SetHeaders(h http.Header, id *Identity) {
+	h.Set(HeaderUserID, strconv.Itoa(id.UserID))
+	h.Set(HeaderUserName, id.Username)
+	h.Set(HeaderUserRole, id.Role)
+}
+
+// Verifier проверяет access-токены (JWT HS256)
+type Verifier struct {
+	secret []byte
+}
+
+// NewVerifier создает проверяющего с общим с сервисом пользователей секретом
+func NewVerifier(secret string) *Verifier {
+	return &Verifier{secret: []byte(secret)}
+}
+
+// Verify проверяет подпись, срок действия и издателя токена
+func (v *Verifier) Verify(token string) (*Identity, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims,
+		func(*jwt.Token) (interface{}, error) { return v.secret, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("неверный sub в токене: %q", claims.Subject)
+	}
+	return &Identity{UserID: userID, Username: claims.Username, Role: claims.Role}, nil
+}
+
+// Middleware оборачивает обработчик проверкой аутентификации уровня level
+func (v *Verifier) Middleware(level string, next http.Handler) (http.Handler, error) {
+	switch level {
+	case LevelNone, LevelOptional, LevelUser, LevelModerator, LevelAdmin:
+	default:
+		return nil, fmt.Errorf("неизвестный уровень аутентификации %q", level)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Данным о пользователе от клиента доверять нельзя
+		r.Header.Del(HeaderUserID)
+		r.Header.Del(HeaderUserName)
+		r.Header.Del(HeaderUserRole)
+
+		if level == LevelNone {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := bearerToken(r)
+		if err != nil {
+			if level == LevelOptional && errors.Is(err, errNoToken) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			unauthorized(w, "Требуется аутентификация")
+			return
+		}
+
+		id, err := v.Verify(token)
+		if err != nil {
+			unauthorized(w, "Недействительный токен")
+			return
+		}
+		if !hasRole(id.Role, level) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			http.Error(w, "Недостаточно прав", http.StatusForbidden)
+			return
+		}
+
+		// Токен сервисам не нужен: они получают проверенные данные в заголовках
+		r.Header.Del("Authorization")
+		SetHeaders(r.Header, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, id)))
+	}), nil
+}
+
+var errNoToken = errors.New("токен не передан")
+
+func bearerToken(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", errNoToken
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", errors.New("ожидается заголовок Authorization: Bearer <token>")
+	}
+	return strings.TrimSpace(token), nil
+}
+
+// hasRole проверяет, достаточно ли роли для уровня маршрута
+func hasRole(role, level string) bool {
+	switch level {
+	case LevelModerator:
+		return role == LevelModerator || role == LevelAdmin
+	case LevelAdmin:
+		return role == LevelAdmin
+	default:
+		return true
+	}
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="news_aggregator"`)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.Error(w, msg, http.StatusUnauthorized)
+}
@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry - сохраненный ответ
+type Entry struct {
+	Status   int
+	Header   http.Header
+	Body     []byte
+	ETag     string
+	StoredAt time.Time
+	// TTL - время, в течение которого ответ считается свежим
+	TTL time.Duration
+	// Stale - сколько еще после TTL ответ можно отдавать, обновляя его в фоне
+	Stale time.Duration
+
+	key  string
+	path string
+	tags []string
+}
+
+// Age возвращает возраст ответа
+func (e *Entry) Age(now time.Time) time.Duration {
+	return now.Sub(e.StoredAt)
+}
+
+// Fresh сообщает, не истек ли TTL ответа
+func (e *Entry) Fresh(now time.Time) bool {
+	return e.Age(now) <= e.TTL
+}
+
+// Usable сообщает, можно ли еще отдавать ответ (свежий или в окне stale-while-revalidate)
+func (e *Entry) Usable(now time.Time) bool {
+	return e.Age(now) <= e.TTL+e.Stale
+}
+
+// Cache - LRU-кэш ответов с ограничением по количеству записей
+type Cache struct {
+	mu         sync.Mutex
+	capacity   int
+	ll         *list.List
+	items      map[string]*list.Element
+	generation uint64
+	refreshing map[string]bool
+}
+
+// New создает кэш на capacity записей
+func New(capacity int) *Cache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Cache{
+		capacity:   capacity,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		refreshing: make(map[string]bool),
+	}
+}
+
+// Key строит ключ кэша из пути и нормализованной строки запроса:
+// параметры и их значения сортируются, пустые параметры отбрасываются
+func Key(u *url.URL) string {
+	query := u.Query()
+	normalized := make(url.Values, len(query))
+	for k, vs := range query {
+		var kept []string
+		for _, v := range vs {
+			if v != "" {
+				kept = append(kept, v)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		sort.Strings(kept)
+		normalized[k] = kept
+	}
+	if len(normalized) == 0 {
+		return u.Path
+	}
+	return u.Path + "?" + normalized.Encode()
+}
+
+// Get возвращает запись, если ее еще можно использовать
+func (c *Cache) Get(key string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*Entry)
+	if !e.Usable(time.Now()) {
+		c.remove(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return e, true
+}
+
+// Generation возвращает счетчик инвалидаций. Его нужно запомнить до обращения
+// к сервису и передать в Set, чтобы не сохранить ответ, устаревший из-за инвалидации
+func (c *Cache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Set сохраняет запись, если с момента generation не было инвалидаций
+func (c *Cache) Set(key, path string, tags []string, e *Entry, generation uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return false
+	}
+
+	e.key = key
+	e.path = path
+	e.tags = tags
+	if el, ok := c.items[key]; ok {
+		el.Value = e
+		c.ll.MoveToFront(el)
+		return true
+	}
+	c.items[key] = c.ll.PushFront(e)
+	for c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back())
+	}
+	return true
+}
+
+// PurgeTag удаляет все записи с тегом tag и возвращает их количество
+func (c *Cache) PurgeTag(tag string) int {
+	return c.purge(func(e *Entry) bool {
+		for _, t := range e.tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// PurgePath удаляет все записи для пути path независимо от параметров запроса
+func (c *Cache) PurgePath(path string) int {
+	return c.purge(func(e *Entry) bool {
+		return e.path == path
+	})
+}
+
+// Len возвращает количество записей
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *Cache) purge(match func(*Entry) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	removed := 0
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		if match(el.Value.(*Entry)) {
+			c.remove(el)
+			removed++
+		}
+		el = next
+	}
+	return removed
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*Entry).key)
+}
+
+// startRefresh отмечает, что запись обновляется в фоне.
+// Возвращает false, если обновление уже идет
+func (c *Cache) startRefresh(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.refreshing[key] {
+		return false
+	}
+	c.refreshing[key] = true
+	return true
+}
+
+func (c *Cache) finishRefresh(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.refreshing, key)
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "/api/news", want: "/api/news"},
+		{url: "/api/news?", want: "/api/news"},
+		{url: "/api/news?page=2&limit=10", want: "/api/news?limit=10&page=2"},
+		{url: "/api/news?limit=10&page=2", want: "/api/news?limit=10&page=2"},
+		{url: "/api/news?search=&page=1", want: "/api/news?page=1"},
+		{url: "/api/news?tag=b&tag=a", want: "/api/news?tag=a&tag=b"},
+		{url: "/api/news?q=%D0%BC%D0%B8%D1%80", want: "/api/news?q=%D0%BC%D0%B8%D1%80"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Key(u); got != tt.want {
+			t.Errorf("Key(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestEntryFreshness(t *testing.T) {
+	now := time.Now()
+	e := &Entry{StoredAt: now, TTL: 10 * time.Second, Stale: 20 * time.Second}
+	tests := []struct {
+		age           time.Duration
+		fresh, usable bool
+	}{
+		{age: 0, fresh: true, usable: true},
+		{age: 10 * time.Second, fresh: true, usable: true},
+		{age: 11 * time.Second, fresh: false, usable: true},
+		{age: 30 * time.Second, fresh: false, usable: true},
+		{age: 31 * time.Second, fresh: false, usable: false},
+	}
+	for _, tt := range tests {
+		at := now.Add(tt.age)
+		if got := e.Fresh(at); got != tt.fresh {
+			t.Errorf("возраст %s: Fresh = %v, want %v", tt.age, got, tt.fresh)
+		}
+		if got := e.Usable(at); got != tt.usable {
+			t.Errorf("возраст %s: Usable = %v, want %v", tt.age, got, tt.usable)
+		}
+	}
+}
+
+func TestCacheLRU(t *testing.T) {
+	c := New(2)
+	set := func(key string) {
+		c.Set(key, key, nil, &Entry{StoredAt: time.Now(), TTL: time.Minute}, c.Generation())
+	}
+	set("/a")
+	set("/b")
+	c.Get("/a") // /b становится самой старой записью
+	set("/c")
+
+	for key, want := range map[string]bool{"/a": true, "/b": false, "/c": true} {
+		if _, ok := c.Get(key); ok != want {
+			t.Errorf("Get(%q) = %v, want %v", key, ok, want)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+}
+
+func TestCachePurge(t *testing.T) {
+	c := New(10)
+	add := func(key, path string, tags ...string) {
+		c.Set(key, path, tags, &Entry{StoredAt: time.Now(), TTL: time.Minute}, c.Generation())
+	}
+	add("/api/news?page=1", "/api/news", "news")
+	add("/api/news?page=2", "/api/news", "news")
+	add("/api/news/1", "/api/news/1", "news")
+	add("/api/comments/counts", "/api/comments/counts")
+
+	if n := c.PurgePath("/api/news"); n != 2 {
+		t.Errorf("PurgePath удалил %d, want 2", n)
+	}
+	if n := c.PurgeTag("news"); n != 1 {
+		t.Errorf("PurgeTag удалил %d, want 1", n)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len = %d, want 1", c.Len())
+	}
+}
+
+func TestCacheSetAfterPurge(t *testing.T) {
+	c := New(10)
+	generation := c.Generation()
+	c.PurgeTag("news")
+	// Ответ получен до инвалидации и не должен попасть в кэш
+	if c.Set("/api/news", "/api/news", nil, &Entry{StoredAt: time.Now(), TTL: time.Minute}, generation) {
+		t.Fatal("Set сохранил ответ, полученный до инвалидации")
+	}
+	if _, ok := c.Get("/api/news"); ok {
+		t.Fatal("устаревший ответ в кэше")
+	}
+}
@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"api_gateway/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// revalidateTimeout ограничивает фоновое обновление записи
+const revalidateTimeout = 10 * time.Second
+
+// Policy - правила кэширования для маршрута
+type Policy struct {
+	TTL   time.Duration
+	Stale time.Duration
+	Tags  []string
+}
+
+// Handler оборачивает обработчик кэшированием GET/HEAD-запросов.
+// lookups (с меткой result: hit, stale, miss, bypass) может быть nil
+func (c *Cache) Handler(p Policy, lookups *prometheus.CounterVec, next http.Handler) http.Handler {
+	count := func(result string) {
+		if lookups != nil {
+			lookups.WithLabelValues(result).Inc()
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := Key(r.URL)
+		noCache := hasDirective(r.Header.Get("Cache-Control"), "no-cache")
+		if !noCache {
+			if e, ok := c.Get(key); ok {
+				if e.Fresh(time.Now()) {
+					count("hit")
+					serve(w, r, e, "HIT")
+					return
+				}
+				count("stale")
+				serve(w, r, e, "STALE")
+				c.revalidate(key, r, p, next)
+				return
+			}
+			count("miss")
+		} else {
+			count("bypass")
+		}
+
+		generation := c.Generation()
+		rec := newRecorder()
+		next.ServeHTTP(rec, r)
+
+		if r.Method == http.MethodGet {
+			if e := newEntry(rec, p); e != nil {
+				c.Set(key, r.URL.Path, p.Tags, e, generation)
+				serve(w, r, e, "MISS")
+				return
+			}
+		}
+		rec.writeTo(w)
+	})
+}
+
+// revalidate обновляет запись в фоне, не задерживая ответ клиенту
+func (c *Cache) revalidate(key string, r *http.Request, p Policy, next http.Handler) {
+	if !c.startRefresh(key) {
+		return
+	}
+
+	// Фоновое обновление переживает исходный запрос, но сохраняет его ID для логов
+	ctx := middleware.ContextWithRequestID(context.Background(), middleware.RequestIDFromContext(r.Context()))
+	ctx, cancel := context.WithTimeout(ctx, revalidateTimeout)
+	req := r.Clone(ctx)
+	req.Method = http.MethodGet
+	req.Header.Del("If-None-Match")
+	generation := c.Generation()
+
+	go func() {
+		defer cancel()
+		defer c.finishRefresh(key)
+
+		rec := newRecorder()
+		next.ServeHTTP(rec, req)
+		if e := newEntry(rec, p); e != nil {
+			c.Set(key, req.URL.Path, p.Tags, e, generation)
+		} else {
+			middleware.LoggerFromContext(ctx).WithFields(logrus.Fields{
+				"key":    key,
+				"status": rec.status,
+			}).Warn("Не удалось обновить запись кэша")
+		}
+	}()
+}
+
+// newEntry создает запись из ответа, если его можно кэшировать
+func newEntry(rec *recorder, p Policy) *Entry {
+	if rec.status != http.StatusOK {
+		return nil
+	}
+	cc := rec.header.Get("Cache-Control")
+	if hasDirective(cc, "no-store") || hasDirective(cc, "private") || rec.header.Get("Set-Cookie") != "" {
+		return nil
+	}
+
+	sum := sha256.Sum256(rec.body.Bytes())
+	header := rec.header.Clone()
+	header.Del("Content-Length")
+	header.Del("Date")
+	return &Entry{
+		Status:   rec.status,
+		Header:   header,
+		Body:     rec.body.Bytes(),
+		ETag:     `"` + hex.EncodeToString(sum[:16]) + `"`,
+		StoredAt: time.Now(),
+		TTL:      p.TTL,
+		Stale:    p.Stale,
+	}
+}
+
+// serve отдает запись клиенту с учетом If-None-Match
+func serve(w http.ResponseWriter, r *http.Request, e *Entry, result string) {
+	h := w.Header()
+	for k, v := range e.Header {
+		h[k] = v
+	}
+	age := e.Age(time.Now())
+	h.Set("ETag", e.ETag)
+	h.Set("Age", fmt.Sprintf("%d", int(age.Seconds())))
+	h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d, stale-while-revalidate=%d",
+		int(e.TTL.Seconds()), int(e.Stale.Seconds())))
+	h.Set("X-Cache", result)
+
+	if etagMatches(r.Header.Get("If-None-Match"), e.ETag) {
+		h.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Length", fmt.Sprintf("%d", len(e.Body)))
+	w.WriteHeader(e.Status)
+	if r.Method != http.MethodHead {
+		w.Write(e.Body)
+	}
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDirective(cacheControl, directive string) bool {
+	for _, d := range strings.Split(cacheControl, ",") {
+		if strings.EqualFold(strings.TrimSpace(d), directive) {
+			return true
+		}
+	}
+	return false
+}
+
+// recorder буферизует ответ обработчика
+type recorder struct {
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: make(http.Header), status: http.StatusOK}
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.status = code
+	rec.wroteHeader = true
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.body.Write(b)
+}
+
+// writeTo передает некэшируемый ответ клиенту без изменений
+func (rec *recorder) writeTo(w http.ResponseWriter) {
+	for k, v := range rec.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rec.status)
+	w.Write(rec.body.Bytes())
+}
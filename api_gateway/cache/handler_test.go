@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHandler отвечает телом "v<номер вызова>"
+func countingHandler(calls *atomic.Int32, header http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "v%d", n)
+	})
+}
+
+func get(h http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandlerHitAndMiss(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   http.Header // заголовки ответа сервиса
+		second   http.Header // заголовки второго запроса
+		want     string      // X-Cache второго ответа
+		wantBody string
+	}{
+		{name: "повтор из кэша", want: "HIT", wantBody: "v1"},
+		{name: "no-cache клиента обходит кэш", second: http.Header{"Cache-Control": {"no-cache"}}, want: "MISS", wantBody: "v2"},
+		{name: "no-store сервиса не кэшируется", header: http.Header{"Cache-Control": {"no-store"}}, want: "", wantBody: "v2"},
+		{name: "private не кэшируется", header: http.Header{"Cache-Control": {"private"}}, want: "", wantBody: "v2"},
+		{name: "Set-Cookie не кэшируется", header: http.Header{"Set-Cookie": {"a=b"}}, want: "", wantBody: "v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			h := New(10).Handler(Policy{TTL: time.Minute}, nil, countingHandler(&calls, tt.header))
+
+			first := get(h, "/api/news?page=1", nil)
+			if first.Code != http.StatusOK || first.Body.String() != "v1" {
+				t.Fatalf("первый ответ: %d %q", first.Code, first.Body.String())
+			}
+			second := get(h, "/api/news?page=1", tt.second)
+			if got := second.Header().Get("X-Cache"); got != tt.want {
+				t.Errorf("X-Cache = %q, want %q", got, tt.want)
+			}
+			if second.Body.String() != tt.wantBody {
+				t.Errorf("тело = %q, want %q", second.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandlerETag(t *testing.T) {
+	var calls atomic.Int32
+	h := New(10).Handler(Policy{TTL: time.Minute}, nil, countingHandler(&calls, nil))
+
+	first := get(h, "/api/news", nil)
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("нет ETag")
+	}
+
+	tests := []struct {
+		ifNoneMatch string
+		want        int
+	}{
+		{ifNoneMatch: etag, want: http.StatusNotModified},
+		{ifNoneMatch: "W/" + etag, want: http.StatusNotModified},
+		{ifNoneMatch: `"other", ` + etag, want: http.StatusNotModified},
+		{ifNoneMatch: "*", want: http.StatusNotModified},
+		{ifNoneMatch: `"other"`, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := get(h, "/api/news", http.Header{"If-None-Match": {tt.ifNoneMatch}})
+		if rec.Code != tt.want {
+			t.Errorf("If-None-Match %s: статус %d, want %d", tt.ifNoneMatch, rec.Code, tt.want)
+		}
+		if tt.want == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: у 304 есть тело", tt.ifNoneMatch)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("обращений к сервису %d, want 1", n)
+	}
+}
+
+func TestHandlerStaleWhileRevalidate(t *testing.T) {
+	var calls atomic.Int32
+	c := New(10)
+	// Нулевой TTL: каждая запись сразу устаревает, но остается пригодной минуту
+	h := c.Handler(Policy{TTL: 0, Stale: time.Minute}, nil, countingHandler(&calls, nil))
+
+	if rec := get(h, "/api/news", nil); rec.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("первый ответ X-Cache = %q", rec.Header().Get("X-Cache"))
+	}
+	time.Sleep(time.Millisecond)
+
+	// Устаревший ответ отдается сразу, обновление идет в фоне
+	rec := get(h, "/api/news", nil)
+	if rec.Header().Get("X-Cache") != "STALE" || rec.Body.String() != "v1" {
+		t.Fatalf("второй ответ: X-Cache = %q, тело %q", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if e, ok := c.Get("/api/news"); ok && string(e.Body) == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("запись не обновилась в фоне")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if rec := get(h, "/api/news", nil); rec.Body.String() != "v2" {
+		t.Errorf("после обновления тело %q, want v2", rec.Body.String())
+	}
+}
+
+func TestHandlerNonGet(t *testing.T) {
+	var calls atomic.Int32
+	h := New(10).Handler(Policy{TTL: time.Minute}, nil, countingHandler(&calls, nil))
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/news", nil))
+		if rec.Header().Get("X-Cache") != "" {
+			t.Errorf("POST прошел через кэш: X-Cache = %q", rec.Header().Get("X-Cache"))
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("обращений к сервису %d, want 2", n)
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api_gateway/auth"
+	"api_gateway/middleware"
+)
+
+const (
+	// moderationStatusHeader - статус, с которым сервис комментариев сохранит комментарий
+	moderationStatusHeader = "X-Moderation-Status"
+	moderationPending      = "pending"
+	// commentApproved - статус опубликованного комментария в ответе сервиса комментариев
+	commentApproved = "approved"
+)
+
+// Тело запроса добавления и редактирования комментария
+type CommentInput struct {
+	NewsID   int    `json:"news_id,omitempty"`
+	ParentID *int   `json:"parent_id,omitempty"`
+	Content  string `json:"content"`
+	// Text - прежнее название поля content, принимается для совместимости
+	Text string `json:"text,omitempty"`
+}
+
+// readCommentInput разбирает тело запроса с комментарием
+func readCommentInput(r *http.Request) (*CommentInput, error) {
+	defer r.Body.Close()
+	var input CommentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return nil, err
+	}
+	if input.Content == "" {
+		input.Content = input.Text
+	}
+	input.Text = ""
+	return &input, nil
+}
+
+// Обработчик добавления новых комментариев
+func handleAddComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	input, err := readCommentInput(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+		return
+	}
+
+	// Проверяем комментарий через сервис цензуры
+	text := input.Content
+	status, content, ok := censorComment(w, r, text)
+	if !ok {
+		return
+	}
+	input.Content = content
+
+	// Если комментарий прошел цензуру, создаем его
+	body, _ := json.Marshal(input)
+	code, respBody := forwardComment(w, r, http.MethodPost, "/api/comments", body, status, "Ошибка создания комментария")
+
+	// Повтором считается только опубликованный текст: отклоненный сервисом
+	// комментариев или ушедший на модерацию не запоминается
+	var created struct {
+		Status string `json:"status"`
+	}
+	if code == http.StatusCreated && json.Unmarshal(respBody, &created) == nil && created.Status == commentApproved {
+		recordComment(r, text)
+	}
+}
+
+// Обработчик редактирования комментария: PATCH /api/comments/{id}.
+// Новый текст снова проходит цензуру
+func handleEditComment(w http.ResponseWriter, r *http.Request) {
+	commentID, ok := commentIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	input, err := readCommentInput(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+		return
+	}
+
+	status, content, ok := censorComment(w, r, input.Content)
+	if !ok {
+		return
+	}
+
+	body, _ := json.Marshal(map[string]string{"content": content})
+	forwardComment(w, r, http.MethodPatch, "/api/comments/"+commentID, body, status, "Ошибка редактирования комментария")
+}
+
+// Обработчик удаления комментария: DELETE /api/comments/{id}
+func handleDeleteComment(w http.ResponseWriter, r *http.Request) {
+	commentID, ok := commentIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	forwardComment(w, r, http.MethodDelete, "/api/comments/"+commentID, nil, "", "Ошибка удаления комментария")
+}
+
+// Обработчик жалобы на комментарий: POST /api/comments/{id}/report.
+// Комментарий, скрытый по жалобам, сразу меняется на странице новости
+func handleReportComment(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 5 || parts[4] != "report" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := strconv.Atoi(parts[3]); err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Неверный ID комментария", http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Ошибка чтения тела запроса", http.StatusBadRequest)
+		return
+	}
+	forwardComment(w, r, http.MethodPost, r.URL.Path, body, "", "Ошибка отправки жалобы")
+}
+
+// Обработчик голосов и реакций: PUT и DELETE /api/comments/{id}/vote
+// и /api/comments/{id}/reactions/{reaction}. Счетчики сразу меняются на странице новости
+func handleVoteComment(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	vote := len(parts) == 5 && parts[4] == "vote"
+	reaction := len(parts) == 6 && parts[4] == "reactions" && parts[5] != ""
+	if !vote && !reaction {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := strconv.Atoi(parts[3]); err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Неверный ID комментария", http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Ошибка чтения тела запроса", http.StatusBadRequest)
+		return
+	}
+	if len(body) == 0 {
+		body = nil
+	}
+	forwardComment(w, r, r.Method, r.URL.Path, body, "", "Ошибка голосования")
+}
+
+// Обработчик действий модератора: POST /api/moderation/comments/{id}/{action}.
+// Одобренный или скрытый комментарий сразу меняется на странице новости
+func handleModerateComment(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Ошибка чтения тела запроса", http.StatusBadRequest)
+		return
+	}
+	if len(body) == 0 {
+		body = nil
+	}
+	forwardComment(w, r, http.MethodPost, r.URL.Path, body, "", "Ошибка модерации комментария")
+}
+
+func commentIDFromPath(w http.ResponseWriter, r *http.Request) (string, bool) {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 4 {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.NotFound(w, r)
+		return "", false
+	}
+	if _, err := strconv.Atoi(parts[3]); err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Неверный ID комментария", http.StatusBadRequest)
+		return "", false
+	}
+	return parts[3], true
+}
+
+// Статусы вердикта сервиса цензуры
+const (
+	verdictRejected = "rejected"
+	// verdictMasked - комментарий сохраняется с текстом, в котором грубые слова
+	// заменены звездочками
+	verdictMasked = "masked"
+	// verdictReview - сомнительный комментарий, решение принимает политика шлюза
+	verdictReview = "review"
+)
+
+// Политики для подозрительных комментариев (CENSOR_SUSPICIOUS_POLICY)
+const (
+	// policyQueue - опубликовать после модерации
+	policyQueue = "queue"
+	// policyReject - отклонить сразу
+	policyReject = "reject"
+	// policyApprove - опубликовать сразу
+	policyApprove = "approve"
+)
+
+// Ответ сервиса цензуры. Matches - найденные нарушения с позициями в тексте,
+// передаются клиенту без изменений
+type CensorVerdict struct {
+	Status  string          `json:"status"`
+	Score   float64         `json:"score"`
+	Reason  string          `json:"reason,omitempty"`
+	Matches json.RawMessage `json:"matches,omitempty"`
+	// Text - текст с замаскированными словами, сохраняется вместо исходного
+	Text string `json:"text,omitempty"`
+}
+
+// censorComment проверяет текст через сервис цензуры и возвращает статус
+// модерации для сервиса комментариев ("pending" или пустой) и текст для
+// сохранения (исходный или с замаскированными словами). Если текст не прошел
+// проверку, ответ уже записан клиенту и возвращается false
+func censorComment(w http.ResponseWriter, r *http.Request, text string) (string, string, bool) {
+	censorReq, err := censorshipClient.NewRequest(r.Context(), http.MethodPost, "/api/censor", bytes.NewReader(censorBody(r, text)))
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Ошибка проверки комментария", http.StatusInternalServerError)
+		return "", "", false
+	}
+	censorReq.Header.Set("Content-Type", "application/json")
+	censorResp, err := censorshipClient.Do(censorReq)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Ошибка проверки комментария", upstreamErrorStatus(err))
+		return "", "", false
+	}
+	defer censorResp.Body.Close()
+
+	if censorResp.StatusCode != http.StatusOK {
+		// Копируем ответ об ошибке от сервиса цензуры: для отклоненного текста
+		// это вердикт с найденными нарушениями
+		for k, v := range censorResp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(censorResp.StatusCode)
+		io.Copy(w, censorResp.Body)
+		return "", "", false
+	}
+
+	var verdict CensorVerdict
+	if err := json.NewDecoder(censorResp.Body).Decode(&verdict); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка разбора ответа сервиса цензуры")
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Ошибка проверки комментария", http.StatusBadGateway)
+		return "", "", false
+	}
+	if verdict.Text != "" && (verdict.Status == verdictMasked || verdict.Status == verdictReview) {
+		text = verdict.Text
+	}
+	if verdict.Status != verdictReview {
+		return "", text, true
+	}
+
+	switch suspiciousPolicy {
+	case policyApprove:
+		return "", text, true
+	case policyReject:
+		verdict.Status = verdictRejected
+		if verdict.Reason == "" {
+			verdict.Reason = "Комментарий отклонен"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(verdict)
+		return "", "", false
+	default:
+		return moderationPending, text, true
+	}
+}
+
+// censorBody - тело запроса к сервису цензуры: текст и его автор
+func censorBody(r *http.Request, text string) []byte {
+	req := map[string]string{"text": text}
+	if id, ok := auth.FromContext(r.Context()); ok {
+		req["author"] = strconv.Itoa(id.UserID)
+	}
+	body, _ := json.Marshal(req)
+	return body
+}
+
+// recordComment сообщает сервису цензуры, что текст опубликован, чтобы
+// его повторы находились при следующих проверках. Ошибка только логируется:
+// комментарий уже сохранен
+func recordComment(r *http.Request, text string) {
+	logger := middleware.LoggerFromContext(r.Context())
+	req, err := censorshipClient.NewRequest(r.Context(), http.MethodPost, "/api/censor/record", bytes.NewReader(censorBody(r, text)))
+	if err != nil {
+		logger.WithError(err).Warn("Отпечаток комментария не запомнен")
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := censorshipClient.Do(req)
+	if err != nil {
+		logger.WithError(err).Warn("Отпечаток комментария не запомнен")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		logger.WithField("status", resp.StatusCode).Warn("Отпечаток комментария не запомнен")
+	}
+}
+
+// forwardComment передает запрос сервису комментариев от имени пользователя
+// и копирует ответ клиенту. Непустой moderation отправляет комментарий на модерацию. После успешного изменения сбрасывается кэш
+// страницы новости, к которой относится комментарий. Возвращает код и тело
+// ответа сервиса (0, если сервис не ответил)
+func forwardComment(w http.ResponseWriter, r *http.Request, method, path string, body []byte, moderation, errMsg string) (int, []byte) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := commentsClient.NewRequest(r.Context(), method, path, reqBody)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, errMsg, http.StatusInternalServerError)
+		return 0, nil
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	// Автор комментария - пользователь, проверенный на уровне маршрута
+	if id, ok := auth.FromContext(r.Context()); ok {
+		auth.SetHeaders(req.Header, id)
+	}
+	if moderation != "" {
+		req.Header.Set(moderationStatusHeader, moderation)
+	}
+	resp, err := commentsClient.Do(req)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, errMsg, upstreamErrorStatus(err))
+		return 0, nil
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, errMsg, http.StatusBadGateway)
+		return 0, nil
+	}
+
+	// Изменение должно сразу появиться на странице новости
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		var comment struct {
+			NewsID int `json:"news_id"`
+		}
+		if err := json.Unmarshal(respBody, &comment); err == nil && comment.NewsID > 0 {
+			responseCache.PurgePath(fmt.Sprintf("/api/news/%d", comment.NewsID))
+			commentCounts.Forget(comment.NewsID)
+		}
+	}
+
+	// Копируем заголовки и тело ответа
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(respBody)
+	return resp.StatusCode, respBody
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"api_gateway/auth"
+	"api_gateway/cache"
+	"api_gateway/upstream"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeCensorship - сервис цензуры с заданным вердиктом, запоминает запросы /api/censor/record
+type fakeCensorship struct {
+	mu      sync.Mutex
+	verdict string
+	records []map[string]string
+}
+
+func (f *fakeCensorship) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req map[string]string
+	json.NewDecoder(r.Body).Decode(&req)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/api/censor":
+		if r.URL.RawQuery != "" {
+			http.Error(w, "неожиданные параметры", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CensorVerdict{Status: f.verdict})
+	case "/api/censor/record":
+		f.records = append(f.records, req)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fakeComments отвечает на создание комментариев кодами из responses по очереди
+type fakeComments struct {
+	mu        sync.Mutex
+	responses []int
+	calls     int
+}
+
+func (f *fakeComments) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	code := f.responses[f.calls]
+	f.calls++
+	if code != http.StatusCreated {
+		http.Error(w, "Новость не найдена", code)
+		return
+	}
+	status := "approved"
+	if r.Header.Get(moderationStatusHeader) != "" {
+		status = r.Header.Get(moderationStatusHeader)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"id": f.calls, "news_id": 1, "status": status})
+}
+
+func testUpstream(name, url string) *upstream.Client {
+	return upstream.New(upstream.Config{
+		Name:             name,
+		BaseURL:          url,
+		ConnectTimeout:   time.Second,
+		ReadTimeout:      time.Second,
+		RequestTimeout:   time.Second,
+		RetryBackoff:     time.Millisecond,
+		FailureThreshold: 100,
+		OpenTimeout:      time.Minute,
+	}, upstream.Metrics{})
+}
+
+// setupComments подменяет сервисы цензуры и комментариев тестовыми
+func setupComments(t *testing.T, censorship *fakeCensorship, comments *fakeComments, policy string) http.Handler {
+	t.Helper()
+	censorshipSrv := httptest.NewServer(censorship)
+	commentsSrv := httptest.NewServer(comments)
+	t.Cleanup(censorshipSrv.Close)
+	t.Cleanup(commentsSrv.Close)
+
+	oldCensorship, oldComments, oldPolicy := censorshipClient, commentsClient, suspiciousPolicy
+	oldCache, oldCounts := responseCache, commentCounts
+	censorshipClient = testUpstream("censorship_service", censorshipSrv.URL)
+	commentsClient = testUpstream("comments_service", commentsSrv.URL)
+	suspiciousPolicy = policy
+	responseCache = cache.New(10)
+	commentCounts = newCountsCache(time.Minute)
+	t.Cleanup(func() {
+		censorshipClient, commentsClient, suspiciousPolicy = oldCensorship, oldComments, oldPolicy
+		responseCache, commentCounts = oldCache, oldCounts
+	})
+
+	h, err := auth.NewVerifier("secret").Middleware(auth.LevelUser, http.HandlerFunc(handleAddComment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func userToken(t *testing.T, userID string) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		Username: "alice",
+		Role:     "user",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Issuer:    "news_aggregator",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAddCommentRecordsPublishedOnly(t *testing.T) {
+	const text = "купите наши лучшие окна со скидкой сегодня"
+	tests := []struct {
+		name      string
+		verdict   string
+		policy    string
+		responses []int // ответы сервиса комментариев на попытки подряд
+		want      []int // коды ответов клиенту
+		records   int   // запомненных отпечатков
+	}{
+		{
+			name:      "404, затем повтор",
+			verdict:   "approved",
+			policy:    policyQueue,
+			responses: []int{http.StatusNotFound, http.StatusCreated},
+			want:      []int{http.StatusNotFound, http.StatusCreated},
+			records:   1,
+		},
+		{
+			name:      "400 от сервиса комментариев",
+			verdict:   "approved",
+			policy:    policyQueue,
+			responses: []int{http.StatusBadRequest, http.StatusBadRequest},
+			want:      []int{http.StatusBadRequest, http.StatusBadRequest},
+		},
+		{
+			name:    "сомнительный отклонен политикой",
+			verdict: verdictReview,
+			policy:  policyReject,
+			want:    []int{http.StatusBadRequest, http.StatusBadRequest},
+		},
+		{
+			name:      "сомнительный на модерации",
+			verdict:   verdictReview,
+			policy:    policyQueue,
+			responses: []int{http.StatusCreated},
+			want:      []int{http.StatusCreated},
+		},
+		{
+			name:      "замаскированный опубликован",
+			verdict:   verdictMasked,
+			policy:    policyQueue,
+			responses: []int{http.StatusCreated},
+			want:      []int{http.StatusCreated},
+			records:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			censorship := &fakeCensorship{verdict: tt.verdict}
+			comments := &fakeComments{responses: tt.responses}
+			h := setupComments(t, censorship, comments, tt.policy)
+			token := userToken(t, "42")
+
+			for i, want := range tt.want {
+				body := `{"news_id": 1, "content": "` + text + `"}`
+				req := httptest.NewRequest(http.MethodPost, "/api/comments", strings.NewReader(body))
+				req.Header.Set("Authorization", "Bearer "+token)
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				if rec.Code != want {
+					t.Fatalf("попытка %d: статус %d, want %d", i, rec.Code, want)
+				}
+			}
+			if len(censorship.records) != tt.records {
+				t.Fatalf("запомнено отпечатков %d, want %d", len(censorship.records), tt.records)
+			}
+			for _, rec := range censorship.records {
+				if rec["text"] != text || rec["author"] != "42" {
+					t.Errorf("запомнен %v, want исходный текст автора 42", rec)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCountsEntries - при большем числе записей кэш счетчиков очищается от устаревших
+const maxCountsEntries = 10000
+
+// countsCache - кэш числа комментариев новостей для списка новостей.
+// Разные страницы и поисковые запросы списка делят одни и те же счетчики,
+// поэтому сервис комментариев опрашивается только о недостающих новостях
+type countsCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[int]countEntry
+}
+
+type countEntry struct {
+	count   int
+	expires time.Time
+}
+
+func newCountsCache(ttl time.Duration) *countsCache {
+	return &countsCache{ttl: ttl, entries: make(map[int]countEntry)}
+}
+
+// Get возвращает число комментариев каждой новости из ids. Отсутствующие
+// в кэше счетчики запрашиваются у сервиса комментариев одним запросом
+func (c *countsCache) Get(ctx context.Context, ids []int) (map[int]int, error) {
+	counts := make(map[int]int, len(ids))
+	var missing []string
+
+	now := time.Now()
+	c.mu.Lock()
+	for _, id := range ids {
+		if e, ok := c.entries[id]; ok && now.Before(e.expires) {
+			counts[id] = e.count
+			continue
+		}
+		missing = append(missing, strconv.Itoa(id))
+	}
+	c.mu.Unlock()
+
+	if len(missing) == 0 {
+		return counts, nil
+	}
+
+	var fetched map[string]int
+	query := url.Values{"news_ids": {strings.Join(missing, ",")}}
+	if _, _, err := fetchJSON(ctx, commentsClient, "/api/comments/counts?"+query.Encode(), commentsTimeout, &fetched); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCountsEntries {
+		for id, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, id)
+			}
+		}
+	}
+	expires := now.Add(c.ttl)
+	for key, n := range fetched {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		counts[id] = n
+		if len(c.entries) < maxCountsEntries {
+			c.entries[id] = countEntry{count: n, expires: expires}
+		}
+	}
+	return counts, nil
+}
+
+// Forget сбрасывает счетчик новости после изменения ее комментариев
+func (c *countsCache) Forget(newsID int) {
+	c.mu.Lock()
+	delete(c.entries, newsID)
+	c.mu.Unlock()
+}
@@ -1,27 +1,50 @@
 package main
 
 import (
-	"bytes"
-	"encoding/hex"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"api_gateway/auth"
+	"api_gateway/cache"
+	"api_gateway/middleware"
+	"api_gateway/ratelimit"
+	"api_gateway/routing"
+	"api_gateway/tracing"
+	"api_gateway/upstream"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
 
 // Краткая информация о новости
 type NewsShortDetailed struct {
-	ID      int    `json:"id"`
-	Title   string `json:"title"`
-	Summary string `json:"summary"`
-	Date    string `json:"date"`
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Date        string `json:"date"`
+	SourceLink  string `json:"source_link"`
+	Source      string `json:"source"`
+	// CommentCount - число комментариев; нет, если сервис комментариев недоступен
+	CommentCount *int `json:"comment_count,omitempty"`
+}
+
+// Страница списка новостей
+type NewsList struct {
+	Items      []NewsShortDetailed `json:"items"`
+	Pagination json.RawMessage     `json:"pagination"`
+	// Degraded выставляется, если не удалось получить число комментариев
+	Degraded bool `json:"degraded,omitempty"`
 }
 
 // Полная информация о новости с комментариями
@@ -32,29 +55,110 @@ type NewsFullDetailed struct {
 	Date     string    `json:"date"`
 	Source   string    `json:"source"`
 	Comments []Comment `json:"comments"`
+	// CommentCount - общее число комментариев новости
+	CommentCount *int `json:"comment_count,omitempty"`
+	// CommentsNextCursor - курсор следующей страницы комментариев (?cursor=...)
+	CommentsNextCursor string `json:"comments_next_cursor,omitempty"`
+	// Degraded выставляется, если часть данных (комментарии) получить не удалось
+	Degraded bool `json:"degraded,omitempty"`
 }
 
 // Комментарий к новости
 type Comment struct {
-	ID        int    `json:"id"`
-	NewsID    int    `json:"news_id"`
-	ParentID  *int   `json:"parent_id,omitempty"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
+	ID         int    `json:"id"`
+	NewsID     int    `json:"news_id"`
+	ParentID   *int   `json:"parent_id,omitempty"`
+	AuthorID   *int   `json:"author_id,omitempty"`
+	AuthorName string `json:"author_name,omitempty"`
+	Content    string `json:"content"`
+	CreatedAt  string `json:"created_at"`
+	ReplyCount int    `json:"reply_count"`
+	Upvotes    int    `json:"upvotes"`
+	Downvotes  int    `json:"downvotes"`
+	// Reactions - число реакций каждого вида
+	Reactions map[string]int `json:"reactions,omitempty"`
+	// Поля дерева комментариев (?format=tree)
+	Depth   int       `json:"depth,omitempty"`
+	Replies []Comment `json:"replies,omitempty"`
 }
 
 var (
 	newsServiceURL       = os.Getenv("NEWS_SERVICE_URL")
 	commentsServiceURL   = os.Getenv("COMMENTS_SERVICE_URL")
 	censorshipServiceURL = os.Getenv("CENSORSHIP_SERVICE_URL")
+	usersServiceURL      = os.Getenv("USERS_SERVICE_URL")
+
+	// Клиенты сервисов
+	newsClient       *upstream.Client
+	commentsClient   *upstream.Client
+	censorshipClient *upstream.Client
+	usersClient      *upstream.Client
+
+	// Кэш ответов шлюза
+	responseCache *cache.Cache
+	// Кэш числа комментариев для списка новостей
+	commentCounts *countsCache
+
+	// Политика для комментариев, которые сервис цензуры счел подозрительными
+	suspiciousPolicy = os.Getenv("CENSOR_SUSPICIOUS_POLICY")
+
+	// Метрики Prometheus
+	upstreamRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_requests_total",
+		Help: "Total number of requests to upstream services by outcome",
+	}, []string{"upstream", "outcome"})
+
+	upstreamRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_retries_total",
+		Help: "Total number of retried requests to upstream services",
+	}, []string{"upstream"})
+
+	upstreamCircuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_upstream_circuit_state",
+		Help: "Circuit breaker state per upstream (0 - closed, 1 - half-open, 2 - open)",
+	}, []string{"upstream"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_cache_lookups_total",
+		Help: "Total number of response cache lookups by result",
+	}, []string{"result"})
+
+	rateLimitThrottled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_ratelimit_throttled_total",
+		Help: "Total number of requests rejected by the rate limiter by scope (key, ip)",
+	}, []string{"scope"})
+)
+
+// commentsPassThrough - параметры детальной страницы новости,
+// которые передаются сервису комментариев
+var commentsPassThrough = []string{"sort", "limit", "cursor", "format", "depth"}
+
+const (
+	// Таймауты обращений к сервисам при сборке детальной страницы новости
+	newsDetailTimeout = 3 * time.Second
+	commentsTimeout   = 2 * time.Second
 )
 
+func init() {
+	// Регистрируем метрики
+	prometheus.MustRegister(upstreamRequests)
+	prometheus.MustRegister(upstreamRetries)
+	prometheus.MustRegister(upstreamCircuitState)
+	prometheus.MustRegister(cacheLookups)
+	prometheus.MustRegister(rateLimitThrottled)
+}
+
 func main() {
 	// Настройка логгера
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 	logrus.SetOutput(os.Stdout)
 	logrus.SetLevel(logrus.InfoLevel)
 
+	// Настройка трассировки
+	if _, err := tracing.Init(context.Background(), "api_gateway"); err != nil {
+		log.Fatalf("Ошибка настройки трассировки: %v", err)
+	}
+
 	if newsServiceURL == "" {
 		newsServiceURL = "http://localhost:8082"
 	}
@@ -64,16 +168,120 @@ func main() {
 	if censorshipServiceURL == "" {
 		censorshipServiceURL = "http://localhost:8083"
 	}
+	if usersServiceURL == "" {
+		usersServiceURL = "http://localhost:8084"
+	}
+
+	// Секрет для проверки access-токенов, общий с сервисом пользователей
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		log.Fatal("Не задан JWT_SECRET")
+	}
+	verifier := auth.NewVerifier(jwtSecret)
+
+	switch suspiciousPolicy {
+	case "":
+		suspiciousPolicy = policyQueue
+	case policyQueue, policyReject, policyApprove:
+	default:
+		log.Fatalf("Неверное значение CENSOR_SUSPICIOUS_POLICY=%q, допустимы queue, reject, approve", suspiciousPolicy)
+	}
 
+	// Создаем клиентов сервисов
+	metrics := upstream.Metrics{
+		Requests: upstreamRequests,
+		Retries:  upstreamRetries,
+		State:    upstreamCircuitState,
+	}
+	newsClient = upstream.New(upstreamConfig("news_service", "NEWS_SERVICE", newsServiceURL), metrics)
+	commentsClient = upstream.New(upstreamConfig("comments_service", "COMMENTS_SERVICE", commentsServiceURL), metrics)
+	censorshipClient = upstream.New(upstreamConfig("censorship_service", "CENSORSHIP_SERVICE", censorshipServiceURL), metrics)
+	usersClient = upstream.New(upstreamConfig("users_service", "USERS_SERVICE", usersServiceURL), metrics)
+
+	// Создаем кэш ответов и следим за циклами загрузки новостей для его сброса
+	responseCache = cache.New(envInt("CACHE_CAPACITY", 1000))
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "gateway_cache_entries",
+		Help: "Number of entries in the response cache",
+	}, func() float64 { return float64(responseCache.Len()) }))
+	commentCounts = newCountsCache(envDuration("COMMENT_COUNTS_CACHE_TTL", 30*time.Second))
+	go watchIngestCycles(envDuration("NEWS_INGEST_POLL_INTERVAL", 15*time.Second))
+
+	// Собственные эндпоинты шлюза
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", handleWelcome)
-	mux.HandleFunc("/api/news", handleNewsList)
-	mux.HandleFunc("/api/news/", handleNewsDetail)
-	mux.HandleFunc("/api/comments", handleAddComment)
+	mux.HandleFunc("/health", handleHealth)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	// Маршруты к сервисам задаются таблицей маршрутов
+	routesFile := os.Getenv("ROUTES_FILE")
+	if routesFile == "" {
+		routesFile = "routes.json"
+	}
+	table, err := routing.Load(routesFile)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки таблицы маршрутов: %v", err)
+	}
+	router, err := routing.NewRouter(table, routing.Options{
+		Upstreams: map[string]*upstream.Client{
+			newsClient.Name():       newsClient,
+			commentsClient.Name():   commentsClient,
+			censorshipClient.Name(): censorshipClient,
+			usersClient.Name():      usersClient,
+		},
+		Handlers: map[string]http.Handler{
+			"news_list":        http.HandlerFunc(handleNewsList),
+			"news_detail":      http.HandlerFunc(handleNewsDetail),
+			"add_comment":      http.HandlerFunc(handleAddComment),
+			"edit_comment":     http.HandlerFunc(handleEditComment),
+			"delete_comment":   http.HandlerFunc(handleDeleteComment),
+			"moderate_comment": http.HandlerFunc(handleModerateComment),
+			"moderate_news":    http.HandlerFunc(handleModerateNews),
+			"report_comment":   http.HandlerFunc(handleReportComment),
+			"vote_comment":     http.HandlerFunc(handleVoteComment),
+		},
+		Auth:         verifier.Middleware,
+		Fallback:     mux,
+		Cache:        responseCache,
+		CacheLookups: cacheLookups,
+	})
+	if err != nil {
+		log.Fatalf("Ошибка построения маршрутов: %v", err)
+	}
+
+	// Ограничение частоты запросов по API-ключу или IP-адресу клиента
+	limitStore := ratelimit.NewMemoryStore()
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "gateway_ratelimit_buckets",
+		Help: "Number of active rate limiter buckets",
+	}, func() float64 { return float64(limitStore.Len()) }))
+	limiter := ratelimit.New(ratelimit.Config{
+		Store: limitStore,
+		Keys:  ratelimit.NewRemoteKeys(usersClient, envDuration("API_KEY_CACHE_TTL", time.Minute)),
+		IPLimit: ratelimit.Limit{
+			Rate:  envFloat("RATE_LIMIT_IP_RPS", 10),
+			Burst: envInt("RATE_LIMIT_IP_BURST", 20),
+		},
+		KeyLimit: ratelimit.Limit{
+			Rate:  envFloat("RATE_LIMIT_KEY_RPS", 50),
+			Burst: envInt("RATE_LIMIT_KEY_BURST", 100),
+		},
+		Exempt:    []string{"/health", "/metrics"},
+		Throttled: rateLimitThrottled,
+	})
 
-	// Подключаем middleware
-	handler := RequestIDMiddleware(mux)
-	handler = LoggingMiddleware(handler)
+	// Подключаем middleware: спан запроса создается первым,
+	// чтобы ID трассировки и запроса попали во все строки лога
+	handler := limiter.Middleware(router)
+	handler = middleware.LoggingMiddleware(handler)
+	handler = middleware.RequestIDMiddleware(handler)
+	fallbackRoute := tracing.MuxRoute(mux)
+	handler = tracing.Middleware(handler, "api_gateway", func(r *http.Request) string {
+		if route := router.Route(r); route != "" {
+			return route
+		}
+		return fallbackRoute(r)
+	})
 
 	log.Println("Запуск API Gateway на порту :8080")
 	if err := http.ListenAndServe(":8080", handler); err != nil {
@@ -81,60 +289,76 @@ func main() {
 	}
 }
 
-// Middleware для добавления ID запроса в контекст
-func RequestIDMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestID := r.Header.Get("X-Request-ID")
-		if requestID == "" {
-			requestID = generateRequestID()
-		}
-		w.Header().Set("X-Request-ID", requestID)
-		next.ServeHTTP(w, r)
-	})
+// upstreamConfig собирает настройки клиента сервиса из переменных окружения
+// с префиксом prefix (например, NEWS_SERVICE_CONNECT_TIMEOUT=2s)
+func upstreamConfig(name, prefix, baseURL string) upstream.Config {
+	return upstream.Config{
+		Name:             name,
+		BaseURL:          baseURL,
+		ConnectTimeout:   envDuration(prefix+"_CONNECT_TIMEOUT", 2*time.Second),
+		ReadTimeout:      envDuration(prefix+"_READ_TIMEOUT", 5*time.Second),
+		RequestTimeout:   envDuration(prefix+"_REQUEST_TIMEOUT", 10*time.Second),
+		MaxRetries:       envInt(prefix+"_MAX_RETRIES", 2),
+		RetryBackoff:     envDuration(prefix+"_RETRY_BACKOFF", 100*time.Millisecond),
+		FailureThreshold: envInt(prefix+"_BREAKER_THRESHOLD", 5),
+		OpenTimeout:      envDuration(prefix+"_BREAKER_COOLDOWN", 30*time.Second),
+	}
 }
 
-// Middleware для логирования информации о каждом запросе
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		requestID := r.Header.Get("X-Request-ID")
-
-		rw := &responseWriter{
-			ResponseWriter: w,
-			statusCode:     http.StatusOK,
+func envDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
 		}
-
-		next.ServeHTTP(rw, r)
-
-		duration := time.Since(start)
-		logrus.WithFields(logrus.Fields{
-			"method":     r.Method,
-			"path":       r.URL.Path,
-			"status":     rw.statusCode,
-			"duration":   duration,
-			"request_id": requestID,
-			"remote_ip":  r.RemoteAddr,
-		}).Info("Обработан запрос")
-	})
+		logrus.Warnf("Неверное значение %s=%q, используется %s", key, v, def)
+	}
+	return def
 }
 
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
+func envInt(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+		logrus.Warnf("Неверное значение %s=%q, используется %d", key, v, def)
+	}
+	return def
 }
 
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
+func envFloat(key string, def float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			return f
+		}
+		logrus.Warnf("Неверное значение %s=%q, используется %g", key, v, def)
+	}
+	return def
 }
 
-func generateRequestID() string {
-	b := make([]byte, 8)
-	if _, err := rand.Read(b); err != nil {
-		// Fallback to timestamp-based ID if random generation fails
-		return fmt.Sprintf("%x", time.Now().UnixNano())
+// watchIngestCycles опрашивает сервис новостей и сбрасывает кэшированные
+// ответы с тегом "news" после каждого нового цикла загрузки лент
+func watchIngestCycles(interval time.Duration) {
+	lastCycle := int64(-1)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		var status struct {
+			Cycle int64 `json:"cycle"`
+		}
+		if _, _, err := fetchJSON(context.Background(), newsClient, "/api/ingest/status", newsDetailTimeout, &status); err != nil {
+			logrus.WithError(err).Warn("Не удалось получить статус загрузки новостей")
+			continue
+		}
+		if lastCycle >= 0 && status.Cycle != lastCycle {
+			removed := responseCache.PurgeTag("news")
+			logrus.WithFields(logrus.Fields{
+				"cycle":   status.Cycle,
+				"removed": removed,
+			}).Info("Кэш новостей сброшен после нового цикла загрузки")
+		}
+		lastCycle = status.Cycle
 	}
-	return hex.EncodeToString(b)
 }
 
 // Обработчик главной страницы
@@ -157,7 +381,43 @@ func handleWelcome(w http.ResponseWriter, r *http.Request) {
 	`))
 }
 
-// Обработчик списка новостей
+// Обработчик проверки состояния шлюза и автоматов защиты сервисов
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type upstreamHealth struct {
+		State    string `json:"state"`
+		Failures int    `json:"consecutive_failures"`
+	}
+	response := struct {
+		Status    string                    `json:"status"`
+		Upstreams map[string]upstreamHealth `json:"upstreams"`
+	}{
+		Status:    "healthy",
+		Upstreams: make(map[string]upstreamHealth),
+	}
+
+	for _, c := range []*upstream.Client{newsClient, commentsClient, censorshipClient, usersClient} {
+		state, failures := c.Breaker().Snapshot()
+		response.Upstreams[c.Name()] = upstreamHealth{State: state.String(), Failures: failures}
+		if state != upstream.StateClosed {
+			response.Status = "degraded"
+		}
+	}
+
+	// Сам шлюз работоспособен, даже если часть сервисов недоступна,
+	// поэтому всегда отвечаем 200 и сообщаем детали в теле
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// Обработчик списка новостей: к каждой новости добавляется число комментариев
 func handleNewsList(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -165,23 +425,47 @@ func handleNewsList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Пересылаем запрос в сервис новостей со всеми параметрами
-	resp, err := http.Get(newsServiceURL + "/api/news?" + r.URL.RawQuery)
+	var list NewsList
+	status, _, err := fetchJSON(r.Context(), newsClient, "/api/news?"+r.URL.RawQuery, newsDetailTimeout, &list)
 	if err != nil {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		http.Error(w, "Ошибка получения новостей", http.StatusInternalServerError)
+		switch status {
+		case 0:
+			middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка получения новостей")
+			http.Error(w, "Ошибка получения новостей", upstreamErrorStatus(err))
+		case http.StatusBadRequest:
+			http.Error(w, "Неверные параметры запроса", http.StatusBadRequest)
+		default:
+			middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка разбора списка новостей")
+			http.Error(w, "Ошибка получения новостей", http.StatusInternalServerError)
+		}
 		return
 	}
-	defer resp.Body.Close()
+	if list.Items == nil {
+		list.Items = []NewsShortDetailed{}
+	}
 
-	// Копируем заголовки ответа
-	for k, v := range resp.Header {
-		w.Header()[k] = v
+	if len(list.Items) > 0 {
+		ids := make([]int, len(list.Items))
+		for i, item := range list.Items {
+			ids[i] = item.ID
+		}
+		// Без счетчиков список все равно отдается, но не кэшируется
+		counts, err := commentCounts.Get(r.Context(), ids)
+		if err != nil {
+			middleware.LoggerFromContext(r.Context()).WithError(err).Warn("Ошибка получения числа комментариев")
+			list.Degraded = true
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			for i := range list.Items {
+				n := counts[list.Items[i].ID]
+				list.Items[i].CommentCount = &n
+			}
+		}
 	}
-	w.WriteHeader(resp.StatusCode)
 
-	// Копируем тело ответа
-	io.Copy(w, resp.Body)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
 }
 
 // Обработчик детальной информации о новости
@@ -199,118 +483,127 @@ func handleNewsDetail(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Неверный ID новости", http.StatusBadRequest)
 		return
 	}
-
-	newsID := parts[3]
-
-	// Получаем детали новости
-	newsResp, err := http.Get(newsServiceURL + "/api/news/" + newsID)
-	if err != nil {
-		log.Printf("Ошибка получения новости: %v", err)
+	id, err := strconv.Atoi(parts[3])
+	if err != nil || id <= 0 {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		http.Error(w, "Ошибка получения новости", http.StatusInternalServerError)
+		http.Error(w, "Неверный ID новости", http.StatusBadRequest)
 		return
 	}
-	defer newsResp.Body.Close()
+	newsID := strconv.Itoa(id)
 
-	if newsResp.StatusCode != http.StatusOK {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		http.Error(w, "Новость не найдена", http.StatusNotFound)
-		return
+	// Параметры страницы комментариев передаются сервису комментариев как есть
+	commentsQuery := url.Values{"news_id": {newsID}}
+	for _, name := range commentsPassThrough {
+		if v := r.URL.Query().Get(name); v != "" {
+			commentsQuery.Set(name, v)
+		}
 	}
 
-	// Получаем комментарии
-	commentsResp, err := http.Get(commentsServiceURL + "/api/comments?news_id=" + newsID)
-	if err != nil {
-		log.Printf("Ошибка получения комментариев: %v", err)
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		http.Error(w, "Ошибка получения комментариев", http.StatusInternalServerError)
+	// Запрашиваем новость, комментарии и их число параллельно
+	var (
+		wg             sync.WaitGroup
+		news           NewsFullDetailed
+		newsStatus     int
+		newsErr        error
+		comments       []Comment
+		commentsStatus int
+		commentsHeader http.Header
+		commentsErr    error
+		counts         map[int]int
+		countsErr      error
+	)
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		newsStatus, _, newsErr = fetchJSON(r.Context(), newsClient, "/api/news/"+newsID, newsDetailTimeout, &news)
+	}()
+	go func() {
+		defer wg.Done()
+		commentsStatus, commentsHeader, commentsErr = fetchJSON(r.Context(), commentsClient,
+			"/api/comments?"+commentsQuery.Encode(), commentsTimeout, &comments)
+	}()
+	go func() {
+		defer wg.Done()
+		counts, countsErr = commentCounts.Get(r.Context(), []int{id})
+	}()
+	wg.Wait()
+
+	// Без новости страницу собрать нельзя
+	if newsErr != nil {
+		switch newsStatus {
+		case 0:
+			middleware.LoggerFromContext(r.Context()).WithError(newsErr).Error("Ошибка получения новости")
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			http.Error(w, "Ошибка получения новости", upstreamErrorStatus(newsErr))
+		case http.StatusOK:
+			middleware.LoggerFromContext(r.Context()).WithError(newsErr).Error("Ошибка разбора новости")
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			http.Error(w, "Ошибка разбора новости", http.StatusInternalServerError)
+		default:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			http.Error(w, "Новость не найдена", http.StatusNotFound)
+		}
 		return
 	}
-	defer commentsResp.Body.Close()
 
-	if commentsResp.StatusCode != http.StatusOK {
-		log.Printf("Ошибка получения комментариев: статус %d", commentsResp.StatusCode)
+	// Ошибка в параметрах страницы комментариев - ошибка клиента
+	if commentsStatus == http.StatusBadRequest {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		http.Error(w, "Ошибка получения комментариев", http.StatusInternalServerError)
+		http.Error(w, "Неверные параметры комментариев", http.StatusBadRequest)
 		return
 	}
 
-	// Разбираем ответ с новостью
-	var news NewsFullDetailed
-	if err := json.NewDecoder(newsResp.Body).Decode(&news); err != nil {
-		log.Printf("Ошибка разбора новости: %v", err)
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		http.Error(w, "Ошибка разбора новости", http.StatusInternalServerError)
-		return
+	// Недоступность комментариев не должна ломать страницу:
+	// отдаем новость без них и помечаем ответ как деградированный
+	if commentsErr != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(commentsErr).Warn("Ошибка получения комментариев")
+		news.Comments = nil
+		news.Degraded = true
+		// Неполный ответ не должен попасть в кэш
+		w.Header().Set("Cache-Control", "no-store")
+	} else {
+		news.Comments = comments
+		news.CommentsNextCursor = commentsHeader.Get("X-Next-Cursor")
 	}
-
-	// Разбираем ответ с комментариями
-	var comments []Comment
-	if err := json.NewDecoder(commentsResp.Body).Decode(&comments); err != nil {
-		log.Printf("Ошибка разбора комментариев: %v", err)
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		http.Error(w, "Ошибка разбора комментариев", http.StatusInternalServerError)
-		return
+	if countsErr == nil {
+		n := counts[news.ID]
+		news.CommentCount = &n
 	}
 
-	// Объединяем новость и комментарии
-	news.Comments = comments
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(news)
 }
 
-// Обработчик добавления новых комментариев
-func handleAddComment(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
-		return
+// upstreamErrorStatus подбирает код ответа клиенту по ошибке обращения к сервису
+func upstreamErrorStatus(err error) int {
+	if errors.Is(err, upstream.ErrCircuitOpen) {
+		return http.StatusServiceUnavailable
 	}
+	return http.StatusInternalServerError
+}
 
-	// Читаем тело запроса
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		http.Error(w, "Ошибка чтения тела запроса", http.StatusBadRequest)
-		return
-	}
-	defer r.Body.Close()
+// fetchJSON выполняет GET-запрос к сервису с таймаутом и разбирает JSON-ответ в v.
+// Возвращает код и заголовки ответа сервиса (0, если ответ не был получен).
+func fetchJSON(ctx context.Context, client *upstream.Client, path string, timeout time.Duration, v interface{}) (int, http.Header, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	// Проверяем комментарий через сервис цензуры
-	censorResp, err := http.Post(censorshipServiceURL+"/api/censor", "application/json", bytes.NewReader(body))
+	req, err := client.NewRequest(ctx, http.MethodGet, path, nil)
 	if err != nil {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		http.Error(w, "Ошибка проверки комментария", http.StatusInternalServerError)
-		return
-	}
-	defer censorResp.Body.Close()
-
-	if censorResp.StatusCode != http.StatusOK {
-		// Копируем ответ об ошибке от сервиса цензуры
-		for k, v := range censorResp.Header {
-			w.Header()[k] = v
-		}
-		w.WriteHeader(censorResp.StatusCode)
-		io.Copy(w, censorResp.Body)
-		return
+		return 0, nil, err
 	}
 
-	// Если комментарий прошел цензуру, создаем его
-	resp, err := http.Post(commentsServiceURL+"/api/comments", "application/json", bytes.NewReader(body))
+	resp, err := client.Do(req)
 	if err != nil {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		http.Error(w, "Ошибка создания комментария", http.StatusInternalServerError)
-		return
+		return 0, nil, err
 	}
 	defer resp.Body.Close()
 
-	// Копируем заголовки ответа
-	for k, v := range resp.Header {
-		w.Header()[k] = v
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, resp.Header, fmt.Errorf("неожиданный статус ответа: %d", resp.StatusCode)
 	}
-	w.WriteHeader(resp.StatusCode)
-
-	// Копируем тело ответа
-	io.Copy(w, resp.Body)
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return resp.StatusCode, resp.Header, err
+	}
+	return resp.StatusCode, resp.Header, nil
 }
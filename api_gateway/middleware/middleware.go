@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// contextKey - тип ключей контекста пакета
+type contextKey string
+
+const (
+	// RequestIDHeader - имя заголовка для ID запроса
+	RequestIDHeader = "X-Request-ID"
+	// requestIDKey - ключ контекста для ID запроса
+	requestIDKey contextKey = "request_id"
+	// maxRequestIDLength - максимальная длина принимаемого ID запроса
+	maxRequestIDLength = 128
+)
+
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// RequestIDFromContext возвращает ID запроса из контекста
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// ContextWithRequestID возвращает контекст с ID запроса
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// LoggerFromContext возвращает запись логгера с ID запроса и трассировки
+func LoggerFromContext(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	if id := RequestIDFromContext(ctx); id != "" {
+		entry = entry.WithField("request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		entry = entry.WithFields(logrus.Fields{
+			"trace_id": sc.TraceID().String(),
+			"span_id":  sc.SpanID().String(),
+		})
+	}
+	return entry
+}
+
+// RequestIDMiddleware принимает ID запроса от клиента или генерирует новый
+// и сохраняет его в контексте, заголовке запроса (для проксирования) и ответе
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = generateRequestID()
+			r.Header.Set(RequestIDHeader, requestID)
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(ContextWithRequestID(r.Context(), requestID)))
+	})
+}
+
+// LoggingMiddleware логирует информацию о каждом запросе
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rw := &responseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+
+		next.ServeHTTP(rw, r)
+
+		duration := time.Since(start)
+		LoggerFromContext(r.Context()).WithFields(logrus.Fields{
+			"method":    r.Method,
+			"path":      r.URL.Path,
+			"status":    rw.statusCode,
+			"duration":  duration,
+			"remote_ip": r.RemoteAddr,
+		}).Info("Обработан запрос")
+	})
+}
+
+// validRequestID отсекает пустые, слишком длинные и небезопасные для логов ID
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func generateRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// Fallback to timestamp-based ID if random generation fails
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
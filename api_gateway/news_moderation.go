@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"api_gateway/auth"
+	"api_gateway/middleware"
+
+	"github.com/sirupsen/logrus"
+)
+
+// handleModerateNews передает решение редактора о новости сервису новостей.
+// Статус влияет на публичный список, поэтому после решения кэш новостей сбрасывается
+func handleModerateNews(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Ошибка чтения тела запроса", http.StatusBadRequest)
+		return
+	}
+
+	req, err := newsClient.NewRequest(r.Context(), http.MethodPost, r.URL.Path, bytes.NewReader(body))
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Ошибка модерации новости", http.StatusInternalServerError)
+		return
+	}
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if id, ok := auth.FromContext(r.Context()); ok {
+		auth.SetHeaders(req.Header, id)
+	}
+	resp, err := newsClient.Do(req)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Ошибка модерации новости", upstreamErrorStatus(err))
+		return
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, "Ошибка модерации новости", http.StatusBadGateway)
+		return
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		removed := responseCache.PurgeTag("news")
+		middleware.LoggerFromContext(r.Context()).WithFields(logrus.Fields{
+			"path":    r.URL.Path,
+			"removed": removed,
+		}).Info("Кэш новостей сброшен после решения редактора")
+	}
+
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(respBody)
+}
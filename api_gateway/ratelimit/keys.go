@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"api_gateway/upstream"
+)
+
+// ErrUnknownKey возвращается для несуществующего или отозванного API-ключа
+var ErrUnknownKey = errors.New("неизвестный API-ключ")
+
+// Key - проверенный API-ключ
+type Key struct {
+	ID   string
+	Name string
+	// Limit - собственный лимит ключа; nil - лимит ключей по умолчанию
+	Limit *Limit
+}
+
+// KeyResolver проверяет API-ключи. CachedKey возвращает результат прошлой
+// проверки без обращения к сервису; cached=false - результата нет
+type KeyResolver interface {
+	ResolveKey(ctx context.Context, key string) (*Key, error)
+	CachedKey(key string) (k *Key, err error, cached bool)
+}
+
+// maxCachedKeys ограничивает размер кэша проверенных ключей
+const maxCachedKeys = 10000
+
+type cachedKey struct {
+	key     *Key
+	err     error
+	expires time.Time
+}
+
+// RemoteKeys проверяет ключи через сервис пользователей и кэширует результат
+// на ttl, поэтому отзыв ключа вступает в силу не позже чем через ttl
+type RemoteKeys struct {
+	client *upstream.Client
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedKey
+}
+
+// NewRemoteKeys создает проверку ключей через сервис пользователей
+func NewRemoteKeys(client *upstream.Client, ttl time.Duration) *RemoteKeys {
+	return &RemoteKeys{
+		client: client,
+		ttl:    ttl,
+		cache:  make(map[string]cachedKey),
+	}
+}
+
+// CachedKey возвращает ключ или ErrUnknownKey из кэша
+func (k *RemoteKeys) CachedKey(key string) (*Key, error, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if c, ok := k.cache[key]; ok && time.Now().Before(c.expires) {
+		return c.key, c.err, true
+	}
+	return nil, nil, false
+}
+
+// ResolveKey возвращает ключ или ErrUnknownKey
+func (k *RemoteKeys) ResolveKey(ctx context.Context, key string) (*Key, error) {
+	if resolved, err, ok := k.CachedKey(key); ok {
+		return resolved, err
+	}
+	now := time.Now()
+
+	resolved, err := k.fetch(ctx, key)
+	if err != nil && !errors.Is(err, ErrUnknownKey) {
+		// Ошибки сервиса не кэшируем
+		return nil, err
+	}
+
+	k.mu.Lock()
+	if len(k.cache) >= maxCachedKeys {
+		k.evict(now)
+	}
+	k.cache[key] = cachedKey{key: resolved, err: err, expires: now.Add(k.ttl)}
+	k.mu.Unlock()
+	return resolved, err
+}
+
+// evict освобождает место в кэше: удаляет истекшие записи, а если таких нет -
+// запись, которая истекает раньше всех. Вызывается под k.mu
+func (k *RemoteKeys) evict(now time.Time) {
+	var (
+		oldest  string
+		expires time.Time
+	)
+	for key, c := range k.cache {
+		if !now.Before(c.expires) {
+			delete(k.cache, key)
+			continue
+		}
+		if oldest == "" || c.expires.Before(expires) {
+			oldest, expires = key, c.expires
+		}
+	}
+	if len(k.cache) >= maxCachedKeys {
+		delete(k.cache, oldest)
+	}
+}
+
+func (k *RemoteKeys) fetch(ctx context.Context, key string) (*Key, error) {
+	body, err := json.Marshal(map[string]string{"key": key})
+	if err != nil {
+		return nil, err
+	}
+	req, err := k.client.NewRequest(ctx, http.MethodPost, "/internal/api-keys/verify", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrUnknownKey
+	default:
+		return nil, fmt.Errorf("сервис пользователей вернул статус %d", resp.StatusCode)
+	}
+
+	var info struct {
+		ID        int      `json:"id"`
+		Name      string   `json:"name"`
+		RateLimit *float64 `json:"rate_limit"`
+		Burst     *int     `json:"burst"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+
+	resolved := &Key{ID: strconv.Itoa(info.ID), Name: info.Name}
+	if info.RateLimit != nil || info.Burst != nil {
+		resolved.Limit = &Limit{}
+		if info.RateLimit != nil {
+			resolved.Limit.Rate = *info.RateLimit
+		}
+		if info.Burst != nil {
+			resolved.Limit.Burst = *info.Burst
+		}
+	}
+	return resolved, nil
+}
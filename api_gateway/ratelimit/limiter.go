@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api_gateway/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// APIKeyHeader - заголовок с API-ключом партнерского приложения
+const APIKeyHeader = "X-API-Key"
+
+// Области лимитов (метка scope метрики)
+const (
+	ScopeKey = "key"
+	ScopeIP  = "ip"
+)
+
+// Config - настройки ограничителя
+type Config struct {
+	Store Store
+	Keys  KeyResolver
+	// IPLimit - лимит запросов без ключа, на IP-адрес клиента
+	IPLimit Limit
+	// KeyLimit - лимит ключа, если у него нет собственного
+	KeyLimit Limit
+	// Exempt - пути, на которые лимиты не распространяются
+	Exempt []string
+	// Throttled - счетчик отклоненных запросов с меткой scope
+	Throttled *prometheus.CounterVec
+}
+
+// Limiter ограничивает частоту запросов по API-ключу или IP-адресу
+type Limiter struct {
+	cfg    Config
+	exempt map[string]bool
+}
+
+// New создает ограничитель
+func New(cfg Config) *Limiter {
+	l := &Limiter{cfg: cfg, exempt: make(map[string]bool)}
+	for _, p := range cfg.Exempt {
+		l.exempt[p] = true
+	}
+	return l
+}
+
+// Middleware проверяет лимит до маршрутизации запроса
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.exempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		logger := middleware.LoggerFromContext(r.Context())
+
+		ipKey := "ip:" + clientIP(r)
+		scope, bucketKey, limit := ScopeIP, ipKey, l.cfg.IPLimit
+		// ipTaken - лимит IP-адреса уже израсходован до проверки ключа
+		var ipTaken *Result
+		invalidKey := false
+		if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+			key, err, cached := l.cfg.Keys.CachedKey(apiKey)
+			if !cached {
+				// Проверка ключа не из кэша обращается к сервису пользователей,
+				// поэтому сначала расходует лимит IP-адреса: перебор ключей
+				// не нагружает сервис сверх этого лимита
+				res, takeErr := l.cfg.Store.Take(r.Context(), ipKey, l.cfg.IPLimit)
+				switch {
+				case takeErr != nil:
+					logger.WithError(takeErr).Error("Ошибка хранилища лимитов")
+				case !res.Allowed:
+					r.Header.Del(APIKeyHeader)
+					l.throttle(w, ScopeIP, l.cfg.IPLimit, res)
+					return
+				default:
+					ipTaken = &res
+				}
+				key, err = l.cfg.Keys.ResolveKey(r.Context(), apiKey)
+			}
+			switch {
+			case err == nil:
+				scope, bucketKey, limit = ScopeKey, "key:"+key.ID, l.keyLimit(key)
+			case errors.Is(err, ErrUnknownKey):
+				// Перебор ключей расходует лимит IP-адреса
+				invalidKey = true
+			default:
+				// Сервис пользователей недоступен: действуем как без ключа
+				logger.WithError(err).Warn("Не удалось проверить API-ключ")
+			}
+			// Ключ нужен только шлюзу
+			r.Header.Del(APIKeyHeader)
+		}
+
+		var res Result
+		if scope == ScopeIP && ipTaken != nil {
+			// Лимит IP-адреса не расходуем дважды за один запрос
+			res = *ipTaken
+		} else {
+			var err error
+			res, err = l.cfg.Store.Take(r.Context(), bucketKey, limit)
+			if err != nil {
+				// Ошибка хранилища не должна останавливать обслуживание
+				logger.WithError(err).Error("Ошибка хранилища лимитов")
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		if !res.Allowed {
+			l.throttle(w, scope, limit, res)
+			return
+		}
+		setLimitHeaders(w, limit, res)
+		if invalidKey {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			http.Error(w, "Недействительный API-ключ", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// throttle отвечает 429 на запрос сверх лимита
+func (l *Limiter) throttle(w http.ResponseWriter, scope string, limit Limit, res Result) {
+	l.cfg.Throttled.WithLabelValues(scope).Inc()
+	setLimitHeaders(w, limit, res)
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.Error(w, "Превышен лимит запросов", http.StatusTooManyRequests)
+}
+
+// setLimitHeaders сообщает клиенту лимит и остаток
+func setLimitHeaders(w http.ResponseWriter, limit Limit, res Result) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+}
+
+// keyLimit дополняет собственный лимит ключа значениями по умолчанию
+func (l *Limiter) keyLimit(key *Key) Limit {
+	limit := l.cfg.KeyLimit
+	if key.Limit != nil {
+		if key.Limit.Rate > 0 {
+			limit.Rate = key.Limit.Rate
+		}
+		if key.Limit.Burst > 0 {
+			limit.Burst = key.Limit.Burst
+		}
+	}
+	return limit
+}
+
+// clientIP - адрес, с которого пришло соединение. Шлюз стоит на границе,
+// поэтому X-Forwarded-For от клиента не учитывается
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeKeys - проверка ключей без сервиса пользователей
+type fakeKeys struct {
+	keys   map[string]*Key
+	cached map[string]bool
+	down   bool
+	remote int
+}
+
+func (f *fakeKeys) CachedKey(key string) (*Key, error, bool) {
+	if !f.cached[key] {
+		return nil, nil, false
+	}
+	k, err := f.lookup(key)
+	return k, err, true
+}
+
+func (f *fakeKeys) ResolveKey(_ context.Context, key string) (*Key, error) {
+	f.remote++
+	if f.down {
+		return nil, errors.New("сервис недоступен")
+	}
+	return f.lookup(key)
+}
+
+func (f *fakeKeys) lookup(key string) (*Key, error) {
+	if k, ok := f.keys[key]; ok {
+		return k, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func newTestLimiter(keys KeyResolver) *Limiter {
+	return New(Config{
+		Store:     NewMemoryStore(),
+		Keys:      keys,
+		IPLimit:   Limit{Rate: 0.001, Burst: 2},
+		KeyLimit:  Limit{Rate: 0.001, Burst: 5},
+		Exempt:    []string{"/health"},
+		Throttled: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "throttled"}, []string{"scope"}),
+	})
+}
+
+func doRequest(h http.Handler, path, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if apiKey != "" {
+		req.Header.Set(APIKeyHeader, apiKey)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLimiterMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(APIKeyHeader) != "" {
+			t.Error("API-ключ передан дальше шлюза")
+		}
+	})
+	valid := &Key{ID: "1", Name: "partner"}
+
+	tests := []struct {
+		name   string
+		keys   *fakeKeys
+		path   string
+		apiKey string
+		want   []int // статусы ответов на запросы подряд
+		remote int   // обращений к сервису пользователей
+	}{
+		{
+			name: "без ключа - лимит IP",
+			keys: &fakeKeys{},
+			path: "/api/news",
+			want: []int{200, 200, 429},
+		},
+		{
+			name: "исключенный путь без лимита",
+			keys: &fakeKeys{},
+			path: "/health",
+			want: []int{200, 200, 200, 200},
+		},
+		{
+			name:   "ключ из кэша - собственный лимит без лимита IP",
+			keys:   &fakeKeys{keys: map[string]*Key{"good": valid}, cached: map[string]bool{"good": true}},
+			path:   "/api/news",
+			apiKey: "good",
+			want:   []int{200, 200, 200, 200, 200, 429},
+		},
+		{
+			name:   "ключ не из кэша проверяется после лимита IP",
+			keys:   &fakeKeys{keys: map[string]*Key{"good": valid}},
+			path:   "/api/news",
+			apiKey: "good",
+			want:   []int{200, 200, 429},
+			remote: 2,
+		},
+		{
+			name:   "перебор ключей упирается в лимит IP без обращения к сервису",
+			keys:   &fakeKeys{},
+			path:   "/api/news",
+			apiKey: "bad",
+			want:   []int{401, 401, 429, 429},
+			remote: 2,
+		},
+		{
+			name:   "неизвестный ключ из кэша расходует лимит IP",
+			keys:   &fakeKeys{cached: map[string]bool{"bad": true}},
+			path:   "/api/news",
+			apiKey: "bad",
+			want:   []int{401, 401, 429},
+		},
+		{
+			name:   "сервис пользователей недоступен - лимит IP один раз за запрос",
+			keys:   &fakeKeys{down: true},
+			path:   "/api/news",
+			apiKey: "good",
+			want:   []int{200, 200, 429},
+			remote: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestLimiter(tt.keys).Middleware(ok)
+			for i, want := range tt.want {
+				rec := doRequest(h, tt.path, tt.apiKey)
+				if rec.Code != want {
+					t.Fatalf("запрос %d: статус %d, want %d", i, rec.Code, want)
+				}
+				if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+					t.Errorf("запрос %d: нет Retry-After", i)
+				}
+			}
+			if tt.keys.remote != tt.remote {
+				t.Errorf("обращений к сервису пользователей %d, want %d", tt.keys.remote, tt.remote)
+			}
+		})
+	}
+}
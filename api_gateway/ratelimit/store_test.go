@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 3}
+	type step struct {
+		after     time.Duration // пауза перед запросом
+		allowed   bool
+		remaining int
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "полная корзина пропускает burst запросов подряд",
+			steps: []step{
+				{0, true, 2}, {0, true, 1}, {0, true, 0}, {0, false, 0},
+			},
+		},
+		{
+			name: "токены пополняются со скоростью rate",
+			steps: []step{
+				{0, true, 2}, {0, true, 1}, {0, true, 0},
+				{500 * time.Millisecond, true, 0},
+				{0, false, 0},
+				{time.Second, true, 1},
+			},
+		},
+		{
+			name: "корзина не наполняется сверх burst",
+			steps: []step{
+				{0, true, 2}, {time.Hour, true, 2}, {0, true, 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1000, 0)
+			s := NewMemoryStore()
+			s.now = func() time.Time { return now }
+			for i, st := range tt.steps {
+				now = now.Add(st.after)
+				res, err := s.Take(context.Background(), "ip:1", limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Allowed != st.allowed || res.Remaining != st.remaining {
+					t.Fatalf("запрос %d: allowed=%v remaining=%d, want allowed=%v remaining=%d",
+						i, res.Allowed, res.Remaining, st.allowed, st.remaining)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreRetryAfter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	limit := Limit{Rate: 4, Burst: 1}
+
+	s.Take(context.Background(), "k", limit)
+	res, _ := s.Take(context.Background(), "k", limit)
+	if res.Allowed {
+		t.Fatal("второй запрос пропущен")
+	}
+	if res.RetryAfter != 250*time.Millisecond {
+		t.Errorf("RetryAfter = %s, want 250ms", res.RetryAfter)
+	}
+	if res.ResetAfter != 250*time.Millisecond {
+		t.Errorf("ResetAfter = %s, want 250ms", res.ResetAfter)
+	}
+}
+
+func TestMemoryStoreSeparateKeys(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 1}
+	for _, key := range []string{"ip:1", "ip:2", "key:1"} {
+		if res, _ := s.Take(context.Background(), key, limit); !res.Allowed {
+			t.Errorf("первый запрос %s отклонен", key)
+		}
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 2}
+
+	s.Take(context.Background(), "idle", limit)
+	now = now.Add(sweepInterval)
+	s.Take(context.Background(), "active", limit)
+	// Корзина idle за минуту наполнилась и удалена, active только что создана
+	if n := s.Len(); n != 1 {
+		t.Errorf("Len = %d, want 1", n)
+	}
+}
@@ -0,0 +1,261 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"api_gateway/cache"
+	"api_gateway/middleware"
+	"api_gateway/upstream"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options - зависимости, необходимые для построения маршрутизатора
+type Options struct {
+	// Upstreams - клиенты сервисов по имени
+	Upstreams map[string]*upstream.Client
+	// Handlers - встроенные обработчики составных эндпоинтов по имени
+	Handlers map[string]http.Handler
+	// Auth оборачивает обработчик проверкой аутентификации заданного уровня.
+	// Возвращает ошибку для неизвестного уровня
+	Auth func(level string, next http.Handler) (http.Handler, error)
+	// Fallback обрабатывает запросы, не попавшие ни в один маршрут
+	Fallback http.Handler
+	// Cache - кэш ответов для маршрутов с правилами cache
+	Cache *cache.Cache
+	// CacheLookups - счетчик обращений к кэшу по результату, может быть nil
+	CacheLookups *prometheus.CounterVec
+}
+
+// Router направляет запросы по таблице маршрутов
+type Router struct {
+	entries  []*entry
+	fallback http.Handler
+}
+
+// entry объединяет маршруты с одинаковым путем
+type entry struct {
+	path   string
+	prefix bool
+	// segments - части пути с параметрами вида {id}; nil, если параметров нет
+	segments []string
+	handlers map[string]http.Handler
+	allow    string
+}
+
+// NewRouter строит маршрутизатор по таблице
+func NewRouter(t *Table, opts Options) (*Router, error) {
+	byPath := make(map[string]*entry)
+	for _, route := range t.Routes {
+		h, err := buildHandler(route, opts)
+		if err != nil {
+			return nil, fmt.Errorf("маршрут %s: %v", route.Path, err)
+		}
+
+		e, ok := byPath[route.Path]
+		if !ok {
+			e = &entry{
+				path:     route.Path,
+				prefix:   strings.HasSuffix(route.Path, "/"),
+				handlers: make(map[string]http.Handler),
+			}
+			if strings.Contains(route.Path, "{") {
+				e.segments = strings.Split(route.Path, "/")
+			}
+			byPath[route.Path] = e
+		}
+		for _, m := range route.Methods {
+			e.handlers[m] = h
+		}
+	}
+
+	rt := &Router{fallback: opts.Fallback}
+	for _, e := range byPath {
+		methods := make([]string, 0, len(e.handlers))
+		for m := range e.handlers {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		e.allow = strings.Join(methods, ", ")
+		rt.entries = append(rt.entries, e)
+	}
+	// Более длинные пути проверяются первыми
+	sort.Slice(rt.entries, func(i, j int) bool {
+		return len(rt.entries[i].path) > len(rt.entries[j].path)
+	})
+	if rt.fallback == nil {
+		rt.fallback = http.NotFoundHandler()
+	}
+	return rt, nil
+}
+
+// ServeHTTP реализует http.Handler
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, e := range rt.entries {
+		if !e.match(r.URL.Path) {
+			continue
+		}
+		h, ok := e.handlers[r.Method]
+		if !ok {
+			w.Header().Set("Allow", e.allow)
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+		h.ServeHTTP(w, r)
+		return
+	}
+	rt.fallback.ServeHTTP(w, r)
+}
+
+// Route возвращает путь маршрута из таблицы, который обработает запрос,
+// или пустую строку, если запрос уйдет в fallback
+func (rt *Router) Route(r *http.Request) string {
+	for _, e := range rt.entries {
+		if e.match(r.URL.Path) {
+			return e.path
+		}
+	}
+	return ""
+}
+
+func (e *entry) match(path string) bool {
+	if e.segments != nil {
+		return e.matchSegments(path)
+	}
+	if e.prefix {
+		return strings.HasPrefix(path, e.path)
+	}
+	return path == e.path
+}
+
+// matchSegments сравнивает путь с шаблоном по частям: параметр {name}
+// совпадает с любой непустой частью. У префиксного шаблона последняя
+// (пустая) часть совпадает с любым остатком пути
+func (e *entry) matchSegments(path string) bool {
+	parts := strings.Split(path, "/")
+	segments := e.segments
+	if e.prefix {
+		if len(parts) < len(segments) {
+			return false
+		}
+		segments = segments[:len(segments)-1]
+	} else if len(parts) != len(segments) {
+		return false
+	}
+	for i, seg := range segments {
+		if isParam(seg) {
+			if parts[i] == "" {
+				return false
+			}
+		} else if parts[i] != seg {
+			return false
+		}
+	}
+	return true
+}
+
+// isParam сообщает, является ли часть пути параметром вида {name}
+func isParam(seg string) bool {
+	return len(seg) > 2 && strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}")
+}
+
+func buildHandler(route Route, opts Options) (http.Handler, error) {
+	var h http.Handler
+	if route.Handler != "" {
+		var ok bool
+		if h, ok = opts.Handlers[route.Handler]; !ok {
+			return nil, fmt.Errorf("неизвестный обработчик %q", route.Handler)
+		}
+	} else {
+		client, ok := opts.Upstreams[route.Upstream]
+		if !ok {
+			return nil, fmt.Errorf("неизвестный сервис %q", route.Upstream)
+		}
+		proxy, err := newProxy(route, client)
+		if err != nil {
+			return nil, err
+		}
+		h = proxy
+	}
+
+	if route.Timeout > 0 {
+		h = withTimeout(h, time.Duration(route.Timeout))
+	}
+
+	if route.Cache != nil {
+		if opts.Cache == nil {
+			return nil, fmt.Errorf("кэш не настроен")
+		}
+		h = opts.Cache.Handler(cache.Policy{
+			TTL:   time.Duration(route.Cache.TTL),
+			Stale: time.Duration(route.Cache.StaleWhileRevalidate),
+			Tags:  route.Cache.Tags,
+		}, opts.CacheLookups, h)
+	}
+
+	if opts.Auth != nil {
+		return opts.Auth(route.Auth, h)
+	}
+	if route.Auth != "none" {
+		return nil, fmt.Errorf("аутентификация %q не поддерживается", route.Auth)
+	}
+	return h, nil
+}
+
+// newProxy создает обратный прокси к сервису с переписыванием пути
+func newProxy(route Route, client *upstream.Client) (*httputil.ReverseProxy, error) {
+	target, err := url.Parse(client.BaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("неверный адрес сервиса %s: %v", client.Name(), err)
+	}
+
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			path := pr.In.URL.Path
+			if route.RewritePrefix != nil {
+				path = *route.RewritePrefix + strings.TrimPrefix(path, route.Path)
+			}
+			pr.Out.URL.Scheme = target.Scheme
+			pr.Out.URL.Host = target.Host
+			pr.Out.URL.Path = strings.TrimSuffix(target.Path, "/") + path
+			pr.Out.URL.RawPath = ""
+			pr.Out.Host = ""
+			pr.SetXForwarded()
+		},
+		Transport: client,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			middleware.LoggerFromContext(r.Context()).WithError(err).WithField("upstream", client.Name()).
+				Error("Ошибка проксирования запроса")
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			http.Error(w, "Ошибка обращения к сервису "+client.Name(), proxyErrorStatus(err))
+		},
+	}, nil
+}
+
+func proxyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, upstream.ErrCircuitOpen):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func withTimeout(next http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
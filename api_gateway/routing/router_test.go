@@ -0,0 +1,146 @@
+package routing
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// named отвечает своим именем, чтобы было видно, какой маршрут сработал
+func named(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, name)
+	})
+}
+
+func TestRouterMatch(t *testing.T) {
+	table := &Table{Routes: []Route{
+		{Path: "/api/news", Methods: []string{"GET"}, Handler: "news_list"},
+		{Path: "/api/comments/", Methods: []string{"GET"}, Handler: "comments"},
+		{Path: "/api/comments/", Methods: []string{"DELETE"}, Handler: "delete_comment"},
+		{Path: "/api/comments/{id}/vote", Methods: []string{"PUT", "DELETE"}, Handler: "vote"},
+		{Path: "/api/comments/{id}/reactions/", Methods: []string{"PUT", "DELETE"}, Handler: "reaction"},
+	}}
+	if err := table.validate(); err != nil {
+		t.Fatal(err)
+	}
+	handlers := make(map[string]http.Handler)
+	for _, name := range []string{"news_list", "comments", "delete_comment", "vote", "reaction"} {
+		handlers[name] = named(name)
+	}
+	rt, err := NewRouter(table, Options{Handlers: handlers, Fallback: named("fallback")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, path string
+		want         string // имя обработчика или статус ответа
+	}{
+		{"GET", "/api/news", "news_list"},
+		{"GET", "/api/news/1", "fallback"},
+		{"GET", "/api/comments/5", "comments"},
+		{"DELETE", "/api/comments/5", "delete_comment"},
+		{"PUT", "/api/comments/5/vote", "vote"},
+		{"DELETE", "/api/comments/5/vote", "vote"},
+		{"PUT", "/api/comments/5/reactions/like", "reaction"},
+		{"DELETE", "/api/comments/5/reactions/", "reaction"},
+		// Пустой параметр не совпадает, запрос уходит в префиксный маршрут
+		{"PUT", "/api/comments//vote", "405"},
+		// Лишняя часть пути после точного шаблона
+		{"PUT", "/api/comments/5/vote/x", "405"},
+		{"POST", "/api/comments/5/vote", "405"},
+		{"GET", "/health", "fallback"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		got := rec.Body.String()
+		if rec.Code != http.StatusOK {
+			got = strconv.Itoa(rec.Code)
+		}
+		if got != tt.want {
+			t.Errorf("%s %s: %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRouterAllow(t *testing.T) {
+	table := &Table{Routes: []Route{
+		{Path: "/api/comments/{id}/vote", Methods: []string{"PUT", "DELETE"}, Handler: "vote"},
+	}}
+	if err := table.validate(); err != nil {
+		t.Fatal(err)
+	}
+	rt, err := NewRouter(table, Options{Handlers: map[string]http.Handler{"vote": named("vote")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/comments/1/vote", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "DELETE, PUT" {
+		t.Errorf("статус %d, Allow %q, want 405 и \"DELETE, PUT\"", rec.Code, rec.Header().Get("Allow"))
+	}
+	if got := rt.Route(httptest.NewRequest(http.MethodGet, "/api/comments/1/vote", nil)); got != "/api/comments/{id}/vote" {
+		t.Errorf("Route = %q, want шаблон из таблицы", got)
+	}
+}
+
+func TestValidatePathParams(t *testing.T) {
+	rewrite := "/"
+	tests := []struct {
+		name    string
+		route   Route
+		wantErr string
+	}{
+		{
+			name:  "параметр",
+			route: Route{Path: "/api/comments/{id}/vote", Methods: []string{"PUT"}, Upstream: "comments_service"},
+		},
+		{
+			name:  "префикс с параметром",
+			route: Route{Path: "/api/comments/{id}/reactions/", Methods: []string{"PUT"}, Upstream: "comments_service"},
+		},
+		{
+			name:    "незакрытая скобка",
+			route:   Route{Path: "/api/comments/{id/vote", Methods: []string{"PUT"}, Upstream: "comments_service"},
+			wantErr: "неверный параметр пути",
+		},
+		{
+			name:    "пустой параметр",
+			route:   Route{Path: "/api/comments/{}/vote", Methods: []string{"PUT"}, Upstream: "comments_service"},
+			wantErr: "неверный параметр пути",
+		},
+		{
+			name:    "скобки внутри части пути",
+			route:   Route{Path: "/api/comments/c{id}", Methods: []string{"PUT"}, Upstream: "comments_service"},
+			wantErr: "неверный параметр пути",
+		},
+		{
+			name:    "вложенные скобки",
+			route:   Route{Path: "/api/comments/{{id}}", Methods: []string{"PUT"}, Upstream: "comments_service"},
+			wantErr: "неверный параметр пути",
+		},
+		{
+			name:    "rewrite_prefix с параметром",
+			route:   Route{Path: "/api/comments/{id}/vote", Methods: []string{"PUT"}, Upstream: "comments_service", RewritePrefix: &rewrite},
+			wantErr: "rewrite_prefix нельзя использовать с параметрами пути",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Table{Routes: []Route{tt.route}}).validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("validate: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Fatalf("validate: нет ошибки, want %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Fatalf("validate: %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Duration - длительность, задаваемая в конфигурации строкой ("5s", "250ms")
+type Duration time.Duration
+
+// UnmarshalJSON разбирает длительность из строки
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("длительность должна быть строкой: %v", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Route описывает один маршрут шлюза
+type Route struct {
+	// Path - путь запроса. Путь, оканчивающийся на "/", задает префикс.
+	// Часть пути вида {id} совпадает с любой непустой частью запроса
+	Path string `json:"path"`
+	// Methods - допустимые HTTP-методы
+	Methods []string `json:"methods"`
+	// Upstream - имя сервиса для прямого проксирования
+	Upstream string `json:"upstream,omitempty"`
+	// RewritePrefix заменяет совпавшую часть пути перед отправкой в сервис
+	RewritePrefix *string `json:"rewrite_prefix,omitempty"`
+	// Timeout ограничивает время обработки запроса
+	Timeout Duration `json:"timeout,omitempty"`
+	// Auth - требуемый уровень аутентификации ("none", если пусто)
+	Auth string `json:"auth,omitempty"`
+	// Handler - имя встроенного обработчика для составных эндпоинтов
+	Handler string `json:"handler,omitempty"`
+	// Cache - правила кэширования ответов
+	Cache *CacheRule `json:"cache,omitempty"`
+}
+
+// CacheRule описывает кэширование ответов маршрута
+type CacheRule struct {
+	// TTL - время, в течение которого ответ считается свежим
+	TTL Duration `json:"ttl"`
+	// StaleWhileRevalidate - сколько после TTL можно отдавать устаревший ответ,
+	// обновляя его в фоне
+	StaleWhileRevalidate Duration `json:"stale_while_revalidate,omitempty"`
+	// Tags - теги для групповой инвалидации (например, "news")
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Table - таблица маршрутов шлюза
+type Table struct {
+	Routes []Route `json:"routes"`
+}
+
+// Load читает таблицу маршрутов из JSON-файла и проверяет ее
+func Load(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var t Table
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&t); err != nil {
+		return nil, fmt.Errorf("ошибка разбора %s: %v", path, err)
+	}
+	if err := t.validate(); err != nil {
+		return nil, fmt.Errorf("ошибка в %s: %v", path, err)
+	}
+	return &t, nil
+}
+
+func (t *Table) validate() error {
+	if len(t.Routes) == 0 {
+		return fmt.Errorf("таблица маршрутов пуста")
+	}
+
+	seen := make(map[string]bool)
+	for i := range t.Routes {
+		r := &t.Routes[i]
+		if !strings.HasPrefix(r.Path, "/") {
+			return fmt.Errorf("маршрут %d: путь %q должен начинаться с /", i, r.Path)
+		}
+		if err := validatePath(r.Path); err != nil {
+			return fmt.Errorf("маршрут %s: %v", r.Path, err)
+		}
+		if strings.Contains(r.Path, "{") && r.RewritePrefix != nil {
+			return fmt.Errorf("маршрут %s: rewrite_prefix нельзя использовать с параметрами пути", r.Path)
+		}
+		if (r.Upstream == "") == (r.Handler == "") {
+			return fmt.Errorf("маршрут %s: нужно указать ровно одно из upstream или handler", r.Path)
+		}
+		if r.Handler != "" && r.RewritePrefix != nil {
+			return fmt.Errorf("маршрут %s: rewrite_prefix применим только к upstream", r.Path)
+		}
+		if r.Timeout < 0 {
+			return fmt.Errorf("маршрут %s: отрицательный timeout", r.Path)
+		}
+		if len(r.Methods) == 0 {
+			return fmt.Errorf("маршрут %s: не указаны методы", r.Path)
+		}
+		for j, m := range r.Methods {
+			m = strings.ToUpper(m)
+			if !isKnownMethod(m) {
+				return fmt.Errorf("маршрут %s: неизвестный метод %q", r.Path, m)
+			}
+			key := m + " " + r.Path
+			if seen[key] {
+				return fmt.Errorf("маршрут %s: метод %s объявлен повторно", r.Path, m)
+			}
+			seen[key] = true
+			r.Methods[j] = m
+		}
+		if r.Auth == "" {
+			r.Auth = "none"
+		}
+		if err := r.validateCache(); err != nil {
+			return fmt.Errorf("маршрут %s: %v", r.Path, err)
+		}
+	}
+	return nil
+}
+
+// validatePath проверяет, что фигурные скобки встречаются только в параметрах
+// вида {name}, занимающих часть пути целиком
+func validatePath(path string) error {
+	for _, seg := range strings.Split(path, "/") {
+		if strings.ContainsAny(seg, "{}") && !isParam(seg) {
+			return fmt.Errorf("неверный параметр пути %q", seg)
+		}
+		if isParam(seg) && strings.ContainsAny(seg[1:len(seg)-1], "{}") {
+			return fmt.Errorf("неверный параметр пути %q", seg)
+		}
+	}
+	return nil
+}
+
+func (r *Route) validateCache() error {
+	if r.Cache == nil {
+		return nil
+	}
+	if r.Cache.TTL <= 0 || r.Cache.StaleWhileRevalidate < 0 {
+		return fmt.Errorf("cache.ttl должен быть положительным")
+	}
+	// Ответы для конкретного пользователя кэшировать нельзя
+	if r.Auth != "none" {
+		return fmt.Errorf("кэширование доступно только для маршрутов без аутентификации")
+	}
+	for _, m := range r.Methods {
+		if m == http.MethodGet {
+			return nil
+		}
+	}
+	return fmt.Errorf("кэширование доступно только для GET-маршрутов")
+}
+
+func isKnownMethod(m string) bool {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
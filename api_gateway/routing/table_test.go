@@ -0,0 +1,126 @@
+package routing
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTableValidate(t *testing.T) {
+	ttl := &CacheRule{TTL: Duration(time.Second)}
+	rewrite := "/"
+	tests := []struct {
+		name    string
+		routes  []Route
+		wantErr string
+	}{
+		{
+			name:   "корректный маршрут",
+			routes: []Route{{Path: "/api/news", Methods: []string{"get"}, Upstream: "news_service", Cache: ttl}},
+		},
+		{
+			name:    "пустая таблица",
+			wantErr: "таблица маршрутов пуста",
+		},
+		{
+			name:    "путь без слеша",
+			routes:  []Route{{Path: "api/news", Methods: []string{"GET"}, Upstream: "news_service"}},
+			wantErr: "должен начинаться с /",
+		},
+		{
+			name:    "нет ни upstream, ни handler",
+			routes:  []Route{{Path: "/api/news", Methods: []string{"GET"}}},
+			wantErr: "ровно одно из upstream или handler",
+		},
+		{
+			name:    "и upstream, и handler",
+			routes:  []Route{{Path: "/api/news", Methods: []string{"GET"}, Upstream: "news_service", Handler: "news_list"}},
+			wantErr: "ровно одно из upstream или handler",
+		},
+		{
+			name:    "rewrite_prefix у handler",
+			routes:  []Route{{Path: "/api/news", Methods: []string{"GET"}, Handler: "news_list", RewritePrefix: &rewrite}},
+			wantErr: "rewrite_prefix применим только к upstream",
+		},
+		{
+			name:    "отрицательный timeout",
+			routes:  []Route{{Path: "/api/news", Methods: []string{"GET"}, Upstream: "news_service", Timeout: -1}},
+			wantErr: "отрицательный timeout",
+		},
+		{
+			name:    "нет методов",
+			routes:  []Route{{Path: "/api/news", Upstream: "news_service"}},
+			wantErr: "не указаны методы",
+		},
+		{
+			name:    "неизвестный метод",
+			routes:  []Route{{Path: "/api/news", Methods: []string{"FETCH"}, Upstream: "news_service"}},
+			wantErr: "неизвестный метод",
+		},
+		{
+			name: "метод объявлен повторно",
+			routes: []Route{
+				{Path: "/api/news", Methods: []string{"GET"}, Upstream: "news_service"},
+				{Path: "/api/news", Methods: []string{"get"}, Handler: "news_list"},
+			},
+			wantErr: "объявлен повторно",
+		},
+		{
+			name: "один путь с разными методами",
+			routes: []Route{
+				{Path: "/api/comments/", Methods: []string{"GET"}, Upstream: "comments_service"},
+				{Path: "/api/comments/", Methods: []string{"DELETE"}, Handler: "delete_comment"},
+			},
+		},
+		{
+			name:    "кэш с аутентификацией",
+			routes:  []Route{{Path: "/api/news", Methods: []string{"GET"}, Upstream: "news_service", Auth: "user", Cache: ttl}},
+			wantErr: "только для маршрутов без аутентификации",
+		},
+		{
+			name:    "кэш без GET",
+			routes:  []Route{{Path: "/api/news", Methods: []string{"POST"}, Upstream: "news_service", Cache: ttl}},
+			wantErr: "только для GET-маршрутов",
+		},
+		{
+			name:    "кэш без ttl",
+			routes:  []Route{{Path: "/api/news", Methods: []string{"GET"}, Upstream: "news_service", Cache: &CacheRule{}}},
+			wantErr: "cache.ttl должен быть положительным",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &Table{Routes: tt.routes}
+			err := table.validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("validate: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Fatalf("validate: нет ошибки, want %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Fatalf("validate: %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTableValidateDefaults(t *testing.T) {
+	table := &Table{Routes: []Route{{Path: "/api/news", Methods: []string{"get", "Head"}, Upstream: "news_service"}}}
+	if err := table.validate(); err != nil {
+		t.Fatal(err)
+	}
+	r := table.Routes[0]
+	if r.Auth != "none" {
+		t.Errorf("Auth = %q, want %q", r.Auth, "none")
+	}
+	if strings.Join(r.Methods, ",") != "GET,HEAD" {
+		t.Errorf("Methods = %v, want [GET HEAD]", r.Methods)
+	}
+}
+
+func TestLoadRoutesFile(t *testing.T) {
+	// Таблица из репозитория должна проходить проверку
+	if _, err := Load("../routes.json"); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+}
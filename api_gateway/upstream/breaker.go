@@ -0,0 +1,131 @@
+package upstream
+
+import (
+	"sync"
+	"time"
+)
+
+// State - состояние автомата защиты (circuit breaker)
+type State int
+
+const (
+	// StateClosed - запросы проходят, ошибки подсчитываются
+	StateClosed State = iota
+	// StateHalfOpen - пропускается один пробный запрос
+	StateHalfOpen
+	// StateOpen - запросы отклоняются без обращения к сервису
+	StateOpen
+)
+
+// String возвращает название состояния для логов, метрик и /health
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker размыкается после серии последовательных ошибок и через
+// заданное время пропускает пробный запрос, чтобы проверить восстановление сервиса
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	onChange  func(State)
+}
+
+// NewBreaker создает автомат защиты. onChange вызывается при каждой смене состояния
+func NewBreaker(threshold int, cooldown time.Duration, onChange func(State)) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	b := &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		onChange:  onChange,
+	}
+	if onChange != nil {
+		onChange(StateClosed)
+	}
+	return b
+}
+
+// Allow сообщает, можно ли отправить запрос в сервис
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateClosed:
+		return true
+	case StateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(StateHalfOpen)
+	}
+
+	// В полуоткрытом состоянии одновременно допускается только один пробный запрос
+	if b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// Success фиксирует успешный ответ сервиса
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != StateClosed {
+		b.setState(StateClosed)
+	}
+}
+
+// Failure фиксирует ошибку сервиса
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == StateHalfOpen || (b.state == StateClosed && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		b.setState(StateOpen)
+	}
+}
+
+// Release освобождает разрешение, выданное Allow, не влияя на счетчик ошибок
+// (например, если запрос отменил сам клиент)
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// Snapshot возвращает текущее состояние и число последовательных ошибок
+func (b *Breaker) Snapshot() (State, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.failures
+}
+
+func (b *Breaker) setState(s State) {
+	b.state = s
+	if b.onChange != nil {
+		b.onChange(s)
+	}
+}
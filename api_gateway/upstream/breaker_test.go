@@ -0,0 +1,107 @@
+package upstream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	type step struct {
+		op    string // allow, deny, success, failure, wait
+		state State
+	}
+	tests := []struct {
+		name      string
+		threshold int
+		steps     []step
+	}{
+		{
+			name:      "размыкается после серии ошибок",
+			threshold: 3,
+			steps: []step{
+				{"allow", StateClosed}, {"failure", StateClosed},
+				{"allow", StateClosed}, {"failure", StateClosed},
+				{"allow", StateClosed}, {"failure", StateOpen},
+				{"deny", StateOpen},
+			},
+		},
+		{
+			name:      "успех сбрасывает счетчик ошибок",
+			threshold: 2,
+			steps: []step{
+				{"allow", StateClosed}, {"failure", StateClosed},
+				{"allow", StateClosed}, {"success", StateClosed},
+				{"allow", StateClosed}, {"failure", StateClosed},
+			},
+		},
+		{
+			name:      "пробный запрос замыкает автомат",
+			threshold: 1,
+			steps: []step{
+				{"allow", StateClosed}, {"failure", StateOpen},
+				{"wait", StateOpen},
+				{"allow", StateHalfOpen}, {"deny", StateHalfOpen},
+				{"success", StateClosed}, {"allow", StateClosed},
+			},
+		},
+		{
+			name:      "неудачный пробный запрос снова размыкает",
+			threshold: 1,
+			steps: []step{
+				{"allow", StateClosed}, {"failure", StateOpen},
+				{"wait", StateOpen},
+				{"allow", StateHalfOpen}, {"failure", StateOpen},
+				{"deny", StateOpen},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const cooldown = 20 * time.Millisecond
+			var changes []State
+			b := NewBreaker(tt.threshold, cooldown, func(s State) { changes = append(changes, s) })
+			for i, s := range tt.steps {
+				switch s.op {
+				case "allow":
+					if !b.Allow() {
+						t.Fatalf("шаг %d: Allow() = false", i)
+					}
+				case "deny":
+					if b.Allow() {
+						t.Fatalf("шаг %d: Allow() = true", i)
+					}
+				case "success":
+					b.Success()
+				case "failure":
+					b.Failure()
+				case "wait":
+					time.Sleep(cooldown + 5*time.Millisecond)
+				}
+				if got, _ := b.Snapshot(); got != s.state {
+					t.Fatalf("шаг %d (%s): состояние %s, want %s", i, s.op, got, s.state)
+				}
+			}
+			if len(changes) == 0 || changes[0] != StateClosed {
+				t.Errorf("onChange не сообщил начальное состояние: %v", changes)
+			}
+		})
+	}
+}
+
+func TestBreakerRelease(t *testing.T) {
+	b := NewBreaker(1, 0, nil)
+	b.Failure()
+	if !b.Allow() {
+		t.Fatal("пробный запрос не пропущен")
+	}
+	if b.Allow() {
+		t.Fatal("второй пробный запрос пропущен")
+	}
+	b.Release()
+	if !b.Allow() {
+		t.Fatal("после Release пробный запрос не пропущен")
+	}
+	if state, _ := b.Snapshot(); state != StateHalfOpen {
+		t.Errorf("состояние %s, want %s", state, StateHalfOpen)
+	}
+}
@@ -0,0 +1,222 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"api_gateway/middleware"
+	"api_gateway/tracing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrCircuitOpen возвращается, когда автомат защиты сервиса разомкнут
+var ErrCircuitOpen = errors.New("автомат защиты разомкнут, сервис временно недоступен")
+
+// Config - настройки клиента для одного сервиса
+type Config struct {
+	Name             string
+	BaseURL          string
+	ConnectTimeout   time.Duration
+	ReadTimeout      time.Duration
+	RequestTimeout   time.Duration
+	MaxRetries       int
+	RetryBackoff     time.Duration
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// Metrics - метрики Prometheus, которые обновляет клиент. Любое поле может быть nil
+type Metrics struct {
+	// Requests с метками upstream и outcome
+	Requests *prometheus.CounterVec
+	// Retries с меткой upstream
+	Retries *prometheus.CounterVec
+	// State с меткой upstream: 0 - closed, 1 - half_open, 2 - open
+	State *prometheus.GaugeVec
+}
+
+// Client - HTTP-клиент сервиса с таймаутами, повторами и автоматом защиты
+type Client struct {
+	cfg     Config
+	http    *http.Client
+	breaker *Breaker
+	metrics Metrics
+}
+
+// New создает клиент для сервиса
+func New(cfg Config, metrics Metrics) *Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   cfg.ConnectTimeout,
+		ResponseHeaderTimeout: cfg.ReadTimeout,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	c := &Client{
+		cfg: cfg,
+		http: &http.Client{
+			Transport: tracing.Transport(transport),
+			Timeout:   cfg.RequestTimeout,
+		},
+		metrics: metrics,
+	}
+	c.breaker = NewBreaker(cfg.FailureThreshold, cfg.OpenTimeout, func(s State) {
+		if c.metrics.State != nil {
+			c.metrics.State.WithLabelValues(cfg.Name).Set(float64(s))
+		}
+	})
+	return c
+}
+
+// Name возвращает имя сервиса
+func (c *Client) Name() string {
+	return c.cfg.Name
+}
+
+// BaseURL возвращает базовый адрес сервиса
+func (c *Client) BaseURL() string {
+	return c.cfg.BaseURL
+}
+
+// Breaker возвращает автомат защиты сервиса
+func (c *Client) Breaker() *Breaker {
+	return c.breaker
+}
+
+// NewRequest создает запрос к сервису; path указывается относительно BaseURL.
+// ID входящего запроса из контекста передается сервису в заголовке X-Request-ID
+func (c *Client) NewRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.cfg.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if id := middleware.RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(middleware.RequestIDHeader, id)
+	}
+	return req, nil
+}
+
+// Do выполняет запрос. Идемпотентные запросы повторяются при сетевых ошибках
+// и ответах 502/503/504; ответы 5xx и сетевые ошибки учитываются автоматом защиты
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if isIdempotent(req) {
+		attempts += c.cfg.MaxRetries
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			c.incRetries()
+			if err := sleep(req.Context(), c.backoff(attempt)); err != nil {
+				return nil, err
+			}
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				req.Body = body
+			}
+		}
+
+		if !c.breaker.Allow() {
+			c.incRequests("rejected")
+			return nil, ErrCircuitOpen
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				// Запрос отменил клиент шлюза - сервис в этом не виноват
+				c.breaker.Release()
+				c.incRequests("canceled")
+				return nil, err
+			}
+			c.breaker.Failure()
+			c.incRequests("error")
+			lastErr = err
+			continue
+		}
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			c.breaker.Failure()
+			c.incRequests("server_error")
+			if attempt < attempts && isRetryableStatus(resp.StatusCode) {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				continue
+			}
+			return resp, nil
+		}
+
+		c.breaker.Success()
+		c.incRequests("success")
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	return c.cfg.RetryBackoff * time.Duration(1<<(attempt-2))
+}
+
+func (c *Client) incRequests(outcome string) {
+	if c.metrics.Requests != nil {
+		c.metrics.Requests.WithLabelValues(c.cfg.Name, outcome).Inc()
+	}
+}
+
+func (c *Client) incRetries() {
+	if c.metrics.Retries != nil {
+		c.metrics.Retries.WithLabelValues(c.cfg.Name).Inc()
+	}
+}
+
+// isIdempotent сообщает, можно ли безопасно повторить запрос
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	default:
+		return false
+	}
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusBadGateway ||
+		code == http.StatusServiceUnavailable ||
+		code == http.StatusGatewayTimeout
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// RoundTrip реализует http.RoundTripper, чтобы клиент можно было
+// использовать как транспорт обратного прокси
+func (c *Client) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Входящий запрос прокси несет RequestURI, который http.Client не принимает
+	if req.RequestURI != "" {
+		req = req.Clone(req.Context())
+		req.RequestURI = ""
+	}
+	return c.Do(req)
+}
@@ -0,0 +1,106 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(url string, retries, threshold int) *Client {
+	return New(Config{
+		Name:             "test",
+		BaseURL:          url,
+		ConnectTimeout:   time.Second,
+		ReadTimeout:      time.Second,
+		RequestTimeout:   time.Second,
+		MaxRetries:       retries,
+		RetryBackoff:     time.Millisecond,
+		FailureThreshold: threshold,
+		OpenTimeout:      time.Minute,
+	}, Metrics{})
+}
+
+func TestClientRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		statuses []int // ответы сервиса по порядку, дальше - 200
+		want     int
+		calls    int32
+	}{
+		{name: "GET повторяется при 503", method: http.MethodGet, statuses: []int{503, 503}, want: 200, calls: 3},
+		{name: "GET не повторяется больше MaxRetries", method: http.MethodGet, statuses: []int{502, 502, 502, 502}, want: 502, calls: 3},
+		{name: "GET не повторяется при 500", method: http.MethodGet, statuses: []int{500}, want: 500, calls: 1},
+		{name: "GET не повторяется при 404", method: http.MethodGet, statuses: []int{404}, want: 404, calls: 1},
+		{name: "POST не повторяется", method: http.MethodPost, body: "{}", statuses: []int{503}, want: 503, calls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(calls.Add(1))
+				if n <= len(tt.statuses) {
+					w.WriteHeader(tt.statuses[n-1])
+					return
+				}
+				io.WriteString(w, "ok")
+			}))
+			defer srv.Close()
+
+			c := newTestClient(srv.URL, 2, 100)
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req, err := c.NewRequest(context.Background(), tt.method, "/", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("статус %d, want %d", resp.StatusCode, tt.want)
+			}
+			if got := calls.Load(); got != tt.calls {
+				t.Errorf("запросов к сервису %d, want %d", got, tt.calls)
+			}
+		})
+	}
+}
+
+func TestClientCircuitOpen(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c := newTestClient(srv.URL, 0, 2)
+	for i := 0; i < 2; i++ {
+		req, _ := c.NewRequest(context.Background(), http.MethodGet, "/", nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("запрос %d: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+
+	req, _ := c.NewRequest(context.Background(), http.MethodGet, "/", nil)
+	if _, err := c.Do(req); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Do после серии ошибок: %v, want ErrCircuitOpen", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("запросов к сервису %d, want 2", got)
+	}
+}
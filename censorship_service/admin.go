@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"censorship_service/middleware"
+	"censorship_service/rules"
+
+	"github.com/sirupsen/logrus"
+)
+
+// roleAdmin - роль, которой доступно управление правилами (заголовок X-User-Role от шлюза)
+const roleAdmin = "admin"
+
+// Ограничения журнала изменений
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// RulesResponse - действующий набор правил и его версия
+type RulesResponse struct {
+	Version int        `json:"version"`
+	Rules   *rules.Set `json:"rules"`
+}
+
+// WordsRequest - тело запроса добавления и удаления слов правила
+type WordsRequest struct {
+	Words []string `json:"words"`
+}
+
+// requireAdmin проверяет роль пользователя. Шлюз тоже проверяет роль,
+// но сервис не полагается только на него
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("X-User-Role") != roleAdmin {
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// changeFromRequest описывает изменение: автор из заголовков X-User-*,
+// пояснение из параметра comment
+func changeFromRequest(r *http.Request, action, rule string) rules.Change {
+	return rules.Change{
+		UserID:   r.Header.Get("X-User-ID"),
+		UserName: r.Header.Get("X-User-Name"),
+		Action:   action,
+		Rule:     rule,
+		Comment:  r.URL.Query().Get("comment"),
+	}
+}
+
+// handleRules - /api/censorship/rules: GET - действующий набор, PUT - замена
+// набора целиком, POST - новое правило
+func handleRules(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeRules(w, ruleStore.Rules())
+	case http.MethodPut:
+		var set rules.Set
+		if err := decodeStrict(r, &set); err != nil {
+			http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+			return
+		}
+		updateRules(w, r, changeFromRequest(r, "replace", ""), func(s *rules.Set) error {
+			*s = set
+			return nil
+		})
+	case http.MethodPost:
+		var rule rules.Rule
+		if err := decodeStrict(r, &rule); err != nil {
+			http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+			return
+		}
+		updateRules(w, r, changeFromRequest(r, "create_rule", rule.Name), func(s *rules.Set) error {
+			return s.AddRule(rule)
+		})
+	default:
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRule - /api/censorship/rules/{name}: GET, PUT, DELETE правила;
+// /api/censorship/rules/{name}/words: POST добавляет слова, DELETE удаляет
+func handleRule(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/censorship/rules/"), "/")
+	name := parts[0]
+	if name == "" || len(parts) > 2 || (len(parts) == 2 && parts[1] != "words") {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(parts) == 2 {
+		var req WordsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Words) == 0 {
+			http.Error(w, "Требуется непустой список words", http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			updateRules(w, r, changeFromRequest(r, "add_words", name), func(s *rules.Set) error {
+				return s.AddWords(name, req.Words)
+			})
+		case http.MethodDelete:
+			updateRules(w, r, changeFromRequest(r, "remove_words", name), func(s *rules.Set) error {
+				return s.RemoveWords(name, req.Words)
+			})
+		default:
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rule := ruleStore.Rules().Find(name)
+		if rule == nil {
+			http.Error(w, rules.ErrRuleNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+	case http.MethodPut:
+		var rule rules.Rule
+		if err := decodeStrict(r, &rule); err != nil {
+			http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+			return
+		}
+		if rule.Name == "" {
+			rule.Name = name
+		}
+		updateRules(w, r, changeFromRequest(r, "update_rule", name), func(s *rules.Set) error {
+			return s.ReplaceRule(name, rule)
+		})
+	case http.MethodDelete:
+		updateRules(w, r, changeFromRequest(r, "delete_rule", name), func(s *rules.Set) error {
+			return s.DeleteRule(name)
+		})
+	default:
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleVersions - GET /api/censorship/versions: сохраненные версии, новые первыми
+func handleVersions(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	versions, err := ruleStore.History().Versions()
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка чтения истории правил")
+		http.Error(w, "Ошибка чтения истории правил", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// handleVersion - GET /api/censorship/versions/{n}: версия с набором правил;
+// POST /api/censorship/versions/{n}/activate: вернуть версию (сохраняется как новая)
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/censorship/versions/"), "/")
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "activate") {
+		http.NotFound(w, r)
+		return
+	}
+
+	version, err := ruleStore.History().Version(n)
+	if errors.Is(err, rules.ErrVersionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка чтения истории правил")
+		http.Error(w, "Ошибка чтения истории правил", http.StatusInternalServerError)
+		return
+	}
+
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(version)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	set, err := rules.Parse(version.Rules)
+	if err != nil {
+		http.Error(w, "Версия не проходит проверку: "+err.Error(), http.StatusConflict)
+		return
+	}
+	change := changeFromRequest(r, "activate", "")
+	if change.Comment == "" {
+		change.Comment = "возврат к версии " + strconv.Itoa(n)
+	}
+	updateRules(w, r, change, func(s *rules.Set) error {
+		*s = *set
+		return nil
+	})
+}
+
+// handleAudit - GET /api/censorship/audit?limit=100: журнал изменений, новые первыми
+func handleAudit(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultAuditLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxAuditLimit {
+			http.Error(w, "limit должен быть от 1 до "+strconv.Itoa(maxAuditLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	entries, err := ruleStore.History().Audit(limit)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка чтения журнала правил")
+		http.Error(w, "Ошибка чтения журнала правил", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// updateRules применяет изменение к набору правил и возвращает новый набор
+func updateRules(w http.ResponseWriter, r *http.Request, change rules.Change, fn func(*rules.Set) error) {
+	set, err := ruleStore.Update(change, fn)
+	var (
+		invalid    *rules.ValidationError
+		historyErr *rules.HistoryError
+	)
+	switch {
+	case errors.Is(err, rules.ErrRuleNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, rules.ErrRuleExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.As(err, &invalid):
+		http.Error(w, invalid.Error(), http.StatusBadRequest)
+		return
+	case errors.As(err, &historyErr):
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка сохранения версии правил")
+		http.Error(w, "Версия не записана в историю, правила не изменены", http.StatusInternalServerError)
+		return
+	case err != nil:
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка сохранения правил")
+		http.Error(w, "Ошибка сохранения правил", http.StatusInternalServerError)
+		return
+	}
+
+	middleware.LoggerFromContext(r.Context()).WithFields(logrus.Fields{
+		"action":  change.Action,
+		"rule":    change.Rule,
+		"user":    change.UserName,
+		"version": ruleStore.Version(),
+	}).Info("Правила цензуры изменены")
+	writeRules(w, set)
+}
+
+func writeRules(w http.ResponseWriter, set *rules.Set) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RulesResponse{Version: ruleStore.Version(), Rules: set})
+}
+
+// decodeStrict разбирает тело запроса, не допуская неизвестных полей
+func decodeStrict(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"censorship_service/rules"
+)
+
+const adminTestRules = `{"rules": [{"name": "profanity", "words": ["блин"], "action": "reject", "reason": "Грубость"}]}`
+
+// useTestHistory подменяет профиль comments набором adminTestRules с историей
+// версий и возвращает каталог истории
+func useTestHistory(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(path, []byte(adminTestRules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	history, err := rules.OpenHistory(filepath.Join(dir, "history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := rules.NewStore(path, history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := profiles[profileComments]
+	profiles[profileComments], ruleStore = store, store
+	t.Cleanup(func() { profiles[profileComments], ruleStore = old, old })
+	return filepath.Join(dir, "history")
+}
+
+// admin выполняет запрос от пользователя с ролью role
+func admin(h http.HandlerFunc, method, path, body, role string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-User-ID", "1")
+	req.Header.Set("X-User-Name", "root")
+	if role != "" {
+		req.Header.Set("X-User-Role", role)
+	}
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec
+}
+
+func TestAdminRequiresAdmin(t *testing.T) {
+	useTestHistory(t)
+
+	tests := []struct {
+		name   string
+		h      http.HandlerFunc
+		method string
+		path   string
+		body   string
+	}{
+		{name: "замена набора", h: handleRules, method: http.MethodPut, path: "/api/censorship/rules", body: `{"rules": []}`},
+		{name: "добавление слов", h: handleRule, method: http.MethodPost, path: "/api/censorship/rules/profanity/words", body: `{"words": ["дурак"]}`},
+		{name: "список версий", h: handleVersions, method: http.MethodGet, path: "/api/censorship/versions"},
+		{name: "возврат версии", h: handleVersion, method: http.MethodPost, path: "/api/censorship/versions/1/activate"},
+		{name: "журнал", h: handleAudit, method: http.MethodGet, path: "/api/censorship/audit"},
+		{name: "пробный прогон", h: handleDryRun, method: http.MethodPost, path: "/api/censorship/dry-run", body: adminTestRules},
+	}
+	for _, tt := range tests {
+		for _, role := range []string{"", "user", "moderator"} {
+			t.Run(tt.name+" - "+role, func(t *testing.T) {
+				if rec := admin(tt.h, tt.method, tt.path, tt.body, role); rec.Code != http.StatusForbidden {
+					t.Errorf("статус %d, want 403", rec.Code)
+				}
+			})
+		}
+	}
+	if v := ruleStore.Version(); v != 1 {
+		t.Errorf("версия %d, want 1: набор изменен без прав", v)
+	}
+}
+
+func TestRollbackToVersion(t *testing.T) {
+	useTestHistory(t)
+
+	rec := admin(handleRule, http.MethodPost, "/api/censorship/rules/profanity/words", `{"words": ["дурак"]}`, roleAdmin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("добавление слов: статус %d: %s", rec.Code, rec.Body)
+	}
+	if resp := censor(t, "ты дурак", ""); resp.Status != statusRejected {
+		t.Fatalf("после добавления слова: статус %s, want rejected", resp.Status)
+	}
+
+	rec = admin(handleVersions, http.MethodGet, "/api/censorship/versions", "", roleAdmin)
+	var versions []rules.VersionInfo
+	if err := json.NewDecoder(rec.Body).Decode(&versions); err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Number != 2 || versions[0].Action != "add_words" {
+		t.Fatalf("версии %+v, want 2 версии, новая первой", versions)
+	}
+
+	rec = admin(handleVersion, http.MethodPost, "/api/censorship/versions/1/activate", "", roleAdmin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("возврат: статус %d: %s", rec.Code, rec.Body)
+	}
+	var resp RulesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	// Возврат сохраняется новой версией с набором первой
+	if resp.Version != 3 || ruleStore.Version() != 3 {
+		t.Errorf("версия после возврата %d (в хранилище %d), want 3", resp.Version, ruleStore.Version())
+	}
+	if got := censor(t, "ты дурак", ""); got.Status != statusApproved {
+		t.Errorf("после возврата: статус %s, want approved", got.Status)
+	}
+	if got := censor(t, "блин", ""); got.Status != statusRejected {
+		t.Errorf("слово первой версии: статус %s, want rejected", got.Status)
+	}
+
+	rec = admin(handleAudit, http.MethodGet, "/api/censorship/audit?limit=1", "", roleAdmin)
+	var audit []rules.AuditEntry
+	if err := json.NewDecoder(rec.Body).Decode(&audit); err != nil {
+		t.Fatal(err)
+	}
+	if len(audit) != 1 || audit[0].Action != "activate" || audit[0].Comment != "возврат к версии 1" || audit[0].UserName != "root" {
+		t.Errorf("журнал %+v, want возврат к версии 1 от root", audit)
+	}
+}
+
+func TestVersionErrors(t *testing.T) {
+	useTestHistory(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{name: "версия", method: http.MethodGet, path: "/api/censorship/versions/1", want: 200},
+		{name: "нет версии", method: http.MethodPost, path: "/api/censorship/versions/99/activate", want: 404},
+		{name: "неверный номер", method: http.MethodGet, path: "/api/censorship/versions/x", want: 404},
+		{name: "неизвестное действие", method: http.MethodPost, path: "/api/censorship/versions/1/delete", want: 404},
+		{name: "возврат только POST", method: http.MethodGet, path: "/api/censorship/versions/1/activate", want: 405},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := admin(handleVersion, tt.method, tt.path, "", roleAdmin); rec.Code != tt.want {
+				t.Errorf("статус %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollbackHistoryFailure(t *testing.T) {
+	dir := useTestHistory(t)
+	admin(handleRule, http.MethodPost, "/api/censorship/rules/profanity/words", `{"words": ["дурак"]}`, roleAdmin)
+
+	// Журнал недоступен для записи: возврат не применяется
+	audit := filepath.Join(dir, "audit.jsonl")
+	if err := os.Remove(audit); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(audit, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := admin(handleVersion, http.MethodPost, "/api/censorship/versions/1/activate", "", roleAdmin)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("статус %d, want 500", rec.Code)
+	}
+	if v := ruleStore.Version(); v != 2 {
+		t.Errorf("версия %d, want 2", v)
+	}
+	if resp := censor(t, "ты дурак", ""); resp.Status != statusRejected {
+		t.Errorf("статус %s, want rejected: действует набор версии 1", resp.Status)
+	}
+}
+
+func TestDryRunHistoricalVersion(t *testing.T) {
+	useTestHistory(t)
+	admin(handleRule, http.MethodPost, "/api/censorship/rules/profanity/words", `{"words": ["дурак"]}`, roleAdmin)
+	fakeCommentsService(t, []RecentComment{
+		{ID: 1, NewsID: 10, Content: "ты дурак"},
+		{ID: 2, NewsID: 10, Content: "блин"},
+		{ID: 3, NewsID: 11, Content: "хорошая статья"},
+	})
+
+	// Набор первой версии проверяется на последних комментариях до возврата
+	rec := admin(handleVersion, http.MethodGet, "/api/censorship/versions/1", "", roleAdmin)
+	var v1 rules.Version
+	if err := json.NewDecoder(rec.Body).Decode(&v1); err != nil {
+		t.Fatal(err)
+	}
+	rec = admin(handleDryRun, http.MethodPost, "/api/censorship/dry-run?limit=10", string(v1.Rules), roleAdmin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("статус %d: %s", rec.Code, rec.Body)
+	}
+	var resp DryRunResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Version != 2 || resp.Checked != 3 || resp.Changed != 1 {
+		t.Errorf("ответ %+v, want version=2 checked=3 changed=1", resp)
+	}
+	if resp.Current[statusRejected] != 2 || resp.Proposed[statusRejected] != 1 || resp.Proposed[statusApproved] != 2 {
+		t.Errorf("current=%v proposed=%v", resp.Current, resp.Proposed)
+	}
+	if len(resp.Changes) != 1 || resp.Changes[0].CommentID != 1 ||
+		resp.Changes[0].Current != statusRejected || resp.Changes[0].Proposed != statusApproved {
+		t.Errorf("изменения %+v, want комментарий 1: rejected -> approved", resp.Changes)
+	}
+
+	// Пробный прогон набор не применяет
+	if v := ruleStore.Version(); v != 2 {
+		t.Errorf("версия %d, want 2", v)
+	}
+}
+
+func TestDryRunErrors(t *testing.T) {
+	useTestHistory(t)
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+	}))
+	defer down.Close()
+	old := commentsServiceURL
+	commentsServiceURL = down.URL
+	defer func() { commentsServiceURL = old }()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{name: "метод", method: http.MethodGet, path: "/api/censorship/dry-run", want: 405},
+		{name: "неверный limit", method: http.MethodPost, path: "/api/censorship/dry-run?limit=0", body: adminTestRules, want: 400},
+		{name: "неверный набор", method: http.MethodPost, path: "/api/censorship/dry-run", body: `{"rules": [{"name": ""}]}`, want: 400},
+		{name: "сервис комментариев отказал", method: http.MethodPost, path: "/api/censorship/dry-run", body: adminTestRules, want: 502},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := admin(handleDryRun, tt.method, tt.path, tt.body, roleAdmin); rec.Code != tt.want {
+				t.Errorf("статус %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+
+	"censorship_service/middleware"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Ограничения пакетной проверки
+const (
+	maxBatchSize  = 1000
+	maxBatchBytes = 16 << 20
+)
+
+// BatchRequest - тексты для пакетной проверки
+type BatchRequest struct {
+	Texts []string `json:"texts"`
+}
+
+// BatchResponse - вердикты в порядке текстов запроса и версия правил,
+// которыми они вынесены
+type BatchResponse struct {
+	Version int              `json:"version"`
+	Results []CensorResponse `json:"results"`
+	// Summary - число вердиктов каждого статуса
+	Summary map[string]int `json:"summary"`
+}
+
+// handleCensorBatch - POST /api/censor/batch?profile=comments: проверяет до maxBatchSize текстов
+// параллельно. Ответ всегда с кодом 200, статус у каждого вердикта свой.
+// Отпечатки текстов не запоминаются: пакетами перепроверяют старые комментарии,
+// и их повторы не должны влиять на новые
+func handleCensorBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile, store, ok := profileFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes)).Decode(&req); err != nil {
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+		return
+	}
+	if len(req.Texts) == 0 || len(req.Texts) > maxBatchSize {
+		http.Error(w, fmt.Sprintf("texts должен содержать от 1 до %d текстов", maxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	// Весь пакет проверяется одним набором, даже если правила меняются во время проверки
+	set := store.Rules()
+	resp := BatchResponse{
+		Version: store.Version(),
+		Results: make([]CensorResponse, len(req.Texts)),
+		Summary: map[string]int{},
+	}
+	opts := checkOptions(profile)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(req.Texts)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				_, resp.Results[i] = censorText(set, req.Texts[i], opts)
+			}
+		}()
+	}
+	for i := range req.Texts {
+		if r.Context().Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if err := r.Context().Err(); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Warn("Пакетная проверка прервана")
+		return
+	}
+
+	for _, res := range resp.Results {
+		resp.Summary[res.Status]++
+	}
+	middleware.LoggerFromContext(r.Context()).WithFields(logrus.Fields{
+		"profile":  profile,
+		"texts":    len(req.Texts),
+		"rejected": resp.Summary[statusRejected],
+	}).Info("Пакетная проверка завершена")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"censorship_service/rules"
+)
+
+// useTestRules подменяет профиль comments набором правил из data
+func useTestRules(t *testing.T, data string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := rules.NewStore(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := profiles[profileComments]
+	profiles[profileComments], ruleStore = store, store
+	t.Cleanup(func() { profiles[profileComments], ruleStore = old, old })
+}
+
+func postBatch(body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/censor/batch", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handleCensorBatch(rec, req)
+	return rec
+}
+
+func TestCensorBatchOrder(t *testing.T) {
+	useTestRules(t, `{"rules": [{"name": "profanity", "words": ["блин"], "action": "reject", "reason": "Грубость"}]}`)
+
+	// Текстов больше, чем обработчиков, чтобы вердикты приходили не по порядку
+	var texts, want []string
+	for i := 0; i < 500; i++ {
+		if i%3 == 0 {
+			texts = append(texts, fmt.Sprintf("блин %d", i))
+			want = append(want, statusRejected)
+		} else {
+			texts = append(texts, fmt.Sprintf("текст %d", i))
+			want = append(want, statusApproved)
+		}
+	}
+	texts[1] = " "
+	want[1] = statusRejected
+	body, _ := json.Marshal(BatchRequest{Texts: texts})
+
+	rec := postBatch(string(body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("статус %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp BatchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != len(texts) {
+		t.Fatalf("вердиктов %d, want %d", len(resp.Results), len(texts))
+	}
+	rejected := 0
+	for i, res := range resp.Results {
+		if res.Status != want[i] {
+			t.Fatalf("вердикт %d (%q): %s, want %s", i, texts[i], res.Status, want[i])
+		}
+		if res.Status == statusRejected {
+			rejected++
+		}
+	}
+	if resp.Summary[statusRejected] != rejected || resp.Summary[statusApproved] != len(texts)-rejected {
+		t.Errorf("Summary = %v, want rejected=%d approved=%d", resp.Summary, rejected, len(texts)-rejected)
+	}
+}
+
+func TestCensorBatchErrors(t *testing.T) {
+	useTestRules(t, `{"rules": [{"name": "profanity", "words": ["блин"], "action": "reject", "reason": "Грубость"}]}`)
+
+	tooMany, _ := json.Marshal(BatchRequest{Texts: make([]string, maxBatchSize+1)})
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{name: "не POST", method: http.MethodGet, target: "/api/censor/batch", want: http.StatusMethodNotAllowed},
+		{name: "неизвестный профиль", method: http.MethodPost, target: "/api/censor/batch?profile=x", body: `{"texts": ["a"]}`, want: http.StatusBadRequest},
+		{name: "не JSON", method: http.MethodPost, target: "/api/censor/batch", body: "{", want: http.StatusBadRequest},
+		{name: "пустой пакет", method: http.MethodPost, target: "/api/censor/batch", body: `{"texts": []}`, want: http.StatusBadRequest},
+		{name: "слишком большой пакет", method: http.MethodPost, target: "/api/censor/batch", body: string(tooMany), want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handleCensorBatch(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Errorf("статус %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Package classifier - локальные модели оценки оскорбительности текста для
+// сервиса цензуры. Модели обучаются заранее и работают без внешних сервисов
+package classifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"censorship_service/rules"
+)
+
+// modelKind - тип модели в файле
+const modelKind = "naive_bayes"
+
+// NaiveBayes - наивный байесовский классификатор по основам слов.
+// Слово учитывается в тексте один раз, сколько бы раз оно ни встречалось
+type NaiveBayes struct {
+	Kind string `json:"kind"`
+	// ToxicDocs и CleanDocs - число оскорбительных и обычных примеров
+	ToxicDocs int `json:"toxic_docs"`
+	CleanDocs int `json:"clean_docs"`
+	// Toxic и Clean - в скольких примерах каждого класса встретилась основа
+	Toxic map[string]int `json:"toxic"`
+	Clean map[string]int `json:"clean"`
+
+	toxicTotal, cleanTotal int
+	vocabulary             int
+}
+
+var _ rules.Classifier = (*NaiveBayes)(nil)
+
+// Load читает модель из JSON-файла
+func Load(path string) (*NaiveBayes, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m NaiveBayes
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("ошибка в %s: %v", path, err)
+	}
+	if m.Kind != modelKind {
+		return nil, fmt.Errorf("ошибка в %s: неизвестный тип модели %q", path, m.Kind)
+	}
+	if m.ToxicDocs <= 0 || m.CleanDocs <= 0 {
+		return nil, fmt.Errorf("ошибка в %s: нужны примеры обоих классов", path)
+	}
+	m.init()
+	return &m, nil
+}
+
+// Save записывает модель в JSON-файл
+func (m *NaiveBayes) Save(path string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Vocabulary возвращает число различных основ в модели
+func (m *NaiveBayes) Vocabulary() int {
+	return m.vocabulary
+}
+
+// Toxicity возвращает вероятность того, что текст оскорбительный. Основы,
+// которых нет в модели, не учитываются; если известных основ нет совсем,
+// возвращается ok=false
+func (m *NaiveBayes) Toxicity(text string) (float64, bool) {
+	odds := math.Log(float64(m.ToxicDocs)) - math.Log(float64(m.CleanDocs))
+	known := false
+	for stem := range uniqueStems(text) {
+		toxic, clean := m.Toxic[stem], m.Clean[stem]
+		if toxic == 0 && clean == 0 {
+			continue
+		}
+		known = true
+		// Сглаживание Лапласа
+		odds += math.Log(float64(toxic+1)/float64(m.toxicTotal+m.vocabulary)) -
+			math.Log(float64(clean+1)/float64(m.cleanTotal+m.vocabulary))
+	}
+	return 1 / (1 + math.Exp(-odds)), known
+}
+
+// init считает суммы, нужные для оценки
+func (m *NaiveBayes) init() {
+	if m.Toxic == nil {
+		m.Toxic = map[string]int{}
+	}
+	if m.Clean == nil {
+		m.Clean = map[string]int{}
+	}
+	m.toxicTotal, m.cleanTotal = 0, 0
+	vocabulary := map[string]bool{}
+	for s, n := range m.Toxic {
+		m.toxicTotal += n
+		vocabulary[s] = true
+	}
+	for s, n := range m.Clean {
+		m.cleanTotal += n
+		vocabulary[s] = true
+	}
+	m.vocabulary = len(vocabulary)
+}
+
+func uniqueStems(text string) map[string]bool {
+	stems := map[string]bool{}
+	for _, s := range rules.Stems(text) {
+		stems[s] = true
+	}
+	return stems
+}
@@ -0,0 +1,195 @@
+package classifier
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var examples = []Example{
+	{Text: "ты тупой идиот", Toxic: true},
+	{Text: "идиоты и дебилы пишут", Toxic: true},
+	{Text: "автор тупой", Toxic: true},
+	{Text: "спасибо за статью", Toxic: false},
+	{Text: "интересная статья, спасибо автору", Toxic: false},
+	{Text: "хорошая новость", Toxic: false},
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []Example
+		wantErr string
+	}{
+		{
+			name: "с заголовком",
+			data: "text,label\n\"привет, мир\",clean\nидиот,toxic\n",
+			want: []Example{{Text: "привет, мир"}, {Text: "идиот", Toxic: true}},
+		},
+		{
+			name: "без заголовка, числовые метки",
+			data: "привет,0\nидиот,1\n",
+			want: []Example{{Text: "привет"}, {Text: "идиот", Toxic: true}},
+		},
+		{
+			name:    "неизвестная метка",
+			data:    "привет,0\nидиот,maybe\n",
+			wantErr: "неизвестная метка",
+		},
+		{
+			name:    "лишняя колонка",
+			data:    "привет,0,1\n",
+			wantErr: "wrong number of fields",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadCSV(strings.NewReader(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ошибка %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("примеров %d, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("пример %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTrain(t *testing.T) {
+	m, err := Train(examples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ToxicDocs != 3 || m.CleanDocs != 3 {
+		t.Errorf("примеров %d/%d, want 3/3", m.ToxicDocs, m.CleanDocs)
+	}
+	// Основа учитывается в примере один раз
+	if m.Toxic["туп"] != 2 || m.Clean["стат"] != 2 {
+		t.Errorf("Toxic[туп] = %d, Clean[стат] = %d, want 2 и 2", m.Toxic["туп"], m.Clean["стат"])
+	}
+
+	if _, err := Train(examples[:3]); err == nil {
+		t.Error("Train без обычных примеров: нет ошибки")
+	}
+}
+
+func TestToxicity(t *testing.T) {
+	m, err := Train(examples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text      string
+		wantOK    bool
+		wantToxic bool // p >= 0.5, проверяется только при ok
+	}{
+		{text: "какой тупой идиот", wantOK: true, wantToxic: true},
+		{text: "Идиоты!", wantOK: true, wantToxic: true},
+		{text: "спасибо, хорошая статья", wantOK: true, wantToxic: false},
+		// Без известных слов остается только априорная вероятность
+		{text: "погода завтра", wantOK: false},
+		{text: "", wantOK: false},
+	}
+	for _, tt := range tests {
+		p, ok := m.Toxicity(tt.text)
+		if ok != tt.wantOK || ok && (p >= 0.5) != tt.wantToxic {
+			t.Errorf("Toxicity(%q) = %.2f, %v, want toxic=%v ok=%v", tt.text, p, ok, tt.wantToxic, tt.wantOK)
+		}
+		if p < 0 || p > 1 {
+			t.Errorf("Toxicity(%q) = %v вне [0, 1]", tt.text, p)
+		}
+	}
+}
+
+func TestAccuracy(t *testing.T) {
+	m, err := Train(examples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Accuracy(examples); got != 1 {
+		t.Errorf("Accuracy на обучающих примерах = %v, want 1", got)
+	}
+	// Текст без известных слов считается обычным
+	unknown := []Example{{Text: "погода завтра"}, {Text: "погода завтра", Toxic: true}}
+	if got := m.Accuracy(unknown); got != 0.5 {
+		t.Errorf("Accuracy = %v, want 0.5", got)
+	}
+	if got := m.Accuracy(nil); got != 0 {
+		t.Errorf("Accuracy(nil) = %v, want 0", got)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	m, err := Train(examples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Vocabulary() != m.Vocabulary() {
+		t.Errorf("Vocabulary = %d, want %d", loaded.Vocabulary(), m.Vocabulary())
+	}
+	for _, text := range []string{"тупой идиот", "спасибо за статью"} {
+		want, _ := m.Toxicity(text)
+		if got, _ := loaded.Toxicity(text); got != want {
+			t.Errorf("Toxicity(%q) после загрузки = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "не JSON", data: "{", wantErr: "ошибка в"},
+		{name: "другой тип", data: `{"kind": "svm", "toxic_docs": 1, "clean_docs": 1}`, wantErr: "неизвестный тип модели"},
+		{name: "один класс", data: `{"kind": "naive_bayes", "toxic_docs": 1}`, wantErr: "нужны примеры обоих классов"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "model.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load: %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSampleCorpus(t *testing.T) {
+	// Пример корпуса из репозитория должен читаться и обучать модель
+	examples, err := ReadCSVFile("../corpus/toxicity_sample.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Train(examples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Accuracy(examples); got < 0.9 {
+		t.Errorf("Accuracy на примере корпуса = %.2f, want >= 0.9", got)
+	}
+}
@@ -0,0 +1,105 @@
+package classifier
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Example - размеченный пример для обучения
+type Example struct {
+	Text  string
+	Toxic bool
+}
+
+// ReadCSV читает размеченные примеры из CSV с колонками text,label.
+// Метка: 1, toxic или true - оскорбительный текст; 0, clean или false - обычный.
+// Первая строка пропускается, если это заголовок
+func ReadCSV(r io.Reader) ([]Example, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	var examples []Example
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		toxic, ok := parseLabel(record[1])
+		if !ok {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("строка %d: неизвестная метка %q", line, record[1])
+		}
+		examples = append(examples, Example{Text: record[0], Toxic: toxic})
+	}
+	return examples, nil
+}
+
+// ReadCSVFile читает размеченные примеры из файла
+func ReadCSVFile(path string) ([]Example, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	examples, err := ReadCSV(f)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка в %s: %v", path, err)
+	}
+	return examples, nil
+}
+
+func parseLabel(label string) (toxic, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(label)) {
+	case "1", "toxic", "true":
+		return true, true
+	case "0", "clean", "false":
+		return false, true
+	}
+	return false, false
+}
+
+// Train обучает модель на примерах. Нужны примеры обоих классов
+func Train(examples []Example) (*NaiveBayes, error) {
+	m := &NaiveBayes{Kind: modelKind, Toxic: map[string]int{}, Clean: map[string]int{}}
+	for _, e := range examples {
+		counts := m.Clean
+		if e.Toxic {
+			m.ToxicDocs++
+			counts = m.Toxic
+		} else {
+			m.CleanDocs++
+		}
+		for stem := range uniqueStems(e.Text) {
+			counts[stem]++
+		}
+	}
+	if m.ToxicDocs == 0 || m.CleanDocs == 0 {
+		return nil, fmt.Errorf("нужны примеры обоих классов: оскорбительных %d, обычных %d", m.ToxicDocs, m.CleanDocs)
+	}
+	m.init()
+	return m, nil
+}
+
+// Accuracy возвращает долю примеров, которые модель относит к верному классу
+// при пороге 0.5. Текст без известных модели слов считается обычным
+func (m *NaiveBayes) Accuracy(examples []Example) float64 {
+	if len(examples) == 0 {
+		return 0
+	}
+	correct := 0
+	for _, e := range examples {
+		p, ok := m.Toxicity(e.Text)
+		if (ok && p >= 0.5) == e.Toxic {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(examples))
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"censorship_service/middleware"
+	"censorship_service/rules"
+	"censorship_service/tracing"
+)
+
+// Размер выборки комментариев для пробного прогона
+const (
+	defaultDryRunLimit = 500
+	maxDryRunLimit     = 5000
+)
+
+// maxDryRunChanges - сколько комментариев с изменившимся вердиктом попадает в ответ
+const maxDryRunChanges = 100
+
+// commentsServiceURL - адрес сервиса комментариев, откуда берутся комментарии для пробного прогона
+var commentsServiceURL string
+
+// internalToken - общий секрет с сервисом комментариев (INTERNAL_TOKEN),
+// передается в заголовке X-Internal-Token
+var internalToken string
+
+// commentsClient - клиент сервиса комментариев со спанами и заголовком traceparent
+var commentsClient = &http.Client{Timeout: 10 * time.Second, Transport: tracing.Transport(http.DefaultTransport)}
+
+// RecentComment - комментарий из сервиса комментариев
+type RecentComment struct {
+	ID      int    `json:"id"`
+	NewsID  int    `json:"news_id"`
+	Content string `json:"content"`
+}
+
+// DryRunChange - комментарий, вердикт которого меняется с новым набором правил
+type DryRunChange struct {
+	CommentID int    `json:"comment_id"`
+	NewsID    int    `json:"news_id"`
+	Content   string `json:"content"`
+	Current   string `json:"current"`
+	Proposed  string `json:"proposed"`
+	// Rule - правило, определившее новый вердикт
+	Rule string `json:"rule,omitempty"`
+}
+
+// DryRunResponse - итог проверки последних комментариев действующим и
+// предложенным наборами правил
+type DryRunResponse struct {
+	Version  int            `json:"version"`
+	Checked  int            `json:"checked"`
+	Current  map[string]int `json:"current"`
+	Proposed map[string]int `json:"proposed"`
+	// Changed - сколько вердиктов изменится; Changes - первые из них
+	Changed int            `json:"changed"`
+	Changes []DryRunChange `json:"changes"`
+}
+
+// handleDryRun - POST /api/censorship/dry-run?limit=500: проверяет последние
+// комментарии предложенным набором правил (тело запроса) и сравнивает
+// с действующим. Набор не применяется
+func handleDryRun(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultDryRunLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxDryRunLimit {
+			http.Error(w, fmt.Sprintf("limit должен быть от 1 до %d", maxDryRunLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+		return
+	}
+	proposed, err := rules.Parse(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	comments, err := fetchRecentComments(r.Context(), limit)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка получения комментариев")
+		http.Error(w, "Ошибка получения комментариев", http.StatusBadGateway)
+		return
+	}
+
+	current := ruleStore.Rules()
+	resp := DryRunResponse{
+		Version:  ruleStore.Version(),
+		Checked:  len(comments),
+		Current:  map[string]int{},
+		Proposed: map[string]int{},
+		Changes:  []DryRunChange{},
+	}
+	opts := checkOptions(profileComments)
+	for _, c := range comments {
+		before := verdictStatus(current.CheckWith(c.Content, opts))
+		after := proposed.CheckWith(c.Content, opts)
+		resp.Current[before]++
+		resp.Proposed[verdictStatus(after)]++
+		if before == verdictStatus(after) {
+			continue
+		}
+		resp.Changed++
+		if len(resp.Changes) < maxDryRunChanges {
+			resp.Changes = append(resp.Changes, DryRunChange{
+				CommentID: c.ID,
+				NewsID:    c.NewsID,
+				Content:   c.Content,
+				Current:   before,
+				Proposed:  verdictStatus(after),
+				Rule:      after.Rule,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// fetchRecentComments получает последние комментарии из сервиса комментариев
+func fetchRecentComments(ctx context.Context, limit int) ([]RecentComment, error) {
+	url := strings.TrimRight(commentsServiceURL, "/") + "/internal/comments/recent?limit=" + strconv.Itoa(limit)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if id := middleware.RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(middleware.RequestIDHeader, id)
+	}
+	req.Header.Set("X-Internal-Token", internalToken)
+
+	resp, err := commentsClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("неожиданный статус ответа сервиса комментариев: %d", resp.StatusCode)
+	}
+
+	var comments []RecentComment
+	if err := json.NewDecoder(resp.Body).Decode(&comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
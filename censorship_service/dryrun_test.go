@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"censorship_service/middleware"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeCommentsService подменяет сервис комментариев и запоминает заголовки запроса
+func fakeCommentsService(t *testing.T, comments []RecentComment) *http.Header {
+	t.Helper()
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		json.NewEncoder(w).Encode(comments)
+	}))
+	t.Cleanup(srv.Close)
+
+	oldURL, oldToken := commentsServiceURL, internalToken
+	commentsServiceURL, internalToken = srv.URL, "s3cret"
+	t.Cleanup(func() { commentsServiceURL, internalToken = oldURL, oldToken })
+	return &got
+}
+
+func TestFetchRecentCommentsHeaders(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	got := fakeCommentsService(t, []RecentComment{{ID: 1, NewsID: 2, Content: "текст"}})
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	parent := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled, Remote: true})
+
+	var comments []RecentComment
+	h := middleware.RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := trace.ContextWithRemoteSpanContext(r.Context(), parent)
+		var err error
+		if comments, err = fetchRecentComments(ctx, 10); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/api/censorship/dry-run", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-42")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(comments) != 1 || comments[0].Content != "текст" {
+		t.Fatalf("comments = %+v", comments)
+	}
+	if v := got.Get(middleware.RequestIDHeader); v != "req-42" {
+		t.Errorf("X-Request-ID = %q, want req-42", v)
+	}
+	if v := got.Get("X-Internal-Token"); v != "s3cret" {
+		t.Errorf("X-Internal-Token = %q, want s3cret", v)
+	}
+	if v := got.Get("Traceparent"); !strings.Contains(v, traceID.String()) {
+		t.Errorf("traceparent = %q, want trace id %s", v, traceID)
+	}
+}
+
+func TestFetchRecentCommentsStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+	}))
+	defer srv.Close()
+	old := commentsServiceURL
+	commentsServiceURL = srv.URL
+	defer func() { commentsServiceURL = old }()
+
+	if _, err := fetchRecentComments(context.Background(), 10); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("ошибка %v, want статус 403", err)
+	}
+}
@@ -1,36 +1,194 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
+	"censorship_service/classifier"
 	"censorship_service/middleware"
+	"censorship_service/rules"
+	"censorship_service/tracing"
 
 	"github.com/sirupsen/logrus"
 )
 
 type CommentRequest struct {
 	Text string `json:"text"`
+	// Author - автор текста; повторы ищутся среди текстов одного автора
+	Author string `json:"author,omitempty"`
 }
 
+// Статусы вердикта
+const (
+	statusApproved = "approved"
+	statusRejected = "rejected"
+	// statusReview - текст допустим, но требует проверки модератором
+	statusReview = "review"
+	// statusMasked - текст допустим после замены слов звездочками
+	statusMasked = "masked"
+)
+
+// CensorResponse - вердикт проверки. Отклоненный текст возвращается с кодом 400,
+// остальные вердикты - с кодом 200
+type CensorResponse struct {
+	Status string `json:"status"`
+	// Score - оценка нарушения от 0 до 1
+	Score float64 `json:"score"`
+	// Reason - объяснение решающего правила
+	Reason  string        `json:"reason,omitempty"`
+	Matches []rules.Match `json:"matches"`
+	// Text - текст со словами, замененными звездочками, для сохранения вместо
+	// исходного (вердикты masked и review)
+	Text string `json:"text,omitempty"`
+}
+
+// ruleStore - действующий набор правил цензуры комментариев
+var ruleStore *rules.Store
+
+// Профили правил: комментарии (управляются через API) и новости (файл NEWS_RULES_FILE)
+const (
+	profileComments = "comments"
+	profileNews     = "news"
+)
+
+// profiles - наборы правил по профилям, профиль выбирается параметром ?profile=
+var profiles = map[string]*rules.Store{}
+
+// fingerprints - отпечатки недавних текстов для поиска повторов
+var fingerprints = rules.NewFingerprints(rules.DefaultFingerprintCapacity)
+
+// toxicityModel - классификатор оскорбительности; nil, если модель не задана
+var toxicityModel rules.Classifier
+
 func main() {
 	// Настройка логгера
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 	logrus.SetOutput(os.Stdout)
 	logrus.SetLevel(logrus.InfoLevel)
 
+	rulesFile := os.Getenv("RULES_FILE")
+	if rulesFile == "" {
+		rulesFile = "rules.json"
+	}
+
+	modelFile := os.Getenv("CLASSIFIER_MODEL")
+
+	// Проверка правил на наборе примеров и обучение классификатора без запуска сервера
+	corpus := flag.String("check-corpus", "", "проверить правила на наборе примеров (JSON) и выйти")
+	train := flag.String("train", "", "обучить классификатор на размеченных примерах (CSV text,label) и выйти")
+	model := flag.String("model", modelFile, "файл модели классификатора для -train")
+	flag.Parse()
+	if *corpus != "" {
+		os.Exit(checkCorpus(rulesFile, *corpus))
+	}
+	if *train != "" {
+		os.Exit(trainClassifier(*train, *model))
+	}
+
+	commentsServiceURL = os.Getenv("COMMENTS_SERVICE_URL")
+	if commentsServiceURL == "" {
+		commentsServiceURL = "http://localhost:8081"
+	}
+	internalToken = os.Getenv("INTERNAL_TOKEN")
+
+	// Настройка трассировки
+	if _, err := tracing.Init(context.Background(), "censorship_service"); err != nil {
+		log.Fatalf("Ошибка настройки трассировки: %v", err)
+	}
+
+	// Файл правил изменяется через API, поэтому обычно лежит в томе с данными.
+	// При первом запуске он создается из набора по умолчанию
+	if err := seedRules(rulesFile, os.Getenv("RULES_SEED_FILE")); err != nil {
+		log.Fatalf("Ошибка создания файла правил: %v", err)
+	}
+
+	// История версий и журнал изменений правил
+	historyDir := os.Getenv("RULES_HISTORY_DIR")
+	if historyDir == "" {
+		historyDir = "history"
+	}
+	history, err := rules.OpenHistory(historyDir)
+	if err != nil {
+		log.Fatalf("Ошибка открытия истории правил: %v", err)
+	}
+
+	// Загрузка правил: без корректного файла сервис не запускается
+	ruleStore, err = rules.NewStore(rulesFile, history)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки правил: %v", err)
+	}
+	logRules(ruleStore)
+
+	// Правила для новостей - отдельный профиль без истории версий
+	newsRulesFile := os.Getenv("NEWS_RULES_FILE")
+	if newsRulesFile == "" {
+		newsRulesFile = "news_rules.json"
+	}
+	newsStore, err := rules.NewStore(newsRulesFile, nil)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки правил для новостей: %v", err)
+	}
+	logRules(newsStore)
+	profiles[profileComments] = ruleStore
+	profiles[profileNews] = newsStore
+
+	// Классификатор необязателен: без CLASSIFIER_MODEL работают только правила
+	if modelFile != "" {
+		m, err := classifier.Load(modelFile)
+		if err != nil {
+			log.Fatalf("Ошибка загрузки модели классификатора: %v", err)
+		}
+		toxicityModel = m
+		logrus.WithFields(logrus.Fields{
+			"file":       modelFile,
+			"vocabulary": m.Vocabulary(),
+		}).Info("Загружена модель классификатора")
+	}
+
+	// Правила перечитываются при изменении файла и по сигналу SIGHUP
+	reloadInterval := envDuration("RULES_RELOAD_INTERVAL", 5*time.Second)
+	for _, store := range profiles {
+		go store.Watch(context.Background(), reloadInterval, onRulesReload(store))
+	}
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		for range hup {
+			for _, store := range profiles {
+				onRulesReload(store)(store.Reload())
+			}
+		}
+	}()
+
 	// Создаем маршрутизатор
 	mux := http.NewServeMux()
 
 	// Добавляем маршруты
 	mux.HandleFunc("/api/censor", handleCensor)
+	mux.HandleFunc("/api/censor/batch", handleCensorBatch)
+	mux.HandleFunc("/api/censor/record", handleRecord)
+	mux.HandleFunc("/api/censorship/rules", handleRules)
+	mux.HandleFunc("/api/censorship/rules/", handleRule)
+	mux.HandleFunc("/api/censorship/versions", handleVersions)
+	mux.HandleFunc("/api/censorship/versions/", handleVersion)
+	mux.HandleFunc("/api/censorship/audit", handleAudit)
+	mux.HandleFunc("/api/censorship/dry-run", handleDryRun)
 	mux.HandleFunc("/health", handleHealth)
 
 	// Добавляем middleware
-	handler := middleware.LoggingMiddleware(middleware.RequestIDMiddleware(mux))
+	handler := middleware.RequestIDMiddleware(middleware.LoggingMiddleware(mux))
+	handler = tracing.Middleware(handler, "censorship_service", tracing.MuxRoute(mux))
 
 	// Запускаем сервер
 	port := os.Getenv("PORT")
@@ -50,34 +208,238 @@ func handleCensor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	profile, store, ok := profileFromRequest(w, r)
+	if !ok {
+		return
+	}
+
 	var req CommentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
 		return
 	}
 
-	// Простая валидация: проверка на пустые или очень короткие комментарии
-	if strings.TrimSpace(req.Text) == "" {
-		http.Error(w, "Комментарий не может быть пустым", http.StatusBadRequest)
+	opts := checkOptions(profile)
+	if profile == profileComments {
+		opts.Fingerprints = fingerprints
+		opts.Author = req.Author
+	}
+	verdict, resp := censorText(store.Rules(), req.Text, opts)
+	code := http.StatusOK
+	if resp.Status == statusRejected {
+		middleware.LoggerFromContext(r.Context()).WithFields(logrus.Fields{
+			"profile": profile,
+			"rule":    verdict.Rule,
+			"score":   verdict.Score,
+		}).Info("Текст отклонен")
+		code = http.StatusBadRequest
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleRecord - POST /api/censor/record: запоминает отпечаток опубликованного
+// комментария для поиска повторов. Шлюз вызывает его после того, как сервис
+// комментариев сохранил комментарий опубликованным
+func handleRecord(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if len(req.Text) < 3 {
-		http.Error(w, "Комментарий слишком короткий", http.StatusBadRequest)
+	var req CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
 		return
 	}
+	ruleStore.Rules().Record(req.Text, req.Author, fingerprints)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// profileFromRequest возвращает профиль правил из параметра ?profile=
+// (по умолчанию comments). Для неизвестного профиля отвечает 400
+func profileFromRequest(w http.ResponseWriter, r *http.Request) (string, *rules.Store, bool) {
+	profile := r.URL.Query().Get("profile")
+	if profile == "" {
+		profile = profileComments
+	}
+	store, ok := profiles[profile]
+	if !ok {
+		http.Error(w, "Неизвестный профиль правил", http.StatusBadRequest)
+		return "", nil, false
+	}
+	return profile, store, true
+}
+
+// checkOptions - средства проверки профиля помимо правил. Классификатор обучен
+// на комментариях, поэтому к новостям не применяется. Отпечатки для поиска
+// повторов вызывающий добавляет сам
+func checkOptions(profile string) rules.CheckOptions {
+	if profile == profileComments {
+		return rules.CheckOptions{Classifier: toxicityModel}
+	}
+	return rules.CheckOptions{}
+}
+
+// censorText проверяет текст набором правил и возвращает вердикт вместе
+// с ответом для клиента
+func censorText(set *rules.Set, text string, opts rules.CheckOptions) (rules.Verdict, CensorResponse) {
+	// Пустой текст отклоняется при любых правилах
+	var verdict rules.Verdict
+	if strings.TrimSpace(text) == "" {
+		reason := "Комментарий не может быть пустым"
+		verdict = rules.Verdict{
+			Action:  rules.ActionReject,
+			Rule:    "empty",
+			Reason:  reason,
+			Score:   1,
+			Matches: []rules.Match{{Rule: "empty", Category: "length", Action: rules.ActionReject, Reason: reason}},
+		}
+	} else {
+		verdict = set.CheckWith(text, opts)
+	}
+
+	resp := CensorResponse{
+		Status:  verdictStatus(verdict),
+		Score:   verdict.Score,
+		Reason:  verdict.Reason,
+		Matches: verdict.Matches,
+		Text:    verdict.Text,
+	}
+	if resp.Matches == nil {
+		resp.Matches = []rules.Match{}
+	}
+	return verdict, resp
+}
+
+// verdictStatus переводит решающее действие вердикта в статус ответа.
+// Подозрительные комментарии не отклоняются: решение принимает политика
+// шлюза (отправить на модерацию или отклонить)
+func verdictStatus(v rules.Verdict) string {
+	switch v.Action {
+	case rules.ActionReject:
+		return statusRejected
+	case rules.ActionSuspicious:
+		return statusReview
+	case rules.ActionMask:
+		return statusMasked
+	}
+	return statusApproved
+}
+
+// seedRules копирует набор по умолчанию в rulesFile, если файла еще нет
+func seedRules(rulesFile, seedFile string) error {
+	if seedFile == "" || seedFile == rulesFile {
+		return nil
+	}
+	if _, err := os.Stat(rulesFile); !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	data, err := os.ReadFile(seedFile)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(rulesFile), 0o755); err != nil {
+		return err
+	}
+	logrus.Infof("Файл правил %s создан из %s", rulesFile, seedFile)
+	return os.WriteFile(rulesFile, data, 0o644)
+}
 
-	// Проверка на запрещенные слова (пример)
-	forbiddenWords := []string{"spam", "advertisement", "offensive"}
-	for _, word := range forbiddenWords {
-		if strings.Contains(strings.ToLower(req.Text), word) {
-			http.Error(w, "Комментарий содержит запрещенные слова", http.StatusBadRequest)
+// onRulesReload возвращает функцию, сообщающую о результате перезагрузки
+// правил store. При ошибке продолжает действовать прежний набор
+func onRulesReload(store *rules.Store) func(*rules.Set, error) {
+	return func(_ *rules.Set, err error) {
+		if err != nil {
+			logrus.WithError(err).WithField("file", store.Path()).Error("Правила не перезагружены, действует прежний набор")
 			return
 		}
+		logRules(store)
 	}
+}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "approved"})
+func logRules(store *rules.Store) {
+	logrus.WithFields(logrus.Fields{
+		"file":  store.Path(),
+		"rules": len(store.Rules().Rules),
+	}).Info("Загружены правила цензуры")
+}
+
+// checkCorpus прогоняет примеры из corpusFile через правила и печатает
+// расхождения. Возвращает код выхода
+func checkCorpus(rulesFile, corpusFile string) int {
+	set, err := rules.Load(rulesFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	cases, err := rules.LoadCorpus(corpusFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	failures := set.RunCorpus(cases)
+	for _, f := range failures {
+		fmt.Printf("FAIL %q: ожидалось %q, получено %q (%s)\n", f.Case.Text, f.Case.Expect, f.Got.Action, f.Got.Rule)
+	}
+	fmt.Printf("Примеров: %d, расхождений: %d\n", len(cases), len(failures))
+	if len(failures) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// trainClassifier обучает классификатор на размеченных примерах из csvFile и
+// записывает модель в modelFile. Каждый десятый пример откладывается для
+// оценки точности, итоговая модель обучается на всех. Возвращает код выхода
+func trainClassifier(csvFile, modelFile string) int {
+	if modelFile == "" {
+		fmt.Fprintln(os.Stderr, "Не указан файл модели: -model или CLASSIFIER_MODEL")
+		return 2
+	}
+	examples, err := classifier.ReadCSVFile(csvFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var training, holdout []classifier.Example
+	for i, e := range examples {
+		if i%10 == 9 {
+			holdout = append(holdout, e)
+		} else {
+			training = append(training, e)
+		}
+	}
+	if m, err := classifier.Train(training); err == nil && len(holdout) > 0 {
+		fmt.Printf("Точность на отложенных примерах (%d): %.2f\n", len(holdout), m.Accuracy(holdout))
+	}
+
+	m, err := classifier.Train(examples)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := m.Save(modelFile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Printf("Примеров: %d (оскорбительных: %d), словарь: %d, модель записана в %s\n",
+		len(examples), m.ToxicDocs, m.Vocabulary(), modelFile)
+	return 0
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		logrus.Warnf("Неверное значение %s=%q, используется %s", key, v, def)
+	}
+	return def
 }
 
 func handleHealth(w http.ResponseWriter, r *http.Request) {
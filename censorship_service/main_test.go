@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"censorship_service/rules"
+)
+
+func censor(t *testing.T, text, author string) CensorResponse {
+	t.Helper()
+	body, _ := json.Marshal(CommentRequest{Text: text, Author: author})
+	rec := httptest.NewRecorder()
+	handleCensor(rec, httptest.NewRequest(http.MethodPost, "/api/censor", strings.NewReader(string(body))))
+	var resp CensorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestHandleRecord(t *testing.T) {
+	useTestRules(t, `{
+		"rules": [{"name": "profanity", "words": ["блин"], "action": "reject", "reason": "Грубость"}],
+		"spam": {"duplicate_count": 2, "duplicate_window": "1m"}
+	}`)
+	old := fingerprints
+	fingerprints = rules.NewFingerprints(10)
+	defer func() { fingerprints = old }()
+
+	const text = "купите наши лучшие окна со скидкой сегодня"
+	// Проверка сама отпечаток не запоминает
+	for i := 0; i < 3; i++ {
+		if resp := censor(t, text, "42"); resp.Status != statusApproved {
+			t.Fatalf("проверка %d: статус %s, want approved", i, resp.Status)
+		}
+	}
+
+	body, _ := json.Marshal(CommentRequest{Text: text, Author: "42"})
+	rec := httptest.NewRecorder()
+	handleRecord(rec, httptest.NewRequest(http.MethodPost, "/api/censor/record", strings.NewReader(string(body))))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("статус %d, want 204", rec.Code)
+	}
+	if resp := censor(t, text, "42"); resp.Status == statusApproved {
+		t.Error("повтор опубликованного текста одобрен")
+	}
+	if resp := censor(t, text, "7"); resp.Status != statusApproved {
+		t.Errorf("текст другого автора: статус %s, want approved", resp.Status)
+	}
+}
@@ -1,12 +1,14 @@
 package middleware
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"net/http"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // RequestIDKey - ключ для хранения ID запроса в контексте
@@ -17,6 +19,8 @@ const (
 	RequestIDHeader = "X-Request-ID"
 	// RequestIDContextKey - ключ контекста для ID запроса
 	RequestIDContextKey RequestIDKey = "request_id"
+	// maxRequestIDLength - максимальная длина принимаемого ID запроса
+	maxRequestIDLength = 128
 )
 
 type responseWriter struct {
@@ -35,23 +39,47 @@ func generateRequestID() string {
 	return hex.EncodeToString(b)
 }
 
-// RequestIDMiddleware добавляет ID запроса в контекст
+// RequestIDFromContext возвращает ID запроса из контекста
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDContextKey).(string)
+	return id
+}
+
+// LoggerFromContext возвращает запись логгера с ID запроса и трассировки
+func LoggerFromContext(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	if id := RequestIDFromContext(ctx); id != "" {
+		entry = entry.WithField("request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		entry = entry.WithFields(logrus.Fields{
+			"trace_id": sc.TraceID().String(),
+			"span_id":  sc.SpanID().String(),
+		})
+	}
+	return entry
+}
+
+// RequestIDMiddleware принимает ID запроса от клиента или генерирует новый
+// и добавляет его в контекст и ответ
 func RequestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestID := r.Header.Get(RequestIDHeader)
-		if requestID == "" {
+		if !validRequestID(requestID) {
 			requestID = generateRequestID()
+			r.Header.Set(RequestIDHeader, requestID)
 		}
 		w.Header().Set(RequestIDHeader, requestID)
-		next.ServeHTTP(w, r)
+		ctx := context.WithValue(r.Context(), RequestIDContextKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// LoggingMiddleware логирует информацию о каждом запросе
+// LoggingMiddleware логирует информацию о каждом запросе.
+// Должен подключаться внутри RequestIDMiddleware
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		requestID := r.Header.Get(RequestIDHeader)
 
 		rw := &responseWriter{
 			ResponseWriter: w,
@@ -61,13 +89,28 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(rw, r)
 
 		duration := time.Since(start)
-		logrus.WithFields(logrus.Fields{
-			"method":     r.Method,
-			"path":       r.URL.Path,
-			"status":     rw.statusCode,
-			"duration":   duration,
-			"request_id": requestID,
-			"remote_ip":  r.RemoteAddr,
+		LoggerFromContext(r.Context()).WithFields(logrus.Fields{
+			"method":    r.Method,
+			"path":      r.URL.Path,
+			"status":    rw.statusCode,
+			"duration":  duration,
+			"remote_ip": r.RemoteAddr,
 		}).Info("Обработан запрос")
 	})
 }
+
+// validRequestID отсекает пустые, слишком длинные и небезопасные для логов ID
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package rules
+
+import "fmt"
+
+// classifierRule и classifierCategory - имя и категория совпадения классификатора в вердикте
+const (
+	classifierRule     = "classifier"
+	classifierCategory = "toxicity"
+)
+
+// Classifier оценивает вероятность того, что текст оскорбительный.
+// Дополняет правила там, где списков слов недостаточно
+type Classifier interface {
+	// Toxicity возвращает вероятность от 0 до 1. ok=false - в тексте нет
+	// ничего знакомого модели, и вероятность ничего не говорит о тексте
+	Toxicity(text string) (p float64, ok bool)
+}
+
+// ClassifierConfig - как вероятность классификатора влияет на вердикт.
+// Без раздела в наборе правил действуют значения по умолчанию
+type ClassifierConfig struct {
+	// ReviewThreshold и RejectThreshold - вероятность, с которой текст уходит
+	// на модерацию и отклоняется
+	ReviewThreshold float64 `json:"review_threshold,omitempty"`
+	RejectThreshold float64 `json:"reject_threshold,omitempty"`
+	// Weight - доля вероятности, которая добавляется к оценке вердикта
+	Weight float64 `json:"weight,omitempty"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// defaultClassifierConfig - настройки классификатора для наборов без раздела classifier
+var defaultClassifierConfig = ClassifierConfig{
+	ReviewThreshold: 0.8,
+	RejectThreshold: 0.98,
+	Weight:          1,
+	Reason:          "Комментарий похож на оскорбительный",
+}
+
+func (c *ClassifierConfig) compile() error {
+	if c.ReviewThreshold == 0 {
+		c.ReviewThreshold = defaultClassifierConfig.ReviewThreshold
+	}
+	if c.RejectThreshold == 0 {
+		c.RejectThreshold = defaultClassifierConfig.RejectThreshold
+	}
+	if c.Weight == 0 {
+		c.Weight = defaultClassifierConfig.Weight
+	}
+	if c.Reason == "" {
+		c.Reason = defaultClassifierConfig.Reason
+	}
+	if c.ReviewThreshold < 0 || c.RejectThreshold > 1 || c.ReviewThreshold > c.RejectThreshold {
+		return fmt.Errorf("classifier: нужно 0 < review_threshold <= reject_threshold <= 1")
+	}
+	if c.Weight < 0 || c.Weight > 1 {
+		return fmt.Errorf("classifier: weight должен быть от 0 до 1")
+	}
+	return nil
+}
+
+// apply добавляет в вердикт оценку классификатора и возвращает ее вклад в score.
+// Оценка ниже review_threshold и оценка текста, в котором модель не знает
+// ни одного слова, не учитываются: иначе обычные комментарии получали бы
+// ненулевой score от одной только априорной вероятности модели
+func (c *ClassifierConfig) apply(verdict *Verdict, text string, runes []rune, classifier Classifier) float64 {
+	p, ok := classifier.Toxicity(text)
+	if !ok || p < c.ReviewThreshold {
+		return 0
+	}
+
+	action := ActionSuspicious
+	if p >= c.RejectThreshold {
+		action = ActionReject
+	}
+	if actionPriority[action] > actionPriority[verdict.Action] {
+		verdict.Action, verdict.Rule, verdict.Reason = action, classifierRule, c.Reason
+	}
+	if len(verdict.Matches) < maxMatches {
+		verdict.Matches = append(verdict.Matches, Match{
+			Rule:     classifierRule,
+			Category: classifierCategory,
+			Action:   action,
+			Reason:   fmt.Sprintf("%s (вероятность %.2f)", c.Reason, p),
+			End:      len(runes),
+		})
+	}
+	return p * c.Weight
+}
@@ -0,0 +1,57 @@
+package rules
+
+import "testing"
+
+// fixedClassifier возвращает заданную вероятность
+type fixedClassifier struct {
+	p  float64
+	ok bool
+}
+
+func (c fixedClassifier) Toxicity(string) (float64, bool) { return c.p, c.ok }
+
+func TestClassifierApply(t *testing.T) {
+	cfg := ClassifierConfig{ReviewThreshold: 0.6, RejectThreshold: 0.9}
+	if err := cfg.compile(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		classifier fixedClassifier
+		wantAction string
+		wantScore  float64
+	}{
+		{name: "ниже порога", classifier: fixedClassifier{p: 0.5, ok: true}},
+		{name: "незнакомый текст", classifier: fixedClassifier{p: 0.95, ok: false}},
+		{name: "на модерацию", classifier: fixedClassifier{p: 0.6, ok: true}, wantAction: ActionSuspicious, wantScore: 0.6},
+		{name: "отклонить", classifier: fixedClassifier{p: 0.9, ok: true}, wantAction: ActionReject, wantScore: 0.9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v Verdict
+			score := cfg.apply(&v, "текст", []rune("текст"), tt.classifier)
+			if v.Action != tt.wantAction || score != tt.wantScore {
+				t.Errorf("action %q, score %v, want %q, %v", v.Action, score, tt.wantAction, tt.wantScore)
+			}
+			if tt.wantAction == "" && len(v.Matches) != 0 {
+				t.Errorf("Matches = %+v, want пусто", v.Matches)
+			}
+		})
+	}
+}
+
+func TestCheckWithClassifier(t *testing.T) {
+	set, err := Parse([]byte(`{"rules": [{"name": "profanity", "words": ["блин"], "action": "reject", "reason": "Грубость"}]}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	// Строгое действие правила не ослабляется классификатором
+	v := set.CheckWith("ну блин", CheckOptions{Classifier: fixedClassifier{p: 0.85, ok: true}})
+	if v.Action != ActionReject || v.Rule != "profanity" {
+		t.Errorf("action %q, rule %q, want reject от profanity", v.Action, v.Rule)
+	}
+	// Без классификатора в opts оценка не применяется
+	if v := set.Check("обычный текст"); v.Score != 0 {
+		t.Errorf("Score = %v, want 0", v.Score)
+	}
+}
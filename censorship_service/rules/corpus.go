@@ -0,0 +1,44 @@
+package rules
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// CorpusCase - пример текста с ожидаемым действием (пусто - текст пропускается)
+type CorpusCase struct {
+	Text   string `json:"text"`
+	Expect string `json:"expect"`
+	// Note - чем интересен пример
+	Note string `json:"note,omitempty"`
+}
+
+// CorpusFailure - пример, для которого правила вынесли другое решение
+type CorpusFailure struct {
+	Case CorpusCase
+	Got  Verdict
+}
+
+// LoadCorpus читает набор примеров из JSON-файла
+func LoadCorpus(path string) ([]CorpusCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cases []CorpusCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+// RunCorpus проверяет примеры и возвращает те, где решение не совпало с ожидаемым
+func (s *Set) RunCorpus(cases []CorpusCase) []CorpusFailure {
+	var failures []CorpusFailure
+	for _, c := range cases {
+		if v := s.Check(c.Text); v.Action != c.Expect {
+			failures = append(failures, CorpusFailure{Case: c, Got: v})
+		}
+	}
+	return failures
+}
@@ -0,0 +1,30 @@
+package rules
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestCorpus прогоняет наборы примеров из corpus через правила сервиса,
+// чтобы изменение нормализации или rules.json не ломало их молча
+func TestCorpus(t *testing.T) {
+	set, err := Load(filepath.Join("..", "rules.json"))
+	if err != nil {
+		t.Fatalf("загрузка правил: %v", err)
+	}
+
+	for _, name := range []string{"evasion.json", "spam.json"} {
+		t.Run(name, func(t *testing.T) {
+			cases, err := LoadCorpus(filepath.Join("..", "corpus", name))
+			if err != nil {
+				t.Fatalf("загрузка примеров: %v", err)
+			}
+			if len(cases) == 0 {
+				t.Fatal("набор примеров пуст")
+			}
+			for _, f := range set.RunCorpus(cases) {
+				t.Errorf("%q: ожидалось %q, получено %q (%s)", f.Case.Text, f.Case.Expect, f.Got.Action, f.Got.Rule)
+			}
+		})
+	}
+}
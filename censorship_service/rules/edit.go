@@ -0,0 +1,104 @@
+package rules
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Изменение набора правил. Методы работают с черновиком из Store.Update:
+// проверка набора выполняется после изменения
+
+// Find возвращает правило по имени или nil
+func (s *Set) Find(name string) *Rule {
+	for i := range s.Rules {
+		if s.Rules[i].Name == name {
+			return &s.Rules[i]
+		}
+	}
+	return nil
+}
+
+// AddRule добавляет правило в конец списка
+func (s *Set) AddRule(r Rule) error {
+	if s.Find(r.Name) != nil {
+		return ErrRuleExists
+	}
+	s.Rules = append(s.Rules, r)
+	return nil
+}
+
+// ReplaceRule заменяет правило name, сохраняя его место в списке
+func (s *Set) ReplaceRule(name string, r Rule) error {
+	old := s.Find(name)
+	if old == nil {
+		return ErrRuleNotFound
+	}
+	if r.Name != name && s.Find(r.Name) != nil {
+		return ErrRuleExists
+	}
+	*old = r
+	return nil
+}
+
+// DeleteRule удаляет правило
+func (s *Set) DeleteRule(name string) error {
+	for i := range s.Rules {
+		if s.Rules[i].Name == name {
+			s.Rules = append(s.Rules[:i], s.Rules[i+1:]...)
+			return nil
+		}
+	}
+	return ErrRuleNotFound
+}
+
+// AddWords добавляет слова в правило, пропуская уже имеющиеся
+func (s *Set) AddWords(name string, words []string) error {
+	r := s.Find(name)
+	if r == nil {
+		return ErrRuleNotFound
+	}
+	for _, w := range words {
+		if !containsWord(r.Words, w) {
+			r.Words = append(r.Words, strings.TrimSpace(w))
+		}
+	}
+	return nil
+}
+
+// RemoveWords удаляет слова из правила (без учета регистра)
+func (s *Set) RemoveWords(name string, words []string) error {
+	r := s.Find(name)
+	if r == nil {
+		return ErrRuleNotFound
+	}
+	kept := r.Words[:0]
+	for _, w := range r.Words {
+		if !containsWord(words, w) {
+			kept = append(kept, w)
+		}
+	}
+	r.Words = kept
+	return nil
+}
+
+func containsWord(words []string, w string) bool {
+	for _, x := range words {
+		if strings.EqualFold(strings.TrimSpace(x), strings.TrimSpace(w)) {
+			return true
+		}
+	}
+	return false
+}
+
+// clone возвращает копию набора без скомпилированных данных
+func (s *Set) clone() (*Set, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var c Set
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,109 @@
+package rules
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// Параметры поиска повторов
+const (
+	// minFingerprintWords - короткие тексты ("спасибо!") повторяются законно
+	// и не учитываются
+	minFingerprintWords = 5
+	// maxFingerprintDistance - сколько бит отпечатков может отличаться
+	// у почти одинаковых текстов
+	maxFingerprintDistance = 3
+	// DefaultFingerprintCapacity - сколько отпечатков хранится по умолчанию
+	DefaultFingerprintCapacity = 10000
+)
+
+// Fingerprints хранит в памяти отпечатки недавно опубликованных текстов
+// с их авторами. Отпечатки старше окна повторов и сверх емкости удаляются
+type Fingerprints struct {
+	mu       sync.Mutex
+	capacity int
+	entries  []fingerprint
+}
+
+type fingerprint struct {
+	hash   uint64
+	author string
+	at     time.Time
+}
+
+// NewFingerprints создает хранилище на capacity отпечатков
+func NewFingerprints(capacity int) *Fingerprints {
+	if capacity <= 0 {
+		capacity = DefaultFingerprintCapacity
+	}
+	return &Fingerprints{capacity: capacity}
+}
+
+// Count возвращает, сколько почти одинаковых текстов автора author (включая
+// проверяемый) запомнено за window. Сам отпечаток не запоминается
+func (f *Fingerprints) Count(hash uint64, author string, window time.Duration) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.prune(time.Now(), window)
+	count := 1
+	for _, e := range f.entries {
+		if e.author == author && bits.OnesCount64(e.hash^hash) <= maxFingerprintDistance {
+			count++
+		}
+	}
+	return count
+}
+
+// Add запоминает отпечаток опубликованного текста автора author
+func (f *Fingerprints) Add(hash uint64, author string, window time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	f.prune(now, window)
+	if len(f.entries) >= f.capacity {
+		f.entries = append(f.entries[:0], f.entries[len(f.entries)-f.capacity+1:]...)
+	}
+	f.entries = append(f.entries, fingerprint{hash: hash, author: author, at: now})
+}
+
+// prune удаляет отпечатки старше window. Вызывается под f.mu
+func (f *Fingerprints) prune(now time.Time, window time.Duration) {
+	old := 0
+	for old < len(f.entries) && now.Sub(f.entries[old].at) > window {
+		old++
+	}
+	if old > 0 {
+		f.entries = append(f.entries[:0], f.entries[old:]...)
+	}
+}
+
+// simhash строит отпечаток текста по основам слов: у почти одинаковых текстов
+// отпечатки отличаются в нескольких битах. ok=false для слишком коротких текстов
+func simhash(tokens []token) (hash uint64, ok bool) {
+	if len(tokens) < minFingerprintWords {
+		return 0, false
+	}
+	var weights [64]int
+	for _, t := range tokens {
+		h := fnv.New64a()
+		h.Write([]byte(t.stem))
+		sum := h.Sum64()
+		for i := range weights {
+			if sum&(1<<i) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	for i, w := range weights {
+		if w > 0 {
+			hash |= 1 << i
+		}
+	}
+	return hash, true
+}
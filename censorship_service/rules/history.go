@@ -0,0 +1,259 @@
+package rules
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrVersionNotFound - версии с таким номером нет в истории
+var ErrVersionNotFound = errors.New("версия правил не найдена")
+
+// Change - кто и зачем меняет правила
+type Change struct {
+	UserID   string `json:"user_id,omitempty"`
+	UserName string `json:"user_name,omitempty"`
+	// Action - вид изменения: create_rule, update_rule, delete_rule, add_words,
+	// remove_words, replace, activate, file
+	Action  string `json:"action"`
+	Rule    string `json:"rule,omitempty"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// VersionInfo - описание сохраненной версии правил
+type VersionInfo struct {
+	Number    int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Change
+}
+
+// Version - версия правил вместе с набором
+type Version struct {
+	VersionInfo
+	Rules json.RawMessage `json:"rules"`
+}
+
+// AuditEntry - запись журнала изменений правил
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Version int       `json:"version"`
+	Change
+}
+
+// History хранит версии правил и журнал изменений в каталоге:
+// versions/<номер>.json и audit.jsonl
+type History struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// OpenHistory открывает каталог истории, создавая его при необходимости
+func OpenHistory(dir string) (*History, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "versions"), 0o755); err != nil {
+		return nil, err
+	}
+	return &History{dir: dir}, nil
+}
+
+// Versions возвращает описания всех версий, новые первыми
+func (h *History) Versions() ([]VersionInfo, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	numbers, err := h.numbers()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]VersionInfo, 0, len(numbers))
+	for i := len(numbers) - 1; i >= 0; i-- {
+		v, err := h.read(numbers[i])
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, v.VersionInfo)
+	}
+	return infos, nil
+}
+
+// Version возвращает версию с номером n
+func (h *History) Version(n int) (*Version, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.read(n)
+}
+
+// Latest возвращает последнюю версию или nil, если история пуста
+func (h *History) Latest() (*Version, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	numbers, err := h.numbers()
+	if err != nil || len(numbers) == 0 {
+		return nil, err
+	}
+	return h.read(numbers[len(numbers)-1])
+}
+
+// Save сохраняет набор как новую версию и записывает изменение в журнал
+func (h *History) Save(data []byte, change Change) (*Version, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	numbers, err := h.numbers()
+	if err != nil {
+		return nil, err
+	}
+	v := &Version{
+		VersionInfo: VersionInfo{Number: 1, CreatedAt: time.Now().UTC(), Change: change},
+		Rules:       json.RawMessage(data),
+	}
+	if len(numbers) > 0 {
+		v.Number = numbers[len(numbers)-1] + 1
+	}
+
+	body, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(h.versionPath(v.Number), body); err != nil {
+		return nil, err
+	}
+	if err := h.audit(AuditEntry{Time: v.CreatedAt, Version: v.Number, Change: change}); err != nil {
+		// Версия без записи в журнале не сохраняется
+		os.Remove(h.versionPath(v.Number))
+		return nil, err
+	}
+	return v, nil
+}
+
+// Audit возвращает последние limit записей журнала, новые первыми
+func (h *History) Audit(limit int) ([]AuditEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, err := os.Open(filepath.Join(h.dir, "audit.jsonl"))
+	if errors.Is(err, os.ErrNotExist) {
+		return []AuditEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]AuditEntry, 0, min(limit, len(entries)))
+	for i := len(entries) - 1; i >= 0 && len(out) < limit; i-- {
+		out = append(out, entries[i])
+	}
+	return out, nil
+}
+
+func (h *History) audit(e AuditEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(h.dir, "audit.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (h *History) read(n int) (*Version, error) {
+	data, err := os.ReadFile(h.versionPath(n))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var v Version
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("версия %d повреждена: %v", n, err)
+	}
+	return &v, nil
+}
+
+// numbers возвращает номера сохраненных версий по возрастанию
+func (h *History) numbers() ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(h.dir, "versions"))
+	if err != nil {
+		return nil, err
+	}
+	var numbers []int
+	for _, e := range entries {
+		if n, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".json")); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+func (h *History) versionPath(n int) string {
+	return filepath.Join(h.dir, "versions", fmt.Sprintf("%06d.json", n))
+}
+
+// sameRules сравнивает наборы правил без учета форматирования
+func sameRules(a, b []byte) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return false
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// writeFileAtomic записывает файл через временный файл в том же каталоге,
+// чтобы читатели не увидели его наполовину записанным
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+package rules
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Нормализация текста перед проверкой. Сводит обходы фильтра к обычному
+// написанию: "С.П.А.М", "с п а м", "sp4m", "спaм" (латинская "a"),
+// "ｓｐａｍ" (полноширинные символы) и "спааам"
+
+// Латинские и кириллические буквы, которые выглядят одинаково
+var (
+	latinToCyrillic = map[rune]rune{
+		'a': 'а', 'b': 'в', 'c': 'с', 'e': 'е', 'h': 'н', 'k': 'к', 'm': 'м',
+		'o': 'о', 'p': 'р', 't': 'т', 'x': 'х', 'y': 'у',
+	}
+	cyrillicToLatin = map[rune]rune{
+		'а': 'a', 'в': 'b', 'с': 'c', 'е': 'e', 'н': 'h', 'к': 'k', 'м': 'm',
+		'о': 'o', 'р': 'p', 'т': 't', 'х': 'x', 'у': 'y', 'і': 'i', 'ј': 'j', 'ѕ': 's',
+	}
+)
+
+// Замены leetspeak для каждого алфавита
+var (
+	latinLeet    = map[rune]rune{'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '@': 'a', '$': 's'}
+	cyrillicLeet = map[rune]rune{'0': 'о', '3': 'з', '4': 'ч', '6': 'б', '8': 'в', '@': 'а'}
+)
+
+// Сколько однобуквенных слов подряд склеивается в одно: через один и тот же
+// знак ("х. у. й", "с - п - а - м") хватает трех букв, через пробел нужно
+// не меньше четырех ("с п а м"), иначе склеиваются обычные фразы ("и я в")
+const (
+	minSpelledOut       = 3
+	minSpelledOutSpaced = 4
+)
+
+// spelledOutSeparators - знаки, которыми разделяют буквы слова по буквам.
+// Запятые и другие знаки не подходят: "пункты а, б, в" - перечисление
+const spelledOutSeparators = ".-_*"
+
+// commonLetterWords - однобуквенные предлоги, союзы, частицы и местоимения.
+// Буквы через пробел, среди которых только такие слова, не склеиваются
+var commonLetterWords = map[string]bool{
+	"а": true, "б": true, "в": true, "ж": true, "и": true,
+	"к": true, "о": true, "с": true, "у": true, "я": true,
+}
+
+// word - нормализованное слово и его место в исходном тексте (в символах)
+type word struct {
+	text       string
+	start, end int
+	// punct - после слова стоял знак препинания
+	punct bool
+}
+
+// normalize приводит текст к форме для сравнения и разбивает его на слова.
+// Позиция слова - символы исходного текста, из которых оно получилось:
+// у слов, разделенных знаком препинания ("привет,блин"), позиции свои
+func normalize(text string) []word {
+	runes := []rune(text)
+	var words []word
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && !unicode.IsSpace(runes[j]) {
+			j++
+		}
+		for _, p := range splitWord(normalizeParts(runes[i:j], i)) {
+			if w := unconfuse(p.text); w != "" {
+				words = append(words, word{text: w, start: p.start, end: p.end, punct: p.end < j})
+			}
+		}
+		i = j
+	}
+	return joinSpelledOut(words, runes)
+}
+
+// part - часть слова между знаками препинания и ее место в исходном тексте
+type part struct {
+	text       string
+	start, end int
+}
+
+// normalizeParts нормализует часть текста без пробелов, которая начинается
+// с символа offset, и делит ее на части из букв и цифр. Нормализуется каждый
+// сегмент NFKC отдельно, поэтому каждая буква результата знает, из каких
+// исходных символов она получилась
+func normalizeParts(chunk []rune, offset int) []part {
+	var parts []part
+	s := string(chunk)
+	at, inPart := offset, false
+	for pos := 0; pos < len(s); {
+		n := norm.NFKC.NextBoundaryInString(s[pos:], true)
+		if n <= 0 {
+			n = len(s) - pos
+		}
+		seg := s[pos : pos+n]
+		start, end := at, at+utf8.RuneCountInString(seg)
+		for _, r := range normalizeField(seg) {
+			if !isWordRune(r) {
+				inPart = false
+				continue
+			}
+			if !inPart {
+				parts = append(parts, part{start: start})
+				inPart = true
+			}
+			p := &parts[len(parts)-1]
+			p.text += string(r)
+			p.end = end
+		}
+		pos += n
+		at = end
+	}
+	return parts
+}
+
+// normalizeField приводит сегмент текста к NFKC и нижнему регистру
+// и удаляет невидимые символы
+func normalizeField(s string) string {
+	return strings.Map(func(r rune) rune {
+		// Невидимые символы и диакритика, навешанная на буквы ("zalgo")
+		if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		if r == 'ё' || r == 'Ё' {
+			return 'е'
+		}
+		return unicode.ToLower(r)
+	}, norm.NFKC.String(s))
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '@' || r == '$'
+}
+
+// splitWord решает, как разделить слово без пробелов по знакам препинания.
+// Если части - отдельные буквы ("с.п.а.м", "s-p-a-m"), они склеиваются обратно
+func splitWord(parts []part) []part {
+	if len(parts) < 2 {
+		return parts
+	}
+	long := 0
+	for _, p := range parts {
+		if utf8.RuneCountInString(p.text) > 2 {
+			long++
+		}
+	}
+	if long <= 1 {
+		joined := part{start: parts[0].start, end: parts[len(parts)-1].end}
+		for _, p := range parts {
+			joined.text += p.text
+		}
+		return []part{joined}
+	}
+	return parts
+}
+
+// unconfuse приводит слово к одному алфавиту: заменяет похожие буквы другого
+// алфавита и цифры leetspeak. Слова без букв не изменяются, кроме удаления "@" и "$"
+func unconfuse(word string) string {
+	runes := []rune(word)
+	var cyrillic, latin, cyrillicOnly, latinOnly, letters int
+	for _, r := range runes {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+			if _, ok := cyrillicToLatin[r]; !ok {
+				cyrillicOnly++
+			}
+		case unicode.Is(unicode.Latin, r):
+			latin++
+			if _, ok := latinToCyrillic[r]; !ok {
+				latinOnly++
+			}
+		}
+	}
+	letters = cyrillic + latin
+	if letters == 0 {
+		return strings.Map(func(r rune) rune {
+			if r == '@' || r == '$' {
+				return -1
+			}
+			return r
+		}, word)
+	}
+
+	// Алфавит слова определяют буквы, которых нет в другом алфавите,
+	// а если таких нет - большинство букв
+	toCyrillic := cyrillicOnly > latinOnly || (cyrillicOnly == latinOnly && cyrillic > latin)
+	homoglyphs, leet := cyrillicToLatin, latinLeet
+	if toCyrillic {
+		homoglyphs, leet = latinToCyrillic, cyrillicLeet
+	}
+
+	var b strings.Builder
+	for i, r := range runes {
+		if m, ok := homoglyphs[r]; ok && cyrillic > 0 && latin > 0 {
+			r = m
+		} else if m, ok := leet[r]; ok && letters > 1 && leetNeighbour(runes, i) {
+			r = m
+		} else if r == '@' || r == '$' {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// leetNeighbour - заменяется только символ рядом с буквой: "sp4m", но не "2024"
+func leetNeighbour(runes []rune, i int) bool {
+	return (i > 0 && unicode.IsLetter(runes[i-1])) || (i+1 < len(runes) && unicode.IsLetter(runes[i+1]))
+}
+
+// joinSpelledOut склеивает написанные по буквам слова ("с п а м", "х. у. й").
+// Буквы склеиваются, только если между ними везде один и тот же разделитель;
+// runes - исходный текст, по позициям слов из него берутся разделители
+func joinSpelledOut(words []word, runes []rune) []word {
+	out := words[:0:0]
+	for i := 0; i < len(words); {
+		j := i
+		if isLetter(words[i].text) {
+			// Другой разделитель завершает слово по буквам: "с п а м, а"
+			sep := separator(runes, words, i)
+			j = i + 1
+			for j < len(words) && isLetter(words[j].text) {
+				j++
+				if separator(runes, words, j-1) != sep {
+					break
+				}
+			}
+			if !spelledOut(words[i:j], sep) {
+				j = i
+			}
+		}
+		if j > i {
+			joined := word{start: words[i].start, end: words[j-1].end, punct: words[j-1].punct}
+			for _, w := range words[i:j] {
+				joined.text += w.text
+			}
+			out = append(out, joined)
+			i = j
+			continue
+		}
+		out = append(out, words[i])
+		i++
+	}
+	return out
+}
+
+// separator возвращает знаки между словами i и i+1 без пробелов
+// ("" - только пробелы, "-" - для "с - п")
+func separator(runes []rune, words []word, i int) string {
+	if i+1 >= len(words) {
+		return ""
+	}
+	return strings.Join(strings.Fields(string(runes[words[i].end:words[i+1].start])), "")
+}
+
+// spelledOut решает, склеивать ли буквы letters, разделенные sep
+func spelledOut(letters []word, sep string) bool {
+	if sep != "" {
+		return strings.Trim(sep, spelledOutSeparators) == "" && len(letters) >= minSpelledOut
+	}
+	if len(letters) < minSpelledOutSpaced {
+		return false
+	}
+	for _, w := range letters {
+		if !commonLetterWords[w.text] {
+			return true
+		}
+	}
+	return false
+}
+
+// isLetter сообщает, состоит ли слово из одной буквы
+func isLetter(s string) bool {
+	runes := []rune(s)
+	return len(runes) == 1 && unicode.IsLetter(runes[0])
+}
+
+// minStretch - с какой длины повтор буквы считается растягиванием: двойные
+// буквы бывают в обычных словах ("касса", "spell")
+const minStretch = 3
+
+// squeeze возвращает варианты слова, в которых растянутые буквы сокращены до
+// одной и до двух ("спааам" -> "спам", "спаам"). Для слов без растягивания
+// вариантов нет
+func squeeze(word string) []string {
+	runes := []rune(word)
+	var one, two []rune
+	stretched := false
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && runes[j] == runes[i] {
+			j++
+		}
+		n := j - i
+		if n >= minStretch {
+			stretched = true
+			one = append(one, runes[i])
+			two = append(two, runes[i], runes[i])
+		} else {
+			one = append(one, runes[i:j]...)
+			two = append(two, runes[i:j]...)
+		}
+		i = j
+	}
+	if !stretched {
+		return nil
+	}
+	return []string{string(one), string(two)}
+}
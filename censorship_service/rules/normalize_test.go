@@ -0,0 +1,115 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []word
+	}{
+		{
+			name: "слова через пробел",
+			text: "Привет  мир",
+			want: []word{{text: "привет", start: 0, end: 6}, {text: "мир", start: 8, end: 11}},
+		},
+		{
+			name: "знаки по краям не входят в позицию",
+			text: "«блин!»",
+			want: []word{{text: "блин", start: 1, end: 5, punct: true}},
+		},
+		{
+			name: "слова через запятую без пробела",
+			text: "привет,блин",
+			want: []word{{text: "привет", start: 0, end: 6, punct: true}, {text: "блин", start: 7, end: 11}},
+		},
+		{
+			name: "буквы через точку склеиваются",
+			text: "с.п.а.м!",
+			want: []word{{text: "спам", start: 0, end: 7, punct: true}},
+		},
+		{
+			name: "буквы через пробел склеиваются",
+			text: "с п а м",
+			want: []word{{text: "спам", start: 0, end: 7}},
+		},
+		{
+			name: "буквы через точку с пробелом склеиваются",
+			text: "х. у. й",
+			want: []word{{text: "хуй", start: 0, end: 7}},
+		},
+		{
+			name: "буквы через дефис с пробелами склеиваются",
+			text: "х - у - й",
+			want: []word{{text: "хуй", start: 0, end: 9}},
+		},
+		{
+			name: "три буквы через пробел не склеиваются",
+			text: "и я в",
+			want: []word{{text: "и", start: 0, end: 1}, {text: "я", start: 2, end: 3}, {text: "в", start: 4, end: 5}},
+		},
+		{
+			name: "предлоги и союзы через пробел не склеиваются",
+			text: "а я и в к",
+			want: []word{
+				{text: "а", start: 0, end: 1}, {text: "я", start: 2, end: 3}, {text: "и", start: 4, end: 5},
+				{text: "в", start: 6, end: 7}, {text: "к", start: 8, end: 9},
+			},
+		},
+		{
+			name: "перечисление через запятую не склеивается",
+			text: "а, б, в",
+			want: []word{
+				{text: "а", start: 0, end: 1, punct: true}, {text: "б", start: 3, end: 4, punct: true},
+				{text: "в", start: 6, end: 7},
+			},
+		},
+		{
+			name: "разные разделители не склеиваются",
+			text: "х. у - й",
+			want: []word{
+				{text: "х", start: 0, end: 1, punct: true}, {text: "у", start: 3, end: 4}, {text: "й", start: 7, end: 8},
+			},
+		},
+		{
+			name: "знак препинания завершает слово по буквам",
+			text: "с п а м, а",
+			want: []word{{text: "спам", start: 0, end: 7, punct: true}, {text: "а", start: 9, end: 10}},
+		},
+		{
+			name: "полноширинные символы",
+			text: "ｓｐａｍ,привет",
+			want: []word{{text: "spam", start: 0, end: 4, punct: true}, {text: "привет", start: 5, end: 11}},
+		},
+		{
+			name: "лигатура раскрывается в несколько букв",
+			text: "ﬁne,идиот",
+			want: []word{{text: "fine", start: 0, end: 3, punct: true}, {text: "идиот", start: 4, end: 9}},
+		},
+		{
+			name: "невидимые символы внутри слова",
+			text: "сп\u200bам",
+			want: []word{{text: "спам", start: 0, end: 5}},
+		},
+		{
+			name: "смешанные алфавиты и leetspeak",
+			text: "спaм sp4m",
+			want: []word{{text: "спам", start: 0, end: 4}, {text: "spam", start: 5, end: 9}},
+		},
+		{
+			name: "ё заменяется на е",
+			text: "ёлка",
+			want: []word{{text: "елка", start: 0, end: 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalize(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
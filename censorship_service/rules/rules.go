@@ -0,0 +1,340 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Действия правил
+const (
+	// ActionReject - текст отклоняется
+	ActionReject = "reject"
+	// ActionSuspicious - текст пропускается, но отправляется на модерацию
+	ActionSuspicious = "suspicious"
+	// ActionMask - найденные слова заменяются звездочками, текст пропускается
+	ActionMask = "mask"
+)
+
+// actionPriority - какое действие решает, если сработало несколько правил
+var actionPriority = map[string]int{ActionMask: 1, ActionSuspicious: 2, ActionReject: 3}
+
+// Rule - одно правило проверки текста
+type Rule struct {
+	// Name - имя правила, попадает в логи
+	Name string `json:"name"`
+	// Words - слова и фразы. Сравниваются по основе без учета регистра,
+	// поэтому одна запись находит все падежные и числовые формы слова.
+	// Запись с "*" на конце ("спам*") находит все слова, начинающиеся с корня
+	Words []string `json:"words,omitempty"`
+	// Patterns - регулярные выражения (синтаксис RE2), например "(?i)t\\.me/"
+	Patterns []string `json:"patterns,omitempty"`
+	// Action - reject, suspicious или mask
+	Action string `json:"action"`
+	// Reason - объяснение для пользователя
+	Reason string `json:"reason"`
+	// Category - категория нарушения для модераторов (spam, links, ...)
+	Category string `json:"category,omitempty"`
+	// Score - вес правила от 0 до 1. По умолчанию 1 для reject, 0.5 для suspicious
+	// и 0.25 для mask
+	Score float64 `json:"score,omitempty"`
+
+	words    [][]term
+	patterns []*regexp.Regexp
+}
+
+// Set - набор правил
+type Set struct {
+	// MinLength и MaxLength - допустимая длина текста в символах (0 - без ограничения)
+	MinLength int    `json:"min_length,omitempty"`
+	MaxLength int    `json:"max_length,omitempty"`
+	Rules     []Rule `json:"rules"`
+	// Spam - эвристики спама (ссылки, крик, контакты, повторы); без раздела
+	// эвристики не применяются
+	Spam *SpamConfig `json:"spam,omitempty"`
+	// Classifier - пороги классификатора; применяется, только если модель загружена
+	Classifier *ClassifierConfig `json:"classifier,omitempty"`
+}
+
+// CheckOptions - необязательные средства проверки помимо правил
+type CheckOptions struct {
+	// Fingerprints - отпечатки недавних текстов; без них повторы не ищутся
+	Fingerprints *Fingerprints
+	// Author - автор текста: повторы ищутся среди его же текстов
+	Author string
+	// Classifier - модель оценки оскорбительности; без нее не применяется
+	Classifier Classifier
+}
+
+// defaultCategory - категория правил без явно указанной категории
+const defaultCategory = "other"
+
+// maxMatches - сколько совпадений попадает в вердикт
+const maxMatches = 50
+
+// Verdict - результат проверки текста
+type Verdict struct {
+	// Action - решающее действие; пусто, если текст прошел проверку
+	Action string
+	// Rule и Reason - правило, определившее действие, и его объяснение
+	Rule   string
+	Reason string
+	// Score - оценка нарушения от 0 до 1: 1 - Π(1 - score) по сработавшим правилам
+	Score float64
+	// Matches - все найденные нарушения
+	Matches []Match
+	// Text - текст, в котором слова правил mask заменены звездочками;
+	// пусто, если маскировать нечего
+	Text string
+}
+
+// Match - найденное нарушение. Start и End - позиции в символах исходного текста
+type Match struct {
+	Rule     string `json:"rule"`
+	Category string `json:"category"`
+	// Action - пусто у эвристик спама, не набравших порога
+	Action   string `json:"action,omitempty"`
+	Reason   string `json:"reason"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Fragment string `json:"fragment,omitempty"`
+}
+
+// Load читает набор правил из JSON-файла и проверяет его
+func Load(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка в %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Parse разбирает набор правил из JSON и проверяет его
+func Parse(data []byte) (*Set, error) {
+	var s Set
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("ошибка разбора: %v", err)
+	}
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Set) compile() error {
+	if s.MinLength < 0 || s.MaxLength < 0 {
+		return fmt.Errorf("длина не может быть отрицательной")
+	}
+	if s.MaxLength > 0 && s.MinLength > s.MaxLength {
+		return fmt.Errorf("min_length больше max_length")
+	}
+	if len(s.Rules) == 0 {
+		return fmt.Errorf("набор правил пуст")
+	}
+	if s.Spam != nil {
+		if err := s.Spam.compile(); err != nil {
+			return err
+		}
+	}
+	if s.Classifier != nil {
+		if err := s.Classifier.compile(); err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := range s.Rules {
+		r := &s.Rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("правило %d: не указано имя", i)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("правило %s объявлено повторно", r.Name)
+		}
+		seen[r.Name] = true
+
+		switch r.Action {
+		case ActionReject, ActionSuspicious, ActionMask:
+		default:
+			return fmt.Errorf("правило %s: неизвестное действие %q", r.Name, r.Action)
+		}
+		if r.Reason == "" {
+			return fmt.Errorf("правило %s: не указана причина", r.Name)
+		}
+		if r.Score < 0 || r.Score > 1 {
+			return fmt.Errorf("правило %s: score должен быть от 0 до 1", r.Name)
+		}
+		if r.Score == 0 {
+			switch r.Action {
+			case ActionReject:
+				r.Score = 1
+			case ActionSuspicious:
+				r.Score = 0.5
+			case ActionMask:
+				r.Score = 0.25
+			}
+		}
+		if r.Category == "" {
+			r.Category = defaultCategory
+		}
+		if len(r.Words) == 0 && len(r.Patterns) == 0 {
+			return fmt.Errorf("правило %s: нужны words или patterns", r.Name)
+		}
+
+		r.words = r.words[:0]
+		for _, w := range r.Words {
+			w = strings.TrimSpace(w)
+			if w == "" {
+				return fmt.Errorf("правило %s: пустое слово", r.Name)
+			}
+			terms, ok := compileTerms(w)
+			if !ok {
+				return fmt.Errorf("правило %s: %q не слово; для ссылок и знаков используйте patterns", r.Name, w)
+			}
+			r.words = append(r.words, terms)
+		}
+		r.patterns = r.patterns[:0]
+		for _, p := range r.Patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return fmt.Errorf("правило %s: %v", r.Name, err)
+			}
+			r.patterns = append(r.patterns, re)
+		}
+	}
+	return nil
+}
+
+// Check проверяет текст. Решает самое строгое из сработавших действий
+// (reject, затем suspicious, затем mask), при равенстве - первое правило в списке.
+// Повторы текста не ищутся, классификатор не применяется: для этого нужен CheckWith
+func (s *Set) Check(text string) Verdict {
+	return s.CheckWith(text, CheckOptions{})
+}
+
+// CheckWith проверяет текст как Check, дополнительно применяя средства из opts.
+// С opts.Fingerprints текст сравнивается с недавними текстами того же автора,
+// чтобы находить один и тот же текст, отправленный много раз
+func (s *Set) CheckWith(text string, opts CheckOptions) Verdict {
+	n := utf8.RuneCountInString(text)
+	if s.MinLength > 0 && n < s.MinLength {
+		return lengthVerdict("min_length", "Комментарий слишком короткий", n)
+	}
+	if s.MaxLength > 0 && n > s.MaxLength {
+		return lengthVerdict("max_length", "Комментарий слишком длинный", n)
+	}
+
+	tokens := tokenize(text)
+	runes := []rune(text)
+	var verdict Verdict
+	var masked [][2]int
+	clean := 1.0
+	for i := range s.Rules {
+		r := &s.Rules[i]
+		found := r.find(text, tokens)
+		if len(found) == 0 {
+			continue
+		}
+		clean *= 1 - r.Score
+		if actionPriority[r.Action] > actionPriority[verdict.Action] {
+			verdict.Action, verdict.Rule, verdict.Reason = r.Action, r.Name, r.Reason
+		}
+		if r.Action == ActionMask {
+			masked = append(masked, found...)
+		}
+		for _, f := range found {
+			if len(verdict.Matches) == maxMatches {
+				break
+			}
+			verdict.Matches = append(verdict.Matches, Match{
+				Rule:     r.Name,
+				Category: r.Category,
+				Action:   r.Action,
+				Reason:   r.Reason,
+				Start:    f[0],
+				End:      f[1],
+				Fragment: string(runes[f[0]:f[1]]),
+			})
+		}
+	}
+	if s.Spam != nil {
+		clean *= 1 - s.Spam.apply(&verdict, text, runes, tokens, opts)
+	}
+	if opts.Classifier != nil {
+		cfg := s.Classifier
+		if cfg == nil {
+			cfg = &defaultClassifierConfig
+		}
+		clean *= 1 - cfg.apply(&verdict, text, runes, opts.Classifier)
+	}
+	verdict.Score = 1 - clean
+	if len(masked) > 0 && verdict.Action != ActionReject {
+		verdict.Text = mask(runes, masked)
+	}
+	return verdict
+}
+
+// Record запоминает отпечаток опубликованного текста автора author, чтобы
+// CheckWith находил его повторы. Проверка отпечатков не запоминает: текст
+// считается опубликованным, только когда его сохранил сервис комментариев.
+// Без раздела spam и для коротких текстов ничего не делает
+func (s *Set) Record(text, author string, fingerprints *Fingerprints) {
+	if s.Spam == nil {
+		return
+	}
+	if hash, ok := simhash(tokenize(text)); ok {
+		fingerprints.Add(hash, author, s.Spam.window)
+	}
+}
+
+// mask заменяет звездочками символы в spans, кроме пробелов
+func mask(runes []rune, spans [][2]int) string {
+	out := make([]rune, len(runes))
+	copy(out, runes)
+	for _, sp := range spans {
+		for i := sp[0]; i < sp[1]; i++ {
+			if !unicode.IsSpace(out[i]) {
+				out[i] = '*'
+			}
+		}
+	}
+	return string(out)
+}
+
+func lengthVerdict(rule, reason string, n int) Verdict {
+	return Verdict{
+		Action:  ActionReject,
+		Rule:    rule,
+		Reason:  reason,
+		Score:   1,
+		Matches: []Match{{Rule: rule, Category: "length", Action: ActionReject, Reason: reason, End: n}},
+	}
+}
+
+// find возвращает места совпадений правила в исходном тексте (в символах)
+func (r *Rule) find(text string, tokens []token) [][2]int {
+	var found [][2]int
+	for _, phrase := range r.words {
+		found = append(found, findPhrase(tokens, phrase)...)
+	}
+	for _, re := range r.patterns {
+		for _, loc := range re.FindAllStringIndex(text, maxMatches) {
+			start := utf8.RuneCountInString(text[:loc[0]])
+			found = append(found, [2]int{start, start + utf8.RuneCountInString(text[loc[0]:loc[1]])})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i][0] < found[j][0] })
+	return found
+}
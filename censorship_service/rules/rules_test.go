@@ -0,0 +1,56 @@
+package rules
+
+import "testing"
+
+func TestCheckMask(t *testing.T) {
+	set, err := Parse([]byte(`{
+		"rules": [
+			{"name": "profanity", "words": ["блин", "дурак"], "action": "mask", "reason": "Грубые слова скрыты"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "отдельное слово", text: "ну блин и все", want: "ну **** и все"},
+		{name: "после запятой без пробела", text: "привет,блин", want: "привет,****"},
+		{name: "перед запятой без пробела", text: "блин,привет", want: "****,привет"},
+		{name: "между знаками", text: "ну-ка,блин!вот", want: "ну-ка,****!вот"},
+		{name: "два слова через точку", text: "дурак.блин", want: "*****.****"},
+		{name: "знаки по краям не скрываются", text: "«блин»", want: "«****»"},
+		{name: "по буквам через точку", text: "б.л.и.н", want: "*******"},
+		{name: "по буквам через пробел", text: "б л и н", want: "* * * *"},
+		{name: "словоформа", text: "с блином", want: "с ******"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := set.Check(tt.text)
+			if v.Action != ActionMask {
+				t.Fatalf("Check(%q).Action = %q, want %q", tt.text, v.Action, ActionMask)
+			}
+			if v.Text != tt.want {
+				t.Errorf("Check(%q).Text = %q, want %q", tt.text, v.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMaskMatches(t *testing.T) {
+	set, err := Parse([]byte(`{"rules": [{"name": "profanity", "words": ["блин"], "action": "mask", "reason": "Грубые слова скрыты"}]}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	v := set.Check("привет,блин")
+	if len(v.Matches) != 1 {
+		t.Fatalf("Matches = %+v, want 1 match", v.Matches)
+	}
+	m := v.Matches[0]
+	if m.Start != 7 || m.End != 11 || m.Fragment != "блин" {
+		t.Errorf("match = [%d, %d) %q, want [7, 11) %q", m.Start, m.End, m.Fragment, "блин")
+	}
+}
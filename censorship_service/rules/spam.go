@@ -0,0 +1,301 @@
+package rules
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// spamRule и spamCategory - имя и категория совпадений эвристик спама в вердикте
+const (
+	spamRule     = "spam"
+	spamCategory = "spam"
+)
+
+// Веса эвристик: каждая сработавшая эвристика добавляет свой вес к оценке
+// спама по формуле 1 - Π(1 - вес)
+const (
+	weightTooManyLinks = 0.3
+	weightShortener    = 0.4
+	weightBadDomain    = 0.3
+	weightCaps         = 0.3
+	weightRepeat       = 0.2
+	weightPhone        = 0.4
+	weightHandle       = 0.3
+	weightDuplicate    = 0.6
+)
+
+var (
+	linkPattern   = regexp.MustCompile(`(?i)\b(?:https?://|www\.|t\.me/)[^\s<>"'()]+`)
+	phonePattern  = regexp.MustCompile(`(?:\+\d[\s\-()]*)?\d(?:[\s\-()]*\d){9,13}`)
+	handlePattern = regexp.MustCompile(`(?:^|[^\w@])(@[A-Za-z][A-Za-z0-9_]{4,31})\b`)
+)
+
+// Домены и зоны по умолчанию для оценки ссылок
+var (
+	defaultShorteners = []string{
+		"bit.ly", "t.co", "goo.gl", "tinyurl.com", "ow.ly", "is.gd", "cutt.ly", "clck.ru", "vk.cc", "shorturl.at",
+	}
+	defaultSuspiciousTLDs = []string{"xyz", "top", "click", "loan", "work", "gq", "tk", "ml", "cf", "ga"}
+)
+
+// SpamConfig - настройки эвристик спама. Эвристики дают оценку спама;
+// при оценке от ReviewThreshold текст уходит на модерацию, от RejectThreshold -
+// отклоняется
+type SpamConfig struct {
+	ReviewThreshold float64 `json:"review_threshold,omitempty"`
+	RejectThreshold float64 `json:"reject_threshold,omitempty"`
+	Reason          string  `json:"reason,omitempty"`
+
+	// MaxLinks - сколько ссылок допустимо без повышения оценки
+	MaxLinks int `json:"max_links,omitempty"`
+	// AllowDomains - ссылки на эти домены (и их поддомены) не учитываются;
+	// DenyDomains - ссылка на такой домен отклоняет текст сразу
+	AllowDomains []string `json:"allow_domains,omitempty"`
+	DenyDomains  []string `json:"deny_domains,omitempty"`
+	// Shorteners и SuspiciousTLDs - сокращатели ссылок и доменные зоны с плохой
+	// репутацией; пустые списки заменяются списками по умолчанию
+	Shorteners     []string `json:"shorteners,omitempty"`
+	SuspiciousTLDs []string `json:"suspicious_tlds,omitempty"`
+
+	// MaxCapsRatio - доля заглавных букв, выше которой текст считается "криком"
+	MaxCapsRatio float64 `json:"max_caps_ratio,omitempty"`
+	// MaxRepeat - сколько одинаковых символов подряд допустимо
+	MaxRepeat int `json:"max_repeat,omitempty"`
+
+	// DuplicateWindow и DuplicateCount - текст, похожий на DuplicateCount-1
+	// других текстов за DuplicateWindow, считается рассылкой
+	DuplicateWindow string `json:"duplicate_window,omitempty"`
+	DuplicateCount  int    `json:"duplicate_count,omitempty"`
+
+	window time.Duration
+}
+
+func (c *SpamConfig) compile() error {
+	if c.ReviewThreshold == 0 {
+		c.ReviewThreshold = 0.5
+	}
+	if c.RejectThreshold == 0 {
+		c.RejectThreshold = 0.9
+	}
+	if c.ReviewThreshold < 0 || c.RejectThreshold > 1 || c.ReviewThreshold > c.RejectThreshold {
+		return fmt.Errorf("spam: нужно 0 < review_threshold <= reject_threshold <= 1")
+	}
+	if c.Reason == "" {
+		c.Reason = "Комментарий похож на спам"
+	}
+	if c.MaxLinks == 0 {
+		c.MaxLinks = 2
+	}
+	if len(c.Shorteners) == 0 {
+		c.Shorteners = append([]string(nil), defaultShorteners...)
+	}
+	if len(c.SuspiciousTLDs) == 0 {
+		c.SuspiciousTLDs = append([]string(nil), defaultSuspiciousTLDs...)
+	}
+	if c.MaxCapsRatio == 0 {
+		c.MaxCapsRatio = 0.7
+	}
+	if c.MaxRepeat == 0 {
+		c.MaxRepeat = 6
+	}
+	if c.DuplicateCount == 0 {
+		c.DuplicateCount = 3
+	}
+	if c.DuplicateWindow == "" {
+		c.DuplicateWindow = "10m"
+	}
+	if c.MaxLinks < 0 || c.MaxCapsRatio < 0 || c.MaxCapsRatio > 1 || c.MaxRepeat < 2 || c.DuplicateCount < 2 {
+		return fmt.Errorf("spam: неверные ограничения")
+	}
+	d, err := time.ParseDuration(c.DuplicateWindow)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("spam: неверный duplicate_window %q", c.DuplicateWindow)
+	}
+	c.window = d
+	for _, list := range [][]string{c.AllowDomains, c.DenyDomains, c.Shorteners, c.SuspiciousTLDs} {
+		for i, d := range list {
+			list[i] = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), ".")
+		}
+	}
+	return nil
+}
+
+// spamSignal - сработавшая эвристика
+type spamSignal struct {
+	weight     float64
+	reason     string
+	start, end int
+	// deny - ссылка на запрещенный домен: текст отклоняется независимо от оценки
+	deny bool
+}
+
+// detectSpam применяет эвристики к тексту. Без opts.Fingerprints повторы
+// не ищутся
+func (c *SpamConfig) detectSpam(text string, tokens []token, opts CheckOptions) []spamSignal {
+	var signals []spamSignal
+	runeAt := func(b int) int { return utf8.RuneCountInString(text[:b]) }
+
+	// Ссылки: количество и репутация доменов
+	links := 0
+	for _, loc := range linkPattern.FindAllStringIndex(text, -1) {
+		start, end := runeAt(loc[0]), runeAt(loc[1])
+		host := linkHost(text[loc[0]:loc[1]])
+		switch {
+		case matchDomain(host, c.AllowDomains):
+			continue
+		case matchDomain(host, c.DenyDomains):
+			signals = append(signals, spamSignal{weight: 1, reason: "Ссылка на запрещенный сайт", start: start, end: end, deny: true})
+		case matchDomain(host, c.Shorteners):
+			signals = append(signals, spamSignal{weight: weightShortener, reason: "Сокращенная ссылка", start: start, end: end})
+		case badHost(host, c.SuspiciousTLDs):
+			signals = append(signals, spamSignal{weight: weightBadDomain, reason: "Ссылка на сайт с плохой репутацией", start: start, end: end})
+		}
+		links++
+		if links > c.MaxLinks {
+			signals = append(signals, spamSignal{weight: weightTooManyLinks, reason: "Слишком много ссылок", start: start, end: end})
+		}
+	}
+
+	// Телефоны и Telegram-аккаунты
+	for _, loc := range phonePattern.FindAllStringIndex(text, -1) {
+		signals = append(signals, spamSignal{weight: weightPhone, reason: "Номер телефона", start: runeAt(loc[0]), end: runeAt(loc[1])})
+	}
+	for _, loc := range handlePattern.FindAllStringSubmatchIndex(text, -1) {
+		signals = append(signals, spamSignal{weight: weightHandle, reason: "Контакт в Telegram", start: runeAt(loc[2]), end: runeAt(loc[3])})
+	}
+
+	// Крик: доля заглавных среди букв (в текстах хотя бы из 10 букв)
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters >= 10 && float64(upper)/float64(letters) > c.MaxCapsRatio {
+		signals = append(signals, spamSignal{weight: weightCaps, reason: "Слишком много заглавных букв", end: utf8.RuneCountInString(text)})
+	}
+
+	// Повторы одного символа
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && runes[j] == runes[i] {
+			j++
+		}
+		if j-i > c.MaxRepeat && !unicode.IsSpace(runes[i]) {
+			signals = append(signals, spamSignal{weight: weightRepeat, reason: "Повтор символов", start: i, end: j})
+			break
+		}
+		i = j
+	}
+
+	// Один и тот же текст, отправленный автором много раз
+	if opts.Fingerprints != nil {
+		if hash, ok := simhash(tokens); ok && opts.Fingerprints.Count(hash, opts.Author, c.window) >= c.DuplicateCount {
+			signals = append(signals, spamSignal{weight: weightDuplicate, reason: "Текст повторяет недавние комментарии", end: len(runes)})
+		}
+	}
+	return signals
+}
+
+// linkHost извлекает домен из найденной ссылки
+func linkHost(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// matchDomain сообщает, совпадает ли host с одним из доменов или его поддоменом
+func matchDomain(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// badHost - адрес вместо домена, punycode или зона с плохой репутацией
+func badHost(host string, tlds []string) bool {
+	if host == "" || net.ParseIP(host) != nil || strings.Contains(host, "xn--") {
+		return true
+	}
+	tld := host[strings.LastIndex(host, ".")+1:]
+	for _, t := range tlds {
+		if tld == t {
+			return true
+		}
+	}
+	return false
+}
+
+// spamScore объединяет веса сработавших эвристик
+func spamScore(signals []spamSignal) float64 {
+	clean := 1.0
+	for _, s := range signals {
+		clean *= 1 - s.weight
+	}
+	return 1 - math.Max(clean, 0)
+}
+
+// apply добавляет в вердикт сработавшие эвристики и возвращает оценку спама.
+// Оценка от порога меняет действие вердикта, если оно не строже
+func (c *SpamConfig) apply(verdict *Verdict, text string, runes []rune, tokens []token, opts CheckOptions) float64 {
+	signals := c.detectSpam(text, tokens, opts)
+	if len(signals) == 0 {
+		return 0
+	}
+	score := spamScore(signals)
+
+	action, reason := "", c.Reason
+	switch {
+	case score >= c.RejectThreshold:
+		action = ActionReject
+	case score >= c.ReviewThreshold:
+		action = ActionSuspicious
+	}
+	for _, s := range signals {
+		if s.deny {
+			action, reason = ActionReject, s.reason
+		}
+	}
+	if action != "" && actionPriority[action] > actionPriority[verdict.Action] {
+		verdict.Action, verdict.Rule, verdict.Reason = action, spamRule, reason
+	}
+
+	for _, s := range signals {
+		if len(verdict.Matches) == maxMatches {
+			break
+		}
+		m := Match{
+			Rule:     spamRule,
+			Category: spamCategory,
+			Action:   action,
+			Reason:   s.reason,
+			Start:    s.start,
+			End:      s.end,
+			Fragment: string(runes[s.start:s.end]),
+		}
+		if s.end-s.start == len(runes) {
+			// Эвристика относится ко всему тексту, его не повторяем
+			m.Fragment = ""
+		}
+		verdict.Matches = append(verdict.Matches, m)
+	}
+	return score
+}
@@ -0,0 +1,194 @@
+package rules
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newSpamConfig(t *testing.T) *SpamConfig {
+	t.Helper()
+	c := &SpamConfig{AllowDomains: []string{"example.com"}, DenyDomains: []string{"casino.ru"}}
+	if err := c.compile(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestDetectSpam(t *testing.T) {
+	c := newSpamConfig(t)
+	tests := []struct {
+		name string
+		text string
+		want []string // причины сработавших эвристик
+	}{
+		{name: "обычный текст", text: "Хорошая новость, спасибо", want: nil},
+		{name: "разрешенный домен", text: "подробнее на https://news.example.com/a", want: nil},
+		{name: "обычная ссылка", text: "источник https://lenta.ru/news/1", want: nil},
+		{name: "запрещенный домен", text: "заходи на www.casino.ru", want: []string{"Ссылка на запрещенный сайт"}},
+		{name: "сокращатель", text: "смотри bit.ly/abc и https://bit.ly/abc", want: []string{"Сокращенная ссылка"}},
+		{name: "плохая зона", text: "тут http://win.xyz/prize", want: []string{"Ссылка на сайт с плохой репутацией"}},
+		{name: "адрес вместо домена", text: "тут http://1.2.3.4/x", want: []string{"Ссылка на сайт с плохой репутацией"}},
+		{
+			name: "много ссылок",
+			text: "https://a.ru https://b.ru https://c.ru",
+			want: []string{"Слишком много ссылок"},
+		},
+		{name: "телефон", text: "звоните +7 (999) 123-45-67", want: []string{"Номер телефона"}},
+		{name: "Telegram", text: "пишите @spam_shop", want: []string{"Контакт в Telegram"}},
+		{name: "email не Telegram", text: "пишите user@mail.ru", want: nil},
+		{name: "крик", text: "КУПИТЕ ДЕШЕВО СЕЙЧАС", want: []string{"Слишком много заглавных букв"}},
+		{name: "короткий крик", text: "ДА!", want: nil},
+		{name: "повтор символов", text: "ура!!!!!!!!", want: []string{"Повтор символов"}},
+		{name: "повтор пробелов", text: "ура          ура", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, s := range c.detectSpam(tt.text, tokenize(tt.text), CheckOptions{}) {
+				got = append(got, s.reason)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detectSpam(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectSpamPosition(t *testing.T) {
+	c := newSpamConfig(t)
+	text := "ёж тут: https://bit.ly/x"
+	signals := c.detectSpam(text, tokenize(text), CheckOptions{})
+	if len(signals) != 1 || signals[0].start != 8 || signals[0].end != 24 {
+		t.Fatalf("signals = %+v, want ссылку в [8, 24)", signals)
+	}
+}
+
+func TestSpamScore(t *testing.T) {
+	tests := []struct {
+		weights []float64
+		want    float64
+	}{
+		{weights: nil, want: 0},
+		{weights: []float64{0.4}, want: 0.4},
+		{weights: []float64{0.4, 0.5}, want: 0.7},
+		{weights: []float64{1, 0.3}, want: 1},
+	}
+	for _, tt := range tests {
+		var signals []spamSignal
+		for _, w := range tt.weights {
+			signals = append(signals, spamSignal{weight: w})
+		}
+		if got := spamScore(signals); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("spamScore(%v) = %v, want %v", tt.weights, got, tt.want)
+		}
+	}
+}
+
+func TestFingerprints(t *testing.T) {
+	tokens := tokenize("купите наши лучшие окна со скидкой сегодня")
+	hash, ok := simhash(tokens)
+	if !ok {
+		t.Fatal("simhash: текст слишком короткий")
+	}
+	similar, _ := simhash(tokenize("купите наши лучшие окна со скидкой сегодня!!!"))
+	if similar != hash {
+		t.Errorf("отпечатки текстов, отличающихся знаками, не совпадают")
+	}
+	if _, ok := simhash(tokenize("спасибо за новость")); ok {
+		t.Error("simhash: короткий текст получил отпечаток")
+	}
+
+	f := NewFingerprints(10)
+	f.Add(hash, "alice", time.Minute)
+	f.Add(hash, "alice", time.Minute)
+	f.Add(hash, "bob", time.Minute)
+
+	tests := []struct {
+		author string
+		window time.Duration
+		want   int
+	}{
+		{author: "alice", window: time.Minute, want: 3},
+		{author: "bob", window: time.Minute, want: 2},
+		{author: "carol", window: time.Minute, want: 1},
+	}
+	for _, tt := range tests {
+		if got := f.Count(hash, tt.author, tt.window); got != tt.want {
+			t.Errorf("Count(%s) = %d, want %d", tt.author, got, tt.want)
+		}
+	}
+	// Count не запоминает отпечаток
+	if got := f.Count(hash, "carol", time.Minute); got != 1 {
+		t.Errorf("повторный Count(carol) = %d, want 1", got)
+	}
+	// Отпечатки старше окна удаляются
+	time.Sleep(2 * time.Millisecond)
+	if got := f.Count(hash, "alice", time.Millisecond); got != 1 {
+		t.Errorf("Count после окна = %d, want 1", got)
+	}
+}
+
+func TestFingerprintsCapacity(t *testing.T) {
+	f := NewFingerprints(2)
+	// Отпечатки отличаются больше чем на maxFingerprintDistance бит
+	hashes := []uint64{0, 0xFFFFFFFF, 0xFFFFFFFF00000000}
+	for _, h := range hashes {
+		f.Add(h, "alice", time.Minute)
+	}
+	// Самый старый отпечаток вытеснен
+	if got := f.Count(hashes[0], "alice", time.Minute); got != 1 {
+		t.Errorf("Count вытесненного = %d, want 1", got)
+	}
+	if got := f.Count(hashes[2], "alice", time.Minute); got != 2 {
+		t.Errorf("Count последнего = %d, want 2", got)
+	}
+}
+
+func TestSetRecord(t *testing.T) {
+	set, err := Parse([]byte(`{
+		"rules": [{"name": "profanity", "words": ["блин"], "action": "reject", "reason": "Грубость"}],
+		"spam": {"duplicate_count": 3, "duplicate_window": "1m"}
+	}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	const text = "купите наши лучшие окна со скидкой сегодня"
+
+	tests := []struct {
+		name      string
+		checks    int      // проверок текста alice без публикации
+		published []string // авторы опубликованных копий текста
+		want      string   // действие при следующей проверке текста alice
+	}{
+		{name: "проверки без публикации не засчитываются", checks: 3, want: ""},
+		{name: "две опубликованные копии - третья повтор", published: []string{"alice", "alice"}, want: ActionSuspicious},
+		{name: "одна опубликованная копия", checks: 2, published: []string{"alice"}, want: ""},
+		{name: "копии другого автора не считаются", published: []string{"bob", "bob"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFingerprints(10)
+			for i := 0; i < tt.checks; i++ {
+				set.CheckWith(text, CheckOptions{Fingerprints: f, Author: "alice"})
+			}
+			for _, author := range tt.published {
+				set.Record(text, author, f)
+			}
+			v := set.CheckWith(text, CheckOptions{Fingerprints: f, Author: "alice"})
+			if v.Action != tt.want {
+				t.Errorf("Action = %q, want %q", v.Action, tt.want)
+			}
+		})
+	}
+
+	// Без раздела spam отпечатки не запоминаются
+	plain, _ := Parse([]byte(`{"rules": [{"name": "profanity", "words": ["блин"], "action": "reject", "reason": "Грубость"}]}`))
+	f := NewFingerprints(10)
+	plain.Record(text, "alice", f)
+	hash, _ := simhash(tokenize(text))
+	if got := f.Count(hash, "alice", time.Minute); got != 1 {
+		t.Errorf("Count = %d, want 1", got)
+	}
+}
@@ -0,0 +1,241 @@
+package rules
+
+import "strings"
+
+// Стеммер для английского языка по алгоритму Snowball English (Porter2).
+// Слово должно быть в нижнем регистре и состоять из латинских букв
+
+var enExceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli", "singly": "singl",
+	"sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+var enExceptions1a = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true,
+	"earring": true, "proceed": true, "exceed": true, "succeed": true,
+}
+
+var enStep2 = []struct{ suffix, repl string }{
+	{"ization", "ize"}, {"ational", "ate"}, {"fulness", "ful"}, {"ousness", "ous"}, {"iveness", "ive"},
+	{"tional", "tion"}, {"biliti", "ble"}, {"lessli", "less"},
+	{"entli", "ent"}, {"ation", "ate"}, {"alism", "al"}, {"aliti", "al"}, {"ousli", "ous"}, {"iviti", "ive"}, {"fulli", "ful"},
+	{"enci", "ence"}, {"anci", "ance"}, {"abli", "able"}, {"izer", "ize"}, {"ator", "ate"}, {"alli", "al"},
+	{"bli", "ble"}, {"ogi", "og"}, {"li", ""},
+}
+
+var enStep3 = []struct{ suffix, repl string }{
+	{"ational", "ate"}, {"tional", "tion"}, {"alize", "al"}, {"icate", "ic"}, {"iciti", "ic"},
+	{"ative", ""}, {"ical", "ic"}, {"ness", ""}, {"ful", ""},
+}
+
+var enStep4 = []string{
+	"ement", "ance", "ence", "able", "ible", "ment", "ant", "ent", "ism", "ate", "iti", "ous", "ive", "ize", "ion",
+	"al", "er", "ic",
+}
+
+func isEnVowel(c byte) bool {
+	return strings.IndexByte("aeiouy", c) >= 0
+}
+
+// stemEnglish возвращает основу английского слова
+func stemEnglish(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	if s, ok := enExceptions[word]; ok {
+		return s
+	}
+
+	w := []byte(strings.TrimPrefix(word, "'"))
+	// Согласная "y" помечается как "Y", чтобы не считать ее гласной
+	for i := range w {
+		if w[i] == 'y' && (i == 0 || isEnVowel(w[i-1])) {
+			w[i] = 'Y'
+		}
+	}
+	r1, r2 := enRegions(w)
+
+	// Шаг 0
+	for _, s := range []string{"'s'", "'s", "'"} {
+		if hasSuffix(w, s) {
+			w = w[:len(w)-len(s)]
+			break
+		}
+	}
+
+	// Шаг 1a
+	switch {
+	case hasSuffix(w, "sses"):
+		w = w[:len(w)-2]
+	case hasSuffix(w, "ied"), hasSuffix(w, "ies"):
+		if len(w) > 4 {
+			w = w[:len(w)-2]
+		} else {
+			w = w[:len(w)-1]
+		}
+	case hasSuffix(w, "us"), hasSuffix(w, "ss"):
+	case hasSuffix(w, "s"):
+		if enHasVowel(w[:len(w)-2]) {
+			w = w[:len(w)-1]
+		}
+	}
+	if enExceptions1a[string(w)] {
+		return string(w)
+	}
+
+	// Шаг 1b
+	switch {
+	case hasSuffix(w, "eedly"):
+		if len(w)-5 >= r1 {
+			w = w[:len(w)-3]
+		}
+	case hasSuffix(w, "eed"):
+		if len(w)-3 >= r1 {
+			w = w[:len(w)-1]
+		}
+	default:
+		for _, s := range []string{"ingly", "edly", "ing", "ed"} {
+			if !hasSuffix(w, s) {
+				continue
+			}
+			if stem := w[:len(w)-len(s)]; enHasVowel(stem) {
+				w = stem
+				switch {
+				case hasSuffix(w, "at"), hasSuffix(w, "bl"), hasSuffix(w, "iz"):
+					w = append(w, 'e')
+				case enEndsDouble(w):
+					w = w[:len(w)-1]
+				case enIsShort(w, r1):
+					w = append(w, 'e')
+				}
+			}
+			break
+		}
+	}
+
+	// Шаг 1c
+	if n := len(w); n > 2 && (w[n-1] == 'y' || w[n-1] == 'Y') && !isEnVowel(w[n-2]) {
+		w[n-1] = 'i'
+	}
+
+	// Шаг 2
+	for _, e := range enStep2 {
+		if !hasSuffix(w, e.suffix) {
+			continue
+		}
+		stem := w[:len(w)-len(e.suffix)]
+		if len(stem) >= r1 {
+			switch e.suffix {
+			case "ogi":
+				if hasSuffix(stem, "l") {
+					w = append(stem, e.repl...)
+				}
+			case "li":
+				if n := len(stem); n > 0 && strings.IndexByte("cdeghkmnrt", stem[n-1]) >= 0 {
+					w = stem
+				}
+			default:
+				w = append(stem, e.repl...)
+			}
+		}
+		break
+	}
+
+	// Шаг 3
+	for _, e := range enStep3 {
+		if !hasSuffix(w, e.suffix) {
+			continue
+		}
+		stem := w[:len(w)-len(e.suffix)]
+		if len(stem) >= r1 && (e.suffix != "ative" || len(stem) >= r2) {
+			w = append(stem, e.repl...)
+		}
+		break
+	}
+
+	// Шаг 4
+	for _, s := range enStep4 {
+		if !hasSuffix(w, s) {
+			continue
+		}
+		stem := w[:len(w)-len(s)]
+		if len(stem) >= r2 && (s != "ion" || hasSuffix(stem, "s") || hasSuffix(stem, "t")) {
+			w = stem
+		}
+		break
+	}
+
+	// Шаг 5
+	if n := len(w); n > 0 {
+		switch w[n-1] {
+		case 'e':
+			if n-1 >= r2 || (n-1 >= r1 && !enShortSyllable(w[:n-1])) {
+				w = w[:n-1]
+			}
+		case 'l':
+			if n-1 >= r2 && n > 1 && w[n-2] == 'l' {
+				w = w[:n-1]
+			}
+		}
+	}
+
+	return strings.ToLower(string(w))
+}
+
+// enRegions возвращает начало областей R1 и R2
+func enRegions(w []byte) (int, int) {
+	r1 := -1
+	for _, p := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(string(w), p) {
+			r1 = len(p)
+			break
+		}
+	}
+	if r1 < 0 {
+		r1 = enRegion(w, 0)
+	}
+	return r1, enRegion(w, r1)
+}
+
+func enRegion(w []byte, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isEnVowel(w[i]) && isEnVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+func enHasVowel(w []byte) bool {
+	for _, c := range w {
+		if isEnVowel(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func enEndsDouble(w []byte) bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && strings.IndexByte("bdfgmnprt", w[n-1]) >= 0
+}
+
+// enShortSyllable сообщает, заканчивается ли слово коротким слогом
+func enShortSyllable(w []byte) bool {
+	n := len(w)
+	if n == 2 {
+		return isEnVowel(w[0]) && !isEnVowel(w[1])
+	}
+	return n >= 3 && !isEnVowel(w[n-3]) && isEnVowel(w[n-2]) &&
+		!isEnVowel(w[n-1]) && strings.IndexByte("wxY", w[n-1]) < 0
+}
+
+// enIsShort - слово короткое, если оканчивается коротким слогом и R1 пуста
+func enIsShort(w []byte, r1 int) bool {
+	return r1 >= len(w) && enShortSyllable(w)
+}
+
+func hasSuffix(w []byte, s string) bool {
+	return strings.HasSuffix(string(w), s)
+}
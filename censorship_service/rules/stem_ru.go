@@ -0,0 +1,152 @@
+package rules
+
+import "strings"
+
+// Стеммер для русского языка по алгоритму Snowball (Porter, 2007).
+// Слово должно быть в нижнем регистре, "ё" заменена на "е"
+
+var (
+	ruPerfectiveGerund1 = []string{"вшись", "вши", "в"}
+	ruPerfectiveGerund2 = []string{"ившись", "ывшись", "ивши", "ывши", "ив", "ыв"}
+	ruAdjective         = []string{"ими", "ыми", "его", "ого", "ему", "ому", "ее", "ие", "ые", "ое", "ей", "ий", "ый", "ой",
+		"ем", "им", "ым", "ом", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею"}
+	ruParticiple1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	ruParticiple2 = []string{"ивш", "ывш", "ующ"}
+	ruReflexive   = []string{"ся", "сь"}
+	ruVerb1       = []string{"ете", "йте", "ешь", "нно", "ла", "на", "ли", "ем", "ло", "но", "ет", "ют", "ны", "ть", "й", "л", "н"}
+	ruVerb2       = []string{"ейте", "уйте", "ила", "ыла", "ена", "ите", "или", "ыли", "ило", "ыло", "ено", "ует", "уют", "ены",
+		"ить", "ыть", "ишь", "ей", "уй", "ил", "ыл", "им", "ым", "ен", "ят", "ит", "ыт", "ую", "ю"}
+	ruNoun = []string{"иями", "ями", "ами", "ией", "иям", "ием", "иях", "ев", "ов", "ие", "ье", "еи", "ии", "ей", "ой", "ий",
+		"ям", "ем", "ам", "ом", "ах", "ях", "ию", "ью", "ия", "ья", "а", "е", "и", "й", "о", "у", "ы", "ь", "ю", "я"}
+	ruDerivational = []string{"ость", "ост"}
+	ruSuperlative  = []string{"ейше", "ейш"}
+)
+
+func isRuVowel(r rune) bool {
+	return strings.ContainsRune("аеиоуыэюя", r)
+}
+
+// stemRussian возвращает основу русского слова
+func stemRussian(word string) string {
+	w := []rune(word)
+
+	// RV - часть после первой гласной, R2 - вторая область R по Snowball
+	rv := len(w)
+	for i, r := range w {
+		if isRuVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	r1 := ruRegion(w, 0)
+	r2 := ruRegion(w, r1)
+	if rv >= len(w) {
+		return word
+	}
+	stem, tail := w[:rv], w[rv:]
+
+	// Шаг 1
+	if s, ok := ruRemoveAfterA(tail, ruPerfectiveGerund1); ok {
+		tail = s
+	} else if s, ok := ruRemove(tail, ruPerfectiveGerund2); ok {
+		tail = s
+	} else {
+		if s, ok := ruRemove(tail, ruReflexive); ok {
+			tail = s
+		}
+		if s, ok := ruRemoveAdjectival(tail); ok {
+			tail = s
+		} else if s, ok := ruRemoveAfterA(tail, ruVerb1); ok {
+			tail = s
+		} else if s, ok := ruRemove(tail, ruVerb2); ok {
+			tail = s
+		} else if s, ok := ruRemove(tail, ruNoun); ok {
+			tail = s
+		}
+	}
+
+	// Шаг 2
+	if s, ok := ruRemove(tail, []string{"и"}); ok {
+		tail = s
+	}
+
+	// Шаг 3: словообразовательный суффикс в R2
+	if s, ok := ruRemove(tail, ruDerivational); ok && rv+len(s) >= r2 {
+		tail = s
+	}
+
+	// Шаг 4
+	if s, ok := ruRemove(tail, []string{"нн"}); ok {
+		tail = append(s, 'н')
+	} else if s, ok := ruRemove(tail, ruSuperlative); ok {
+		tail = s
+		if s, ok := ruRemove(tail, []string{"нн"}); ok {
+			tail = append(s, 'н')
+		}
+	} else if s, ok := ruRemove(tail, []string{"ь"}); ok {
+		tail = s
+	}
+
+	return string(stem) + string(tail)
+}
+
+// ruRegion - начало области R после позиции from: за первой согласной,
+// следующей за гласной
+func ruRegion(w []rune, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isRuVowel(w[i]) && isRuVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+// ruRemove удаляет самое длинное из окончаний endings (списки упорядочены по убыванию длины)
+func ruRemove(w []rune, endings []string) ([]rune, bool) {
+	for _, e := range endings {
+		if s, ok := ruTrim(w, e); ok {
+			return s, true
+		}
+	}
+	return w, false
+}
+
+// ruRemoveAfterA удаляет окончание, только если перед ним стоит "а" или "я"
+func ruRemoveAfterA(w []rune, endings []string) ([]rune, bool) {
+	for _, e := range endings {
+		s, ok := ruTrim(w, e)
+		if !ok {
+			continue
+		}
+		if len(s) > 0 && (s[len(s)-1] == 'а' || s[len(s)-1] == 'я') {
+			return s, true
+		}
+	}
+	return w, false
+}
+
+// ruRemoveAdjectival удаляет окончание прилагательного вместе с суффиксом причастия
+func ruRemoveAdjectival(w []rune) ([]rune, bool) {
+	s, ok := ruRemove(w, ruAdjective)
+	if !ok {
+		return w, false
+	}
+	if p, ok := ruRemove(s, ruParticiple2); ok {
+		return p, true
+	}
+	if p, ok := ruRemoveAfterA(s, ruParticiple1); ok {
+		return p, true
+	}
+	return s, true
+}
+
+func ruTrim(w []rune, ending string) ([]rune, bool) {
+	e := []rune(ending)
+	if len(e) > len(w) {
+		return w, false
+	}
+	if string(w[len(w)-len(e):]) != ending {
+		return w, false
+	}
+	return w[: len(w)-len(e) : len(w)-len(e)], true
+}
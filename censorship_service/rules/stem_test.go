@@ -0,0 +1,119 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStemRussian(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{word: "вороны", want: "ворон"},
+		{word: "книги", want: "книг"},
+		{word: "дураком", want: "дурак"},
+		{word: "идиоты", want: "идиот"},
+		{word: "красивая", want: "красив"},
+		{word: "тяжелыми", want: "тяжел"},
+		{word: "красивейший", want: "красив"},
+		{word: "бегущий", want: "бегущ"},
+		{word: "длинный", want: "длин"},
+		{word: "сделавшись", want: "сдела"},
+		{word: "нежность", want: "нежност"},
+		// Окончание после "а" или "я" снимается, только если они в RV
+		{word: "взял", want: "взял"},
+		// Слова без RV не изменяются
+		{word: "мир", want: "мир"},
+		{word: "я", want: "я"},
+	}
+	for _, tt := range tests {
+		if got := stemRussian(tt.word); got != tt.want {
+			t.Errorf("stemRussian(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestStemEnglish(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{word: "running", want: "run"},
+		{word: "hopping", want: "hop"},
+		{word: "caresses", want: "caress"},
+		{word: "ponies", want: "poni"},
+		{word: "connections", want: "connect"},
+		{word: "generously", want: "generous"},
+		{word: "happiness", want: "happi"},
+		{word: "national", want: "nation"},
+		{word: "agreed", want: "agre"},
+		{word: "spammers", want: "spammer"},
+		{word: "idiots", want: "idiot"},
+		{word: "yellow", want: "yellow"},
+		// Исключения и короткие слова
+		{word: "skies", want: "sky"},
+		{word: "news", want: "news"},
+		{word: "is", want: "is"},
+	}
+	for _, tt := range tests {
+		if got := stemEnglish(tt.word); got != tt.want {
+			t.Errorf("stemEnglish(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{word: "спамом", want: "спам"},
+		{word: "spammers", want: "spammer"},
+		// Смесь алфавитов и цифры не стеммируются
+		{word: "spамом", want: "spамом"},
+		{word: "spam2", want: "spam2"},
+	}
+	for _, tt := range tests {
+		if got := stem(tt.word); got != tt.want {
+			t.Errorf("stem(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestStems(t *testing.T) {
+	got := Stems("Идиоты, SPAMMERS! Ёлки")
+	want := []string{"идиот", "spammer", "елк"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Stems = %q, want %q", got, want)
+	}
+}
+
+func TestCompileTerms(t *testing.T) {
+	tests := []struct {
+		entry string
+		want  []term
+		ok    bool
+	}{
+		{entry: "дураки", want: []term{{value: "дурак"}}, ok: true},
+		{entry: "дурак*", want: []term{{value: "дурак", prefix: true}}, ok: true},
+		{entry: "полный идиот", want: []term{{value: "полн"}, {value: "идиот"}}, ok: true},
+		{entry: "спам!", ok: false},
+		{entry: "", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := compileTerms(tt.entry)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("compileTerms(%q) = %v, %v, want %v, %v", tt.entry, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFindPhrase(t *testing.T) {
+	phrase, _ := compileTerms("полный идиот")
+	got := findPhrase(tokenize("Ты полным идиотом не будь, полный идиот"), phrase)
+	want := [][2]int{{3, 17}, {27, 39}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findPhrase = %v, want %v", got, want)
+	}
+}
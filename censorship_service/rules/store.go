@@ -0,0 +1,230 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Ошибки изменения правил через Store.Update
+var (
+	ErrRuleNotFound = errors.New("правило не найдено")
+	ErrRuleExists   = errors.New("правило с таким именем уже есть")
+)
+
+// ValidationError - изменение дает некорректный набор правил
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// HistoryError - версия не сохранена в истории, поэтому изменение не применено:
+// действует прежний набор
+type HistoryError struct {
+	Err error
+}
+
+func (e *HistoryError) Error() string {
+	return "версия правил не сохранена в истории, изменение не применено: " + e.Err.Error()
+}
+
+func (e *HistoryError) Unwrap() error {
+	return e.Err
+}
+
+// Store хранит действующий набор правил и перечитывает его из файла.
+// Файл с ошибкой не заменяет работающий набор. С историей каждое изменение
+// набора сохраняется как новая версия и записывается в журнал
+type Store struct {
+	path    string
+	history *History
+	current atomic.Pointer[Set]
+
+	mu      sync.Mutex
+	modTime time.Time
+	version int
+}
+
+// NewStore загружает набор правил из path. Без корректного файла сервис
+// не запускается. history может быть nil - тогда версии не ведутся
+func NewStore(path string, history *History) (*Store, error) {
+	s := &Store{path: path, history: history}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Rules возвращает действующий набор правил
+func (s *Store) Rules() *Set {
+	return s.current.Load()
+}
+
+// Path возвращает путь к файлу правил
+func (s *Store) Path() string {
+	return s.path
+}
+
+// History возвращает историю версий (nil, если она не ведется)
+func (s *Store) History() *History {
+	return s.history
+}
+
+// Version возвращает номер действующей версии (0 без истории)
+func (s *Store) Version() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version
+}
+
+// Reload перечитывает файл. При ошибке действующий набор сохраняется.
+// Измененный вручную файл сохраняется в истории как новая версия; если
+// сохранить ее не удалось, набор не применяется и возвращается *HistoryError.
+// Возвращает новый набор
+func (s *Store) Reload() (*Set, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+	set, err := Load(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.record(set, Change{Action: "file", Comment: "файл правил изменен вручную"}); err != nil {
+		return nil, &HistoryError{Err: err}
+	}
+	s.current.Store(set)
+	s.modTime = info.ModTime()
+	return set, nil
+}
+
+// Update применяет fn к копии действующего набора, проверяет результат,
+// записывает его в файл правил и делает действующим. Версия в истории
+// сохраняется после записи файла; если сохранить ее не удалось, прежний
+// файл восстанавливается, набор остается прежним и возвращается *HistoryError.
+// Ошибки набора возвращаются как *ValidationError, ошибки fn - без изменений
+func (s *Store) Update(change Change, fn func(*Set) error) (*Set, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	draft, err := s.current.Load().clone()
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(draft); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(draft, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	set, err := Parse(data)
+	if err != nil {
+		return nil, &ValidationError{Err: err}
+	}
+
+	previous, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	data, _ = json.MarshalIndent(set, "", "    ")
+	if err := s.writeFile(append(data, '\n')); err != nil {
+		return nil, err
+	}
+	if err := s.record(set, change); err != nil {
+		if restoreErr := s.writeFile(previous); restoreErr != nil {
+			return nil, fmt.Errorf("%w; прежний файл правил не восстановлен: %v", &HistoryError{Err: err}, restoreErr)
+		}
+		return nil, &HistoryError{Err: err}
+	}
+	s.current.Store(set)
+	return set, nil
+}
+
+// writeFile записывает файл правил и запоминает время его изменения, чтобы
+// Watch не принял запись за ручную правку. Вызывается под s.mu
+func (s *Store) writeFile(data []byte) error {
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// record сохраняет набор в истории, если он отличается от последней версии
+func (s *Store) record(set *Set, change Change) error {
+	if s.history == nil {
+		return nil
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		return err
+	}
+	latest, err := s.history.Latest()
+	if err != nil {
+		return err
+	}
+	if latest != nil && sameRules(latest.Rules, data) {
+		s.version = latest.Number
+		return nil
+	}
+	if latest == nil && change.Action == "file" {
+		change.Comment = "исходный файл правил"
+	}
+	v, err := s.history.Save(data, change)
+	if err != nil {
+		return err
+	}
+	s.version = v.Number
+	return nil
+}
+
+// Watch проверяет время изменения файла раз в interval и перечитывает его
+// при изменении. Результат каждой перезагрузки передается в onReload
+func (s *Store) Watch(ctx context.Context, interval time.Duration, onReload func(*Set, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	statFailed := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Об отсутствии файла сообщаем один раз, а не на каждом тике
+		info, err := os.Stat(s.path)
+		if err != nil {
+			if !statFailed {
+				onReload(nil, err)
+			}
+			statFailed = true
+			continue
+		}
+		statFailed = false
+		s.mu.Lock()
+		changed := !info.ModTime().Equal(s.modTime)
+		if changed {
+			// Запоминаем время сразу, чтобы не повторять ошибку на каждом тике
+			s.modTime = info.ModTime()
+		}
+		s.mu.Unlock()
+		if changed {
+			onReload(s.Reload())
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package rules
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const storeTestRules = `{"rules": [{"name": "profanity", "words": ["блин"], "action": "reject", "reason": "Грубость"}]}`
+
+// newTestStore создает набор правил с историей во временном каталоге
+func newTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(path, []byte(storeTestRules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	history, err := OpenHistory(filepath.Join(dir, "history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(path, history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, dir
+}
+
+// breakAudit делает журнал недоступным для записи: на его месте каталог
+func breakAudit(t *testing.T, dir string) func() {
+	t.Helper()
+	audit := filepath.Join(dir, "history", "audit.jsonl")
+	if err := os.Remove(audit); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(audit, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		if err := os.Remove(audit); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func addSpamRule(s *Set) error {
+	return s.AddRule(Rule{Name: "spam", Words: []string{"казино"}, Action: ActionReject, Reason: "Реклама"})
+}
+
+func TestStoreUpdateHistoryFailure(t *testing.T) {
+	store, dir := newTestStore(t)
+	before, _ := os.ReadFile(store.Path())
+	version := store.Version()
+	repair := breakAudit(t, dir)
+
+	_, err := store.Update(Change{Action: "add_rule", Rule: "spam"}, addSpamRule)
+	var historyErr *HistoryError
+	if !errors.As(err, &historyErr) {
+		t.Fatalf("Update: %v, want *HistoryError", err)
+	}
+	if store.Rules().Find("spam") != nil {
+		t.Error("изменение применено без версии в истории")
+	}
+	if after, _ := os.ReadFile(store.Path()); string(after) != string(before) {
+		t.Error("файл правил не восстановлен")
+	}
+	if store.Version() != version {
+		t.Errorf("Version = %d, want %d", store.Version(), version)
+	}
+	versions, err := store.History().Versions()
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("версий %d (%v), want 1: версия без записи в журнале осталась", len(versions), err)
+	}
+
+	// Повтор того же изменения после восстановления истории проходит без 409
+	repair()
+	if _, err := store.Update(Change{Action: "add_rule", Rule: "spam"}, addSpamRule); err != nil {
+		t.Fatalf("повторный Update: %v", err)
+	}
+	if store.Rules().Find("spam") == nil || store.Version() != version+1 {
+		t.Errorf("после повтора правило %v, версия %d, want правило и версию %d",
+			store.Rules().Find("spam") != nil, store.Version(), version+1)
+	}
+}
+
+func TestStoreReloadHistoryFailure(t *testing.T) {
+	store, dir := newTestStore(t)
+	version := store.Version()
+	repair := breakAudit(t, dir)
+
+	edited := `{"rules": [{"name": "profanity", "words": ["блин", "дурак"], "action": "reject", "reason": "Грубость"}]}`
+	if err := os.WriteFile(store.Path(), []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := store.Reload()
+	var historyErr *HistoryError
+	if !errors.As(err, &historyErr) {
+		t.Fatalf("Reload: %v, want *HistoryError", err)
+	}
+	if len(store.Rules().Find("profanity").Words) != 1 || store.Version() != version {
+		t.Error("ручная правка применена без версии в истории")
+	}
+
+	repair()
+	if _, err := store.Reload(); err != nil {
+		t.Fatalf("повторный Reload: %v", err)
+	}
+	if len(store.Rules().Find("profanity").Words) != 2 || store.Version() != version+1 {
+		t.Errorf("после повтора слов %d, версия %d", len(store.Rules().Find("profanity").Words), store.Version())
+	}
+}
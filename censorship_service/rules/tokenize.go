@@ -0,0 +1,125 @@
+package rules
+
+import (
+	"strings"
+	"unicode"
+)
+
+// token - слово текста после нормализации и его основа. Для слов с
+// растянутыми буквами ("спааам") хранятся и варианты без повтора
+type token struct {
+	word  string
+	stem  string
+	forms []token
+
+	// start и end - место слова в исходном тексте
+	start, end int
+}
+
+// tokenize нормализует текст, разбивает его на слова и находит их основы
+func tokenize(text string) []token {
+	words := normalize(text)
+	tokens := make([]token, len(words))
+	for i, w := range words {
+		tokens[i] = token{word: w.text, stem: stem(w.text), start: w.start, end: w.end}
+		for _, f := range squeeze(w.text) {
+			tokens[i].forms = append(tokens[i].forms, token{word: f, stem: stem(f)})
+		}
+	}
+	return tokens
+}
+
+// Stems возвращает основы слов текста после той же нормализации, что и
+// при сравнении с правилами. Используется классификаторами
+func Stems(text string) []string {
+	tokens := tokenize(text)
+	stems := make([]string, len(tokens))
+	for i, t := range tokens {
+		stems[i] = t.stem
+	}
+	return stems
+}
+
+// stem выбирает стеммер по алфавиту слова. Слова на смеси алфавитов и с
+// цифрами не изменяются
+func stem(word string) string {
+	cyrillic, latin := 0, 0
+	for _, r := range word {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case r >= 'a' && r <= 'z':
+			latin++
+		default:
+			return word
+		}
+	}
+	switch {
+	case latin == 0:
+		return stemRussian(word)
+	case cyrillic == 0:
+		return stemEnglish(word)
+	}
+	return word
+}
+
+// term - слово правила. Корень с "*" на конце ищется по началу слова,
+// остальные слова сравниваются по основе
+type term struct {
+	value  string
+	prefix bool
+}
+
+func (t term) match(tok token) bool {
+	if t.prefix && strings.HasPrefix(tok.word, t.value) || !t.prefix && tok.stem == t.value {
+		return true
+	}
+	for _, f := range tok.forms {
+		if t.match(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileTerms разбирает слово или фразу правила в последовательность слов.
+// Запись нормализуется так же, как проверяемый текст
+func compileTerms(entry string) ([]term, bool) {
+	prefix := strings.HasSuffix(entry, "*")
+	entry = strings.TrimSuffix(entry, "*")
+	for _, r := range entry {
+		if !isWordRune(r) && r != ' ' && r != '-' {
+			return nil, false
+		}
+	}
+	tokens := tokenize(entry)
+	if len(tokens) == 0 {
+		return nil, false
+	}
+
+	terms := make([]term, len(tokens))
+	for i, t := range tokens {
+		terms[i] = term{value: t.stem}
+	}
+	if prefix {
+		last := tokens[len(tokens)-1].word
+		terms[len(terms)-1] = term{value: last, prefix: true}
+	}
+	return terms, true
+}
+
+// findPhrase ищет в тексте слова фразы, идущие подряд, и возвращает места
+// найденных фраз в исходном тексте
+func findPhrase(tokens []token, phrase []term) [][2]int {
+	var found [][2]int
+	for i := 0; i+len(phrase) <= len(tokens); i++ {
+		j := 0
+		for j < len(phrase) && phrase[j].match(tokens[i+j]) {
+			j++
+		}
+		if j == len(phrase) {
+			found = append(found, [2]int{tokens[i].start, tokens[i+j-1].end})
+		}
+	}
+	return found
+}
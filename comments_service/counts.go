@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"comments_service/middleware"
+)
+
+// maxCountsBatch - сколько новостей можно запросить за раз (размер страницы списка новостей)
+const maxCountsBatch = 100
+
+// handleCommentCounts - GET /api/comments/counts?news_ids=1,2,3: число видимых
+// комментариев каждой новости. Ключи ответа - ID новостей, для новостей без
+// комментариев возвращается 0
+func handleCommentCounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	raw := r.URL.Query().Get("news_ids")
+	if raw == "" {
+		http.Error(w, "Требуются ID новостей", http.StatusBadRequest)
+		return
+	}
+	var ids []int
+	for _, v := range strings.Split(raw, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			http.Error(w, "Неверный ID новости", http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) > maxCountsBatch {
+		http.Error(w, fmt.Sprintf("Можно запросить не больше %d новостей", maxCountsBatch), http.StatusBadRequest)
+		return
+	}
+
+	counts, err := getCommentCounts(r.Context(), ids)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка подсчета комментариев")
+		http.Error(w, "Ошибка подсчета комментариев", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counts)
+}
+
+// getCommentCounts считает опубликованные комментарии, которые показываются
+// в ветках. Заглушки удаленных, скрытых и отклоненных комментариев не учитываются
+func getCommentCounts(ctx context.Context, ids []int) (map[string]int, error) {
+	counts := make(map[string]int, len(ids))
+	for _, id := range ids {
+		counts[strconv.Itoa(id)] = 0
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT c.news_id, count(*)
+		FROM comments c
+		WHERE c.news_id = ANY($1) AND c.deleted_at IS NULL AND c.status = 'approved' AND c.orphaned_at IS NULL
+		GROUP BY c.news_id
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var newsID, n int
+		if err := rows.Scan(&newsID, &n); err != nil {
+			return nil, err
+		}
+		counts[strconv.Itoa(newsID)] = n
+	}
+	return counts, rows.Err()
+}
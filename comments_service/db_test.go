@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// useTestDB подключает обработчики к тестовой базе TEST_DATABASE_URL и очищает
+// таблицы. Без переменной тест пропускается: базу данных он портит
+func useTestDB(t *testing.T) {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL не задан")
+	}
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("подключение к базе: %v", err)
+	}
+	old := db
+	db = pool
+	t.Cleanup(func() {
+		db = old
+		pool.Close()
+	})
+
+	if err := createTables(); err != nil {
+		t.Fatalf("создание таблиц: %v", err)
+	}
+	if _, err := db.Exec(context.Background(), `
+		TRUNCATE comments, comment_edits, comment_reports, comment_votes, comment_reactions RESTART IDENTITY CASCADE
+	`); err != nil {
+		t.Fatalf("очистка таблиц: %v", err)
+	}
+}
+
+// insertComment сохраняет комментарий автора authorID к новости 1 и возвращает его ID
+func insertComment(t *testing.T, parentID *int, authorID int, status string) int {
+	t.Helper()
+	var id int
+	err := db.QueryRow(context.Background(), `
+		INSERT INTO comments (news_id, parent_id, author_id, author_name, content, status)
+		VALUES (1, $1, $2, $3, $4, $5)
+		RETURNING id
+	`, parentID, authorID, "user"+strconv.Itoa(authorID), "текст", status).Scan(&id)
+	if err != nil {
+		t.Fatalf("вставка комментария: %v", err)
+	}
+	return id
+}
+
+// commentStatus возвращает статус модерации комментария из базы
+func commentStatus(t *testing.T, id int) string {
+	t.Helper()
+	var status string
+	if err := db.QueryRow(context.Background(), `SELECT status FROM comments WHERE id = $1`, id).Scan(&status); err != nil {
+		t.Fatalf("статус комментария %d: %v", id, err)
+	}
+	return status
+}
+
+// serve выполняет запрос от пользователя userID с ролью role (0 - без заголовков X-User-*)
+func serve(h http.HandlerFunc, method, path, body string, userID int, role string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if userID != 0 {
+		req.Header.Set("X-User-ID", strconv.Itoa(userID))
+		req.Header.Set("X-User-Role", role)
+	}
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec
+}
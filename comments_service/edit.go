@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"comments_service/middleware"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Роли, которым разрешено изменять чужие комментарии
+const (
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+var (
+	errCommentNotFound = errors.New("комментарий не найден")
+	errCommentDeleted  = errors.New("комментарий удален")
+	errForbidden       = errors.New("недостаточно прав")
+	errOwnComment      = errors.New("действие недоступно для своего комментария")
+)
+
+// Тело запроса редактирования комментария
+type EditRequest struct {
+	Content string `json:"content"`
+}
+
+// Предыдущая версия комментария
+type CommentEdit struct {
+	Content  string    `json:"content"`
+	EditedBy *int      `json:"edited_by,omitempty"`
+	EditedAt time.Time `json:"edited_at"`
+}
+
+// actor - пользователь, от имени которого шлюз выполняет запрос (заголовки X-User-*)
+type actor struct {
+	userID int
+	role   string
+}
+
+func actorFromRequest(r *http.Request) (actor, bool) {
+	userID, err := strconv.Atoi(r.Header.Get("X-User-ID"))
+	if err != nil {
+		return actor{}, false
+	}
+	return actor{userID: userID, role: r.Header.Get("X-User-Role")}, true
+}
+
+func (a actor) isModerator() bool {
+	return a.role == roleModerator || a.role == roleAdmin
+}
+
+// canModify - изменять комментарий может автор или модератор
+func (a actor) canModify(authorID *int) bool {
+	return a.isModerator() || (authorID != nil && *authorID == a.userID)
+}
+
+// handleComment - операции с одним комментарием:
+// PATCH и DELETE /api/comments/{id}, GET /api/comments/{id}/history,
+// POST /api/comments/{id}/report, PUT и DELETE /api/comments/{id}/vote
+// и /api/comments/{id}/reactions/{reaction}
+func handleComment(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/comments/"), "/")
+	commentID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Неверный ID комментария", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodPatch:
+			handleEditComment(w, r, commentID)
+		case http.MethodDelete:
+			handleDeleteComment(w, r, commentID)
+		default:
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 2 && parts[1] == "history":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+		handleCommentHistory(w, r, commentID)
+	case len(parts) == 2 && parts[1] == "report":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+		handleReportComment(w, r, commentID)
+	case len(parts) == 2 && parts[1] == "vote":
+		handleVote(w, r, commentID)
+	case len(parts) == 3 && parts[1] == "reactions":
+		handleReaction(w, r, commentID, parts[2])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func handleEditComment(w http.ResponseWriter, r *http.Request, commentID int) {
+	who, ok := actorFromRequest(r)
+	if !ok {
+		http.Error(w, "Требуется аутентификация", http.StatusUnauthorized)
+		return
+	}
+
+	var req EditRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Content) == "" {
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+		return
+	}
+
+	// Если новый текст подозрителен, шлюз возвращает комментарий на модерацию
+	status := ""
+	if r.Header.Get(moderationStatusHeader) == statusPending {
+		status = statusPending
+	}
+
+	comment, err := editComment(r.Context(), commentID, who, req.Content, status)
+	if err != nil {
+		writeModifyError(w, r, err, "Ошибка редактирования комментария")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+func handleDeleteComment(w http.ResponseWriter, r *http.Request, commentID int) {
+	who, ok := actorFromRequest(r)
+	if !ok {
+		http.Error(w, "Требуется аутентификация", http.StatusUnauthorized)
+		return
+	}
+
+	comment, err := deleteComment(r.Context(), commentID, who)
+	if err != nil {
+		writeModifyError(w, r, err, "Ошибка удаления комментария")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+// handleCommentHistory отдает предыдущие версии комментария автору и модераторам
+func handleCommentHistory(w http.ResponseWriter, r *http.Request, commentID int) {
+	who, ok := actorFromRequest(r)
+	if !ok {
+		http.Error(w, "Требуется аутентификация", http.StatusUnauthorized)
+		return
+	}
+
+	var authorID *int
+	err := db.QueryRow(r.Context(), `SELECT author_id FROM comments WHERE id = $1`, commentID).Scan(&authorID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errCommentNotFound
+		}
+		writeModifyError(w, r, err, "Ошибка получения истории")
+		return
+	}
+	if !who.canModify(authorID) {
+		writeModifyError(w, r, errForbidden, "")
+		return
+	}
+
+	rows, err := db.Query(r.Context(), `
+		SELECT content, edited_by, edited_at
+		FROM comment_edits
+		WHERE comment_id = $1
+		ORDER BY edited_at, id
+	`, commentID)
+	if err != nil {
+		writeModifyError(w, r, err, "Ошибка получения истории")
+		return
+	}
+	defer rows.Close()
+
+	history := []CommentEdit{}
+	for rows.Next() {
+		var e CommentEdit
+		if err := rows.Scan(&e.Content, &e.EditedBy, &e.EditedAt); err != nil {
+			writeModifyError(w, r, err, "Ошибка получения истории")
+			return
+		}
+		history = append(history, e)
+	}
+	if err := rows.Err(); err != nil {
+		writeModifyError(w, r, err, "Ошибка получения истории")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+func writeModifyError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	switch {
+	case errors.Is(err, errCommentNotFound):
+		http.Error(w, "Комментарий не найден", http.StatusNotFound)
+	case errors.Is(err, errCommentDeleted):
+		http.Error(w, "Комментарий удален", http.StatusConflict)
+	case errors.Is(err, errForbidden):
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+	default:
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+// lockComment блокирует строку комментария до конца транзакции и проверяет права
+func lockComment(ctx context.Context, tx pgx.Tx, commentID int, who actor) (content string, err error) {
+	var (
+		authorID  *int
+		deletedAt *time.Time
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT content, author_id, deleted_at FROM comments WHERE id = $1 FOR UPDATE
+	`, commentID).Scan(&content, &authorID, &deletedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errCommentNotFound
+		}
+		return "", err
+	}
+	if !who.canModify(authorID) {
+		return "", errForbidden
+	}
+	if deletedAt != nil {
+		return "", errCommentDeleted
+	}
+	return content, nil
+}
+
+// editComment сохраняет прежний текст в истории и заменяет его новым.
+// Непустой status заменяет статус модерации
+func editComment(ctx context.Context, commentID int, who actor, content, status string) (*Comment, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	previous, err := lockComment(ctx, tx, commentID, who)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO comment_edits (comment_id, content, edited_by) VALUES ($1, $2, $3)
+	`, commentID, previous, who.userID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE comments SET content = $2, edited_at = CURRENT_TIMESTAMP, status = COALESCE(NULLIF($3, ''), status)
+		WHERE id = $1
+	`, commentID, content, status); err != nil {
+		return nil, err
+	}
+
+	comment, err := getCommentByID(ctx, tx, commentID)
+	if err != nil {
+		return nil, err
+	}
+	return comment, tx.Commit(ctx)
+}
+
+// deleteComment помечает комментарий удаленным. Строка остается в базе,
+// чтобы не разрывать ветку ответов
+func deleteComment(ctx context.Context, commentID int, who actor) (*Comment, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockComment(ctx, tx, commentID, who); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE comments SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $2 WHERE id = $1
+	`, commentID, who.userID); err != nil {
+		return nil, err
+	}
+
+	comment, err := getCommentByID(ctx, tx, commentID)
+	if err != nil {
+		return nil, err
+	}
+	return comment, tx.Commit(ctx)
+}
+
+// getCommentByID возвращает комментарий без скрытия текста - для автора и модераторов
+func getCommentByID(ctx context.Context, tx pgx.Tx, commentID int) (*Comment, error) {
+	var c Comment
+	err := tx.QueryRow(ctx, `
+		SELECT `+commentFullSelectSQL+`
+		FROM comments c
+		WHERE c.id = $1
+	`, commentID).Scan(c.scanFields()...)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
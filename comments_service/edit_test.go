@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestActorCanModify(t *testing.T) {
+	author := 7
+	tests := []struct {
+		name     string
+		who      actor
+		authorID *int
+		want     bool
+	}{
+		{name: "автор", who: actor{userID: 7, role: "user"}, authorID: &author, want: true},
+		{name: "другой пользователь", who: actor{userID: 8, role: "user"}, authorID: &author},
+		{name: "модератор", who: actor{userID: 8, role: roleModerator}, authorID: &author, want: true},
+		{name: "администратор", who: actor{userID: 8, role: roleAdmin}, authorID: &author, want: true},
+		{name: "анонимный комментарий", who: actor{userID: 7, role: "user"}},
+		{name: "анонимный комментарий - модератор", who: actor{userID: 8, role: roleModerator}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.who.canModify(tt.authorID); got != tt.want {
+				t.Errorf("canModify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleCommentBeforeDB(t *testing.T) {
+	// Запросы отклоняются до обращения к базе
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		userID int
+		want   int
+	}{
+		{name: "редактирование без пользователя", method: http.MethodPatch, path: "/api/comments/1", body: `{"content":"x"}`, want: 401},
+		{name: "удаление без пользователя", method: http.MethodDelete, path: "/api/comments/1", want: 401},
+		{name: "история без пользователя", method: http.MethodGet, path: "/api/comments/1/history", want: 401},
+		{name: "пустой текст", method: http.MethodPatch, path: "/api/comments/1", body: `{"content":"  "}`, userID: 7, want: 400},
+		{name: "неверный ID", method: http.MethodPatch, path: "/api/comments/abc", body: `{"content":"x"}`, userID: 7, want: 400},
+		{name: "неподдерживаемый метод", method: http.MethodPut, path: "/api/comments/1", userID: 7, want: 405},
+		{name: "история только GET", method: http.MethodPost, path: "/api/comments/1/history", userID: 7, want: 405},
+		{name: "неизвестный путь", method: http.MethodGet, path: "/api/comments/1/unknown", userID: 7, want: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(handleComment, tt.method, tt.path, tt.body, tt.userID, "user")
+			if rec.Code != tt.want {
+				t.Errorf("статус %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteModifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: errCommentNotFound, want: 404},
+		{err: fmt.Errorf("правка: %w", errCommentDeleted), want: 409},
+		{err: errForbidden, want: 403},
+		{err: errors.New("база недоступна"), want: 500},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeModifyError(rec, httptest.NewRequest(http.MethodPatch, "/api/comments/1", nil), tt.err, "Ошибка")
+		if rec.Code != tt.want {
+			t.Errorf("%v: статус %d, want %d", tt.err, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestEditCommentPermissions(t *testing.T) {
+	useTestDB(t)
+	id := insertComment(t, nil, 7, statusApproved)
+	path := fmt.Sprintf("/api/comments/%d", id)
+
+	tests := []struct {
+		name   string
+		userID int
+		role   string
+		want   int
+	}{
+		{name: "другой пользователь", userID: 8, role: "user", want: 403},
+		{name: "автор", userID: 7, role: "user", want: 200},
+		{name: "модератор", userID: 9, role: roleModerator, want: 200},
+		{name: "администратор", userID: 10, role: roleAdmin, want: 200},
+	}
+	for _, tt := range tests {
+		body := fmt.Sprintf(`{"content":"правка %d"}`, tt.userID)
+		rec := serve(handleComment, http.MethodPatch, path, body, tt.userID, tt.role)
+		if rec.Code != tt.want {
+			t.Fatalf("%s: статус %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	// Каждая успешная правка сохранила прежний текст
+	rec := serve(handleComment, http.MethodGet, path+"/history", "", 7, "user")
+	var history []CommentEdit
+	if err := json.NewDecoder(rec.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].Content != "текст" || history[2].Content != "правка 9" {
+		t.Errorf("история %+v, want 3 версии от исходного текста", history)
+	}
+
+	if rec := serve(handleComment, http.MethodPatch, "/api/comments/999999", `{"content":"x"}`, 7, "user"); rec.Code != 404 {
+		t.Errorf("правка несуществующего: статус %d, want 404", rec.Code)
+	}
+}
+
+func TestCommentHistoryPermissions(t *testing.T) {
+	useTestDB(t)
+	id := insertComment(t, nil, 7, statusApproved)
+	path := fmt.Sprintf("/api/comments/%d/history", id)
+
+	tests := []struct {
+		name   string
+		userID int
+		role   string
+		want   int
+	}{
+		{name: "автор", userID: 7, role: "user", want: 200},
+		{name: "модератор", userID: 9, role: roleModerator, want: 200},
+		{name: "другой пользователь", userID: 8, role: "user", want: 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(handleComment, http.MethodGet, path, "", tt.userID, tt.role); rec.Code != tt.want {
+				t.Errorf("статус %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteCommentPermissions(t *testing.T) {
+	useTestDB(t)
+
+	tests := []struct {
+		name   string
+		userID int
+		role   string
+		want   int
+	}{
+		{name: "другой пользователь", userID: 8, role: "user", want: 403},
+		{name: "автор", userID: 7, role: "user", want: 200},
+		{name: "модератор", userID: 9, role: roleModerator, want: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := insertComment(t, nil, 7, statusApproved)
+			reply := insertComment(t, &parent, 8, statusApproved)
+			path := fmt.Sprintf("/api/comments/%d", parent)
+
+			rec := serve(handleComment, http.MethodDelete, path, "", tt.userID, tt.role)
+			if rec.Code != tt.want {
+				t.Fatalf("статус %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want != 200 {
+				return
+			}
+			var c Comment
+			if err := json.NewDecoder(rec.Body).Decode(&c); err != nil {
+				t.Fatal(err)
+			}
+			if !c.Deleted {
+				t.Error("комментарий не помечен удаленным")
+			}
+			// Ответ остается в ветке, повторное удаление - конфликт
+			if got := commentStatus(t, reply); got != statusApproved {
+				t.Errorf("статус ответа %q, want approved", got)
+			}
+			if rec := serve(handleComment, http.MethodDelete, path, "", tt.userID, tt.role); rec.Code != 409 {
+				t.Errorf("повторное удаление: статус %d, want 409", rec.Code)
+			}
+			if rec := serve(handleComment, http.MethodPatch, path, `{"content":"x"}`, tt.userID, tt.role); rec.Code != 409 {
+				t.Errorf("правка удаленного: статус %d, want 409", rec.Code)
+			}
+		})
+	}
+}
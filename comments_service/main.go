@@ -2,27 +2,97 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
-	"database/sql"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
+	"comments_service/middleware"
+	"comments_service/tracing"
+
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
 // Комментарий к новости
 type Comment struct {
-	ID        int       `json:"id"`
-	NewsID    int       `json:"news_id"`
-	ParentID  *int      `json:"parent_id,omitempty"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         int        `json:"id"`
+	NewsID     int        `json:"news_id"`
+	ParentID   *int       `json:"parent_id,omitempty"`
+	AuthorID   *int       `json:"author_id,omitempty"`
+	AuthorName string     `json:"author_name,omitempty"`
+	Content    string     `json:"content"`
+	CreatedAt  time.Time  `json:"created_at"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"`
+	// Deleted - комментарий удален: текст заменен на "[deleted]", автор скрыт
+	Deleted bool `json:"deleted,omitempty"`
+	// Status - статус модерации (pending, approved, rejected, hidden)
+	Status string `json:"status"`
+	// ReplyCount - число прямых ответов на комментарий
+	ReplyCount int `json:"reply_count"`
+	Upvotes    int `json:"upvotes"`
+	Downvotes  int `json:"downvotes"`
+	// Reactions - число реакций каждого вида
+	Reactions map[string]int `json:"reactions,omitempty"`
+	// Rank - нижняя граница доверительного интервала Уилсона для доли
+	// положительных голосов, по ней сортирует sort=top
+	Rank float64 `json:"-"`
+}
+
+// Заглушки текста: удаленный или скрытый модератором комментарий остается
+// в ветке, чтобы не терялись ответы на него
+const (
+	deletedPlaceholder = "[deleted]"
+	hiddenPlaceholder  = "[hidden]"
+)
+
+// replyCountSQL - число видимых прямых ответов на комментарий c
+const replyCountSQL = `(SELECT count(*) FROM comments r WHERE r.parent_id = c.id AND
+	(r.status IN ('approved', 'hidden') OR r.status = 'rejected' AND comment_has_published_replies(r.id)))`
+
+// visibleCommentSQL - комментарии, которые показываются в ветках. Отклоненный
+// комментарий с опубликованными ответами остается заглушкой, иначе ветка
+// обрывается на нем. Комментарии к удаленным или скрытым новостям
+// (orphaned_at) не показываются и не считаются
+const visibleCommentSQL = `(c.status IN ('approved', 'hidden') OR c.status = 'rejected' AND comment_has_published_replies(c.id))
+	AND c.orphaned_at IS NULL`
+
+// commentSelectSQL - поля комментария c в том виде, в каком они отдаются клиенту
+const commentSelectSQL = `
+	c.id, c.news_id, c.parent_id,
+	CASE WHEN c.deleted_at IS NULL AND c.status = 'approved' THEN c.author_id END AS author_id,
+	CASE WHEN c.deleted_at IS NULL AND c.status = 'approved' THEN c.author_name ELSE '' END AS author_name,
+	CASE
+		WHEN c.deleted_at IS NOT NULL THEN '` + deletedPlaceholder + `'
+		WHEN c.status <> 'approved' THEN '` + hiddenPlaceholder + `'
+		ELSE c.content
+	END AS content,
+	c.created_at, c.edited_at, c.deleted_at IS NOT NULL AS deleted, c.status,
+	` + replyCountSQL + ` AS reply_count,
+	c.upvotes, c.downvotes, c.reactions, c.vote_rank`
+
+// commentFullSelectSQL - те же поля без скрытия текста и автора,
+// для автора комментария и модераторов
+const commentFullSelectSQL = `
+	c.id, c.news_id, c.parent_id, c.author_id, c.author_name, c.content,
+	c.created_at, c.edited_at, c.deleted_at IS NOT NULL AS deleted, c.status,
+	` + replyCountSQL + ` AS reply_count,
+	c.upvotes, c.downvotes, c.reactions, c.vote_rank`
+
+// commentColumnsSQL - столбцы commentSelectSQL в порядке scanFields
+const commentColumnsSQL = `id, news_id, parent_id, author_id, author_name, content, created_at, edited_at, deleted, status, reply_count,
+	upvotes, downvotes, reactions, vote_rank`
+
+// scanFields возвращает адреса полей в порядке commentColumnsSQL
+func (c *Comment) scanFields() []any {
+	return []any{&c.ID, &c.NewsID, &c.ParentID, &c.AuthorID, &c.AuthorName, &c.Content,
+		&c.CreatedAt, &c.EditedAt, &c.Deleted, &c.Status, &c.ReplyCount,
+		&c.Upvotes, &c.Downvotes, &c.Reactions, &c.Rank}
 }
 
 // Тело запроса для создания комментария
@@ -30,9 +100,18 @@ type CommentRequest struct {
 	NewsID   int    `json:"news_id"`
 	ParentID *int   `json:"parent_id,omitempty"`
 	Content  string `json:"content"`
+	// Автор заполняется из заголовков X-User-*, которые выставляет шлюз
+	AuthorID   *int   `json:"-"`
+	AuthorName string `json:"-"`
+	// Status - pending, если шлюз отправил комментарий на модерацию
+	Status string `json:"-"`
 }
 
-var db *pgxpool.Pool
+var (
+	db *pgxpool.Pool
+	// newsIndex проверяет, что комментарий относится к существующей новости
+	newsIndex *newsChecker
+)
 
 func main() {
 	// Настройка логгера
@@ -63,11 +142,34 @@ func main() {
 		dbName = "comments_db"
 	}
 
+	if v := os.Getenv("REPORT_HIDE_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("Неверное значение REPORT_HIDE_THRESHOLD: %q", v)
+		}
+		reportHideThreshold = n
+	}
+
+	internalToken = os.Getenv("INTERNAL_TOKEN")
+	if internalToken == "" {
+		logrus.Warn("INTERNAL_TOKEN не задан, /internal/comments/recent недоступен")
+	}
+
 	connString := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
 		dbUser, dbPassword, dbHost, dbPort, dbName)
 
-	var err error
-	db, err = pgxpool.New(context.Background(), connString)
+	// Настройка трассировки
+	if _, err := tracing.Init(context.Background(), "comments_service"); err != nil {
+		log.Fatalf("Ошибка настройки трассировки: %v", err)
+	}
+
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		log.Fatalf("Неверные параметры подключения к базе данных: %v", err)
+	}
+	poolConfig.ConnConfig.Tracer = tracing.QueryTracer{}
+
+	db, err = pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		log.Fatalf("Не удалось подключиться к базе данных: %v", err)
 	}
@@ -78,14 +180,31 @@ func main() {
 		log.Fatalf("Ошибка создания таблиц: %v", err)
 	}
 
+	// Проверка новостей в сервисе новостей и периодическая сверка комментариев
+	newsServiceURL := os.Getenv("NEWS_SERVICE_URL")
+	if newsServiceURL == "" {
+		newsServiceURL = "http://localhost:8082"
+	}
+	newsIndex = newNewsChecker(newsServiceURL, envDuration("NEWS_CHECK_CACHE_TTL", 10*time.Minute))
+	if interval := envDuration("NEWS_RECONCILE_INTERVAL", time.Hour); interval > 0 {
+		go runReconciliation(newsIndex, interval)
+	}
+
 	// Настройка HTTP-обработчиков с middleware
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", handleHealth)
 	mux.HandleFunc("/api/comments", handleComments)
+	mux.HandleFunc("/api/comments/", handleComment)
+	mux.HandleFunc("/api/comments/counts", handleCommentCounts)
+	mux.HandleFunc("/api/moderation/comments", handleModerationQueue)
+	mux.HandleFunc("/api/moderation/comments/", handleModerationAction)
+	mux.HandleFunc("/api/moderation/reports", handleReportedComments)
+	mux.HandleFunc("/internal/comments/recent", handleRecentComments)
 
 	// Применение middleware
-	handler := RequestIDMiddleware(mux)
-	handler = LoggingMiddleware(handler)
+	handler := middleware.LoggingMiddleware(mux)
+	handler = middleware.RequestIDMiddleware(handler)
+	handler = tracing.Middleware(handler, "comments_service", tracing.MuxRoute(mux))
 
 	log.Printf("Запуск сервиса комментариев на порту :8081")
 	if err := http.ListenAndServe(":8081", handler); err != nil {
@@ -102,10 +221,96 @@ func createTables() error {
 			content TEXT NOT NULL,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		);
+
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS author_id INTEGER;
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS author_name TEXT NOT NULL DEFAULT '';
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP WITH TIME ZONE;
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_by INTEGER;
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'approved'
+			CHECK (status IN ('pending', 'approved', 'rejected', 'hidden'));
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS moderation_reason TEXT;
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS moderated_by INTEGER;
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMP WITH TIME ZONE;
+		CREATE INDEX IF NOT EXISTS idx_comments_status ON comments(status, created_at);
+		CREATE INDEX IF NOT EXISTS idx_comments_news_id ON comments(news_id);
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS orphaned_at TIMESTAMP WITH TIME ZONE;
+
+		CREATE TABLE IF NOT EXISTS comment_edits (
+			id SERIAL PRIMARY KEY,
+			comment_id INTEGER NOT NULL REFERENCES comments(id),
+			content TEXT NOT NULL,
+			edited_by INTEGER,
+			edited_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_comment_edits_comment_id ON comment_edits(comment_id);
+
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS report_count INTEGER NOT NULL DEFAULT 0;
+		CREATE INDEX IF NOT EXISTS idx_comments_report_count ON comments(report_count, id) WHERE report_count > 0;
+
+		CREATE TABLE IF NOT EXISTS comment_reports (
+			id SERIAL PRIMARY KEY,
+			comment_id INTEGER NOT NULL REFERENCES comments(id),
+			reporter_id INTEGER NOT NULL,
+			reason TEXT NOT NULL CHECK (reason IN ('spam', 'abuse', 'off_topic', 'misinformation', 'other')),
+			details TEXT,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			resolved_at TIMESTAMP WITH TIME ZONE,
+			UNIQUE (comment_id, reporter_id)
+		);
+
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS upvotes INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS downvotes INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS reactions JSONB NOT NULL DEFAULT '{}';
+		-- Нижняя граница интервала Уилсона (z = 1.96) для доли голосов "за"
+		ALTER TABLE comments ADD COLUMN IF NOT EXISTS vote_rank DOUBLE PRECISION GENERATED ALWAYS AS (
+			CASE WHEN upvotes + downvotes = 0 THEN 0 ELSE
+				((upvotes + 1.9208) / (upvotes + downvotes)
+					- 1.96 * sqrt(upvotes::DOUBLE PRECISION * downvotes / (upvotes + downvotes) + 0.9604) / (upvotes + downvotes))
+				/ (1 + 3.8416 / (upvotes + downvotes))
+			END
+		) STORED;
+
+		CREATE TABLE IF NOT EXISTS comment_votes (
+			comment_id INTEGER NOT NULL REFERENCES comments(id),
+			user_id INTEGER NOT NULL,
+			value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (comment_id, user_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS comment_reactions (
+			comment_id INTEGER NOT NULL REFERENCES comments(id),
+			user_id INTEGER NOT NULL,
+			reaction TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (comment_id, user_id, reaction)
+		);
+
+		-- Есть ли у комментария $1 опубликованные или скрытые ответы на любой глубине
+		CREATE OR REPLACE FUNCTION comment_has_published_replies(INTEGER) RETURNS BOOLEAN
+		LANGUAGE SQL STABLE AS $$
+			WITH RECURSIVE sub AS (
+				SELECT id, status FROM comments WHERE parent_id = $1
+				UNION ALL
+				SELECT c.id, c.status FROM comments c JOIN sub ON c.parent_id = sub.id
+			)
+			SELECT EXISTS (SELECT 1 FROM sub WHERE status IN ('approved', 'hidden'))
+		$$;
 	`)
 	return err
 }
 
+func envDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+		logrus.Warnf("Неверное значение %s=%q, используется %s", key, v, def)
+	}
+	return def
+}
+
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
@@ -128,32 +333,120 @@ func handleComments(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleGetComments отдает страницу комментариев новости. Курсор следующей
+// страницы передается в заголовке X-Next-Cursor
 func handleGetComments(w http.ResponseWriter, r *http.Request) {
-	newsID := r.URL.Query().Get("news_id")
-	if newsID == "" {
+	query := r.URL.Query()
+	if query.Get("news_id") == "" {
 		http.Error(w, "Требуется ID новости", http.StatusBadRequest)
 		return
 	}
+	newsID, err := strconv.Atoi(query.Get("news_id"))
+	if err != nil {
+		http.Error(w, "Неверный ID новости", http.StatusBadRequest)
+		return
+	}
+	page, err := parsePageParams(query, sortNew, sortOld, sortTop)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	comments, err := getCommentsByNewsID(newsID)
+	switch query.Get("format") {
+	case "", "flat":
+	case "tree":
+		handleGetCommentTree(w, r, newsID, page)
+		return
+	default:
+		http.Error(w, "Неизвестный формат, допустимы flat и tree", http.StatusBadRequest)
+		return
+	}
+
+	comments, next, err := getCommentsByNewsID(r.Context(), newsID, page)
 	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка получения комментариев")
 		http.Error(w, "Ошибка получения комментариев", http.StatusInternalServerError)
 		return
 	}
 
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(comments)
 }
 
+// handleGetCommentTree отдает комментарии деревом: ?format=tree&depth=5&parent_id=...
+// Сортировка и limit применяются на каждом уровне, курсор - к корням
+func handleGetCommentTree(w http.ResponseWriter, r *http.Request, newsID int, page pageParams) {
+	query := r.URL.Query()
+
+	depth := defaultTreeDepth
+	if v := query.Get("depth"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 1 || d > maxTreeDepth {
+			http.Error(w, fmt.Sprintf("Глубина должна быть от 1 до %d", maxTreeDepth), http.StatusBadRequest)
+			return
+		}
+		depth = d
+	}
+
+	var parentID *int
+	if v := query.Get("parent_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Неверный ID комментария", http.StatusBadRequest)
+			return
+		}
+		parentID = &id
+	}
+
+	tree, next, err := getCommentTree(r.Context(), newsID, parentID, depth, page)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка получения комментариев")
+		http.Error(w, "Ошибка получения комментариев", http.StatusInternalServerError)
+		return
+	}
+
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tree)
+}
+
 func handleCreateComment(w http.ResponseWriter, r *http.Request) {
 	var req CommentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
 		return
 	}
+	if userID, err := strconv.Atoi(r.Header.Get("X-User-ID")); err == nil {
+		req.AuthorID = &userID
+		req.AuthorName = r.Header.Get("X-User-Name")
+	}
+	req.Status = statusApproved
+	if r.Header.Get(moderationStatusHeader) == statusPending {
+		req.Status = statusPending
+	}
+
+	// Если сервис новостей недоступен, комментарий сохраняется: новость
+	// проверит периодическая сверка
+	exists, err := newsIndex.Exists(r.Context(), req.NewsID)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Warn("Не удалось проверить новость")
+	} else if !exists {
+		http.Error(w, "Новость не найдена", http.StatusNotFound)
+		return
+	}
 
-	comment, err := createComment(req)
+	comment, err := createComment(r.Context(), req)
 	if err != nil {
+		if errors.Is(err, errInvalidParent) {
+			http.Error(w, "Родительский комментарий не найден, не опубликован или относится к другой новости", http.StatusBadRequest)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка создания комментария")
 		http.Error(w, "Ошибка создания комментария", http.StatusInternalServerError)
 		return
 	}
@@ -163,98 +456,65 @@ func handleCreateComment(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(comment)
 }
 
-func getCommentsByNewsID(newsID string) ([]Comment, error) {
-	rows, err := db.Query(context.Background(), `
-		SELECT id, news_id, parent_id, content, created_at
-		FROM comments
-		WHERE news_id = $1
-		ORDER BY created_at DESC
-	`, newsID)
+func getCommentsByNewsID(ctx context.Context, newsID int, page pageParams) ([]Comment, string, error) {
+	cursorCond, args := cursorCondition(page.cursor, []any{newsID, page.limit + 1})
+	rows, err := db.Query(ctx, `
+		WITH `+rankedCommentsSQL+`
+		SELECT `+commentColumnsSQL+`
+		FROM ranked
+		WHERE `+cursorCond+`
+		ORDER BY `+orderBy(page.order)+`
+		LIMIT $2
+	`, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
-	var comments []Comment
+	comments := []Comment{}
 	for rows.Next() {
 		var c Comment
-		var parentID sql.NullInt64
-		if err := rows.Scan(&c.ID, &c.NewsID, &parentID, &c.Content, &c.CreatedAt); err != nil {
-			return nil, err
-		}
-		if parentID.Valid {
-			pid := int(parentID.Int64)
-			c.ParentID = &pid
+		if err := rows.Scan(c.scanFields()...); err != nil {
+			return nil, "", err
 		}
 		comments = append(comments, c)
 	}
-	return comments, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	// Строк запрашивается на одну больше, чтобы узнать, есть ли следующая страница
+	next := ""
+	if len(comments) > page.limit {
+		comments = comments[:page.limit]
+		next = encodeCursor(page.order, comments[len(comments)-1])
+	}
+	return comments, next, nil
 }
 
-func createComment(req CommentRequest) (*Comment, error) {
+// errInvalidParent - ответ на несуществующий, неопубликованный или чужой новости комментарий
+var errInvalidParent = errors.New("неверный родительский комментарий")
+
+func createComment(ctx context.Context, req CommentRequest) (*Comment, error) {
+	// Ответ вставляется, только если родитель опубликован и относится к той же новости
 	var comment Comment
-	err := db.QueryRow(context.Background(), `
-		INSERT INTO comments (news_id, parent_id, content)
-		VALUES ($1, $2, $3)
-		RETURNING id, news_id, parent_id, content, created_at
-	`, req.NewsID, req.ParentID, req.Content).Scan(
-		&comment.ID, &comment.NewsID, &comment.ParentID, &comment.Content, &comment.CreatedAt,
+	err := db.QueryRow(ctx, `
+		INSERT INTO comments (news_id, parent_id, author_id, author_name, content, status)
+		SELECT $1::INTEGER, $2::INTEGER, $3::INTEGER, $4::TEXT, $5::TEXT, $6::TEXT
+		WHERE $2::INTEGER IS NULL OR EXISTS (
+			SELECT 1 FROM comments
+			WHERE id = $2 AND news_id = $1 AND deleted_at IS NULL AND status = 'approved'
+		)
+		RETURNING id, news_id, parent_id, author_id, author_name, content, created_at, status
+	`, req.NewsID, req.ParentID, req.AuthorID, req.AuthorName, req.Content, req.Status).Scan(
+		&comment.ID, &comment.NewsID, &comment.ParentID, &comment.AuthorID, &comment.AuthorName,
+		&comment.Content, &comment.CreatedAt, &comment.Status,
 	)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errInvalidParent
+		}
 		return nil, err
 	}
 	return &comment, nil
 }
-
-// Middleware для добавления ID запроса в контекст
-func RequestIDMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestID := r.Header.Get("X-Request-ID")
-		if requestID == "" {
-			requestID = generateRequestID()
-		}
-		w.Header().Set("X-Request-ID", requestID)
-		next.ServeHTTP(w, r)
-	})
-}
-
-// Middleware для логирования информации о каждом запросе
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		requestID := r.Header.Get("X-Request-ID")
-
-		rw := &responseWriter{
-			ResponseWriter: w,
-			statusCode:     http.StatusOK,
-		}
-
-		next.ServeHTTP(rw, r)
-
-		duration := time.Since(start)
-		logrus.WithFields(logrus.Fields{
-			"method":     r.Method,
-			"path":       r.URL.Path,
-			"status":     rw.statusCode,
-			"duration":   duration,
-			"request_id": requestID,
-			"remote_ip":  r.RemoteAddr,
-		}).Info("Request processed")
-	})
-}
-
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
-}
-
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
-}
-
-func generateRequestID() string {
-	b := make([]byte, 8)
-	rand.Read(b)
-	return hex.EncodeToString(b)
-}
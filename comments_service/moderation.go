@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"comments_service/middleware"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Статусы модерации комментария
+const (
+	statusPending  = "pending"
+	statusApproved = "approved"
+	statusRejected = "rejected"
+	statusHidden   = "hidden"
+)
+
+// moderationStatusHeader - шлюз выставляет pending, если сервис цензуры
+// счел комментарий подозрительным и политика требует модерации
+const moderationStatusHeader = "X-Moderation-Status"
+
+// moderationActions - действие модератора, итоговый статус и статусы, из которых оно допустимо
+var moderationActions = map[string]struct {
+	status string
+	from   []string
+	reason bool
+}{
+	"approve": {status: statusApproved, from: []string{statusPending, statusRejected, statusHidden}},
+	"reject":  {status: statusRejected, from: []string{statusPending, statusHidden}, reason: true},
+	"hide":    {status: statusHidden, from: []string{statusApproved}, reason: true},
+}
+
+var errWrongStatus = errors.New("действие недопустимо для текущего статуса")
+
+// Комментарий с данными модерации
+type ModeratedComment struct {
+	Comment
+	Reason      *string    `json:"moderation_reason,omitempty"`
+	ModeratedBy *int       `json:"moderated_by,omitempty"`
+	ModeratedAt *time.Time `json:"moderated_at,omitempty"`
+}
+
+// moderatedSelectSQL - commentFullSelectSQL и поля модерации в порядке scanFields
+const moderatedSelectSQL = commentFullSelectSQL + `,
+	c.moderation_reason, c.moderated_by, c.moderated_at`
+
+func (m *ModeratedComment) scanFields() []any {
+	return append(m.Comment.scanFields(), &m.Reason, &m.ModeratedBy, &m.ModeratedAt)
+}
+
+// Тело запроса действия модератора
+type ModerationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// handleModerationQueue - GET /api/moderation/comments: очередь модерации.
+// По умолчанию - ожидающие решения: pending и скрытые автоматически по жалобам.
+// Фильтры ?status=, news_id, author_id; старые комментарии первыми
+func handleModerationQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	if who, ok := actorFromRequest(r); !ok || !who.isModerator() {
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	// Комментарии к удаленным новостям модерировать не нужно
+	conds := []string{"c.orphaned_at IS NULL"}
+	var args []any
+	switch status := query.Get("status"); status {
+	case "":
+		conds = append(conds, "(c.status = 'pending' OR (c.status = 'hidden' AND c.moderated_by IS NULL))")
+	case statusPending, statusApproved, statusRejected, statusHidden:
+		args = append(args, status)
+		conds = append(conds, "c.status = $1")
+	default:
+		http.Error(w, "Неизвестный статус", http.StatusBadRequest)
+		return
+	}
+	for _, name := range []string{"news_id", "author_id"} {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Неверный %s", name), http.StatusBadRequest)
+			return
+		}
+		args = append(args, id)
+		conds = append(conds, fmt.Sprintf("c.%s = $%d", name, len(args)))
+	}
+
+	// Очередь всегда идет от старых к новым
+	query.Set("sort", sortOld)
+	page, err := parsePageParams(query, sortOld)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	items, next, err := getModerationQueue(r.Context(), conds, args, page)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка получения очереди модерации")
+		http.Error(w, "Ошибка получения очереди модерации", http.StatusInternalServerError)
+		return
+	}
+
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// handleModerationAction - POST /api/moderation/comments/{id}/{approve|reject|hide}
+func handleModerationAction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/moderation/comments/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	action, ok := moderationActions[parts[1]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := actorFromRequest(r)
+	if !ok || !who.isModerator() {
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+		return
+	}
+	commentID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Неверный ID комментария", http.StatusBadRequest)
+		return
+	}
+
+	var req ModerationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+			return
+		}
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if action.reason && req.Reason == "" {
+		http.Error(w, "Требуется причина", http.StatusBadRequest)
+		return
+	}
+
+	comment, err := moderateComment(r.Context(), commentID, who, action.status, action.from, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, errCommentNotFound):
+			http.Error(w, "Комментарий не найден", http.StatusNotFound)
+		case errors.Is(err, errWrongStatus):
+			http.Error(w, "Действие недопустимо для текущего статуса комментария", http.StatusConflict)
+		default:
+			middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка модерации комментария")
+			http.Error(w, "Ошибка модерации комментария", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+func getModerationQueue(ctx context.Context, conds []string, args []any, page pageParams) ([]ModeratedComment, string, error) {
+	cursorCond, args := cursorCondition(page.cursor, args)
+	args = append(args, page.limit+1)
+	rows, err := db.Query(ctx, `
+		SELECT `+moderatedSelectSQL+`
+		FROM comments c
+		WHERE `+strings.Join(conds, " AND ")+` AND `+cursorCond+`
+		ORDER BY `+orderBy(sortOld)+`
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	items := []ModeratedComment{}
+	for rows.Next() {
+		var m ModeratedComment
+		if err := rows.Scan(m.scanFields()...); err != nil {
+			return nil, "", err
+		}
+		items = append(items, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(items) > page.limit {
+		items = items[:page.limit]
+		next = encodeCursor(sortOld, items[len(items)-1].Comment)
+	}
+	return items, next, nil
+}
+
+// moderateComment переводит комментарий в status, если текущий статус входит в from.
+// Открытые жалобы на комментарий считаются рассмотренными
+func moderateComment(ctx context.Context, commentID int, who actor, status string, from []string, reason string) (*ModeratedComment, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var m ModeratedComment
+	err = tx.QueryRow(ctx, `
+		UPDATE comments c
+		SET status = $2, moderation_reason = NULLIF($3, ''), moderated_by = $4, moderated_at = CURRENT_TIMESTAMP,
+			report_count = 0
+		WHERE c.id = $1 AND c.status = ANY($5)
+		RETURNING `+moderatedSelectSQL,
+		commentID, status, reason, who.userID, from).Scan(m.scanFields()...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Строка не обновлена: комментария нет или статус не подходит
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1)`, commentID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, errCommentNotFound
+		}
+		return nil, errWrongStatus
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE comment_reports SET resolved_at = CURRENT_TIMESTAMP
+		WHERE comment_id = $1 AND resolved_at IS NULL
+	`, commentID); err != nil {
+		return nil, err
+	}
+	return &m, tx.Commit(ctx)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestModerationBeforeDB(t *testing.T) {
+	// Запросы отклоняются до обращения к базе
+	tests := []struct {
+		name   string
+		h      http.HandlerFunc
+		method string
+		path   string
+		body   string
+		userID int
+		role   string
+		want   int
+	}{
+		{name: "очередь без пользователя", h: handleModerationQueue, method: http.MethodGet, path: "/api/moderation/comments", want: 403},
+		{name: "очередь - пользователь", h: handleModerationQueue, method: http.MethodGet, path: "/api/moderation/comments", userID: 7, role: "user", want: 403},
+		{name: "очередь - метод", h: handleModerationQueue, method: http.MethodPost, path: "/api/moderation/comments", userID: 9, role: roleModerator, want: 405},
+		{name: "очередь - неизвестный статус", h: handleModerationQueue, method: http.MethodGet, path: "/api/moderation/comments?status=spam", userID: 9, role: roleModerator, want: 400},
+		{name: "очередь - неверный news_id", h: handleModerationQueue, method: http.MethodGet, path: "/api/moderation/comments?news_id=x", userID: 9, role: roleAdmin, want: 400},
+		{name: "действие без пользователя", h: handleModerationAction, method: http.MethodPost, path: "/api/moderation/comments/1/approve", want: 403},
+		{name: "действие - пользователь", h: handleModerationAction, method: http.MethodPost, path: "/api/moderation/comments/1/approve", userID: 7, role: "user", want: 403},
+		{name: "неизвестное действие", h: handleModerationAction, method: http.MethodPost, path: "/api/moderation/comments/1/delete", userID: 9, role: roleModerator, want: 404},
+		{name: "действие - метод", h: handleModerationAction, method: http.MethodGet, path: "/api/moderation/comments/1/approve", userID: 9, role: roleModerator, want: 405},
+		{name: "действие - неверный ID", h: handleModerationAction, method: http.MethodPost, path: "/api/moderation/comments/x/approve", userID: 9, role: roleModerator, want: 400},
+		{name: "отклонение без причины", h: handleModerationAction, method: http.MethodPost, path: "/api/moderation/comments/1/reject", body: `{"reason":" "}`, userID: 9, role: roleModerator, want: 400},
+		{name: "скрытие без причины", h: handleModerationAction, method: http.MethodPost, path: "/api/moderation/comments/1/hide", userID: 9, role: roleModerator, want: 400},
+		{name: "неверное тело", h: handleModerationAction, method: http.MethodPost, path: "/api/moderation/comments/1/hide", body: `{`, userID: 9, role: roleModerator, want: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(tt.h, tt.method, tt.path, tt.body, tt.userID, tt.role); rec.Code != tt.want {
+				t.Errorf("статус %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestModerationTransitions(t *testing.T) {
+	useTestDB(t)
+
+	statuses := []string{statusPending, statusApproved, statusRejected, statusHidden}
+	allowed := map[string][]string{
+		"approve": {statusPending, statusRejected, statusHidden},
+		"reject":  {statusPending, statusHidden},
+		"hide":    {statusApproved},
+	}
+	for action, from := range allowed {
+		for _, status := range statuses {
+			t.Run(action+" из "+status, func(t *testing.T) {
+				id := insertComment(t, nil, 7, status)
+				path := fmt.Sprintf("/api/moderation/comments/%d/%s", id, action)
+				rec := serve(handleModerationAction, http.MethodPost, path, `{"reason":"причина"}`, 9, roleModerator)
+
+				want, wantStatus := http.StatusConflict, status
+				for _, s := range from {
+					if s == status {
+						want, wantStatus = http.StatusOK, moderationActions[action].status
+					}
+				}
+				if rec.Code != want {
+					t.Fatalf("статус ответа %d, want %d: %s", rec.Code, want, rec.Body)
+				}
+				if got := commentStatus(t, id); got != wantStatus {
+					t.Errorf("статус комментария %q, want %q", got, wantStatus)
+				}
+			})
+		}
+	}
+
+	rec := serve(handleModerationAction, http.MethodPost, "/api/moderation/comments/999999/approve", "", 9, roleModerator)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("несуществующий комментарий: статус %d, want 404", rec.Code)
+	}
+}
+
+func TestModerationQueue(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+
+	pending := insertComment(t, nil, 7, statusPending)
+	insertComment(t, nil, 7, statusApproved)
+	autoHidden := insertComment(t, nil, 7, statusHidden)
+	hiddenByModerator := insertComment(t, nil, 7, statusHidden)
+	if _, err := db.Exec(ctx, `UPDATE comments SET moderated_by = 9 WHERE id = $1`, hiddenByModerator); err != nil {
+		t.Fatal(err)
+	}
+
+	// По умолчанию в очереди ожидающие решения: pending и скрытые по жалобам
+	rec := serve(handleModerationQueue, http.MethodGet, "/api/moderation/comments", "", 9, roleModerator)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("статус %d, want 200", rec.Code)
+	}
+	var items []ModeratedComment
+	if err := json.NewDecoder(rec.Body).Decode(&items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].ID != pending || items[1].ID != autoHidden {
+		t.Errorf("очередь %+v, want комментарии %d и %d", items, pending, autoHidden)
+	}
+
+	// Решение модератора убирает комментарий из очереди и закрывает жалобы
+	if _, err := db.Exec(ctx, `
+		INSERT INTO comment_reports (comment_id, reporter_id, reason) VALUES ($1, 8, 'spam')
+	`, autoHidden); err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/api/moderation/comments/%d/approve", autoHidden)
+	if rec := serve(handleModerationAction, http.MethodPost, path, "", 9, roleModerator); rec.Code != http.StatusOK {
+		t.Fatalf("одобрение: статус %d, want 200", rec.Code)
+	}
+	var open int
+	if err := db.QueryRow(ctx, `
+		SELECT count(*) FROM comment_reports WHERE comment_id = $1 AND resolved_at IS NULL
+	`, autoHidden).Scan(&open); err != nil {
+		t.Fatal(err)
+	}
+	if open != 0 {
+		t.Errorf("открытых жалоб %d, want 0", open)
+	}
+
+	rec = serve(handleModerationQueue, http.MethodGet, "/api/moderation/comments", "", 9, roleModerator)
+	items = nil
+	if err := json.NewDecoder(rec.Body).Decode(&items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].ID != pending {
+		t.Errorf("очередь после одобрения %+v, want только %d", items, pending)
+	}
+}
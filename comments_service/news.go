@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"comments_service/middleware"
+	"comments_service/tracing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newsExistsBatch - сколько ID проверяется одним запросом к сервису новостей
+const newsExistsBatch = 100
+
+// missingNewsTTL - сколько помнить отсутствующую новость: она может появиться
+// при следующей загрузке лент
+const missingNewsTTL = 30 * time.Second
+
+// newsChecker проверяет существование новостей в сервисе новостей.
+// Базы сервисов раздельные, поэтому внешнего ключа на news_id нет
+type newsChecker struct {
+	baseURL string
+	client  *http.Client
+	ttl     time.Duration
+
+	mu    sync.Mutex
+	known map[int]newsEntry
+}
+
+type newsEntry struct {
+	exists  bool
+	expires time.Time
+}
+
+func newNewsChecker(baseURL string, ttl time.Duration) *newsChecker {
+	return &newsChecker{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 2 * time.Second, Transport: tracing.Transport(http.DefaultTransport)},
+		ttl:     ttl,
+		known:   make(map[int]newsEntry),
+	}
+}
+
+// Exists сообщает, существует ли новость. Ответ сервиса новостей кэшируется
+func (c *newsChecker) Exists(ctx context.Context, newsID int) (bool, error) {
+	now := time.Now()
+	c.mu.Lock()
+	e, ok := c.known[newsID]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.exists, nil
+	}
+
+	found, err := c.fetch(ctx, []int{newsID})
+	if err != nil {
+		return false, err
+	}
+	exists := found[newsID]
+
+	ttl := c.ttl
+	if !exists {
+		ttl = missingNewsTTL
+	}
+	c.mu.Lock()
+	// Устаревшие записи удаляются, когда их становится много
+	if len(c.known) >= 10000 {
+		for id, e := range c.known {
+			if now.After(e.expires) {
+				delete(c.known, id)
+			}
+		}
+	}
+	c.known[newsID] = newsEntry{exists: exists, expires: now.Add(ttl)}
+	c.mu.Unlock()
+	return exists, nil
+}
+
+// fetch запрашивает у сервиса новостей, какие из ids существуют (не больше newsExistsBatch)
+func (c *newsChecker) fetch(ctx context.Context, ids []int) (map[int]bool, error) {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	query := url.Values{"ids": {strings.Join(parts, ",")}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/internal/news/exists?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if id := middleware.RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(middleware.RequestIDHeader, id)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("неожиданный статус ответа сервиса новостей: %d", resp.StatusCode)
+	}
+
+	var body map[string]bool
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	found := make(map[int]bool, len(body))
+	for key, exists := range body {
+		if id, err := strconv.Atoi(key); err == nil {
+			found[id] = exists
+		}
+	}
+	return found, nil
+}
+
+// runReconciliation периодически помечает комментарии к новостям, которых нет
+// в сервисе новостей (например, комментарий сохранен, пока сервис был недоступен)
+func runReconciliation(checker *newsChecker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		orphaned, restored, err := reconcileComments(context.Background(), checker)
+		if err != nil {
+			logrus.WithError(err).Warn("Ошибка сверки комментариев с новостями")
+		} else if orphaned > 0 || restored > 0 {
+			logrus.WithFields(logrus.Fields{
+				"orphaned": orphaned,
+				"restored": restored,
+			}).Info("Сверка комментариев с новостями завершена")
+		}
+		<-ticker.C
+	}
+}
+
+// reconcileComments выставляет orphaned_at комментариям к несуществующим
+// новостям и снимает отметку, если новость нашлась. Возвращает число
+// помеченных и восстановленных комментариев
+func reconcileComments(ctx context.Context, checker *newsChecker) (orphaned, restored int64, err error) {
+	rows, err := db.Query(ctx, `SELECT DISTINCT news_id FROM comments`)
+	if err != nil {
+		return 0, 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	var missing, present []int
+	for start := 0; start < len(ids); start += newsExistsBatch {
+		batch := ids[start:min(start+newsExistsBatch, len(ids))]
+		found, err := checker.fetch(ctx, batch)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, id := range batch {
+			if found[id] {
+				present = append(present, id)
+			} else {
+				missing = append(missing, id)
+			}
+		}
+	}
+
+	tag, err := db.Exec(ctx, `
+		UPDATE comments SET orphaned_at = CURRENT_TIMESTAMP
+		WHERE news_id = ANY($1) AND orphaned_at IS NULL
+	`, missing)
+	if err != nil {
+		return 0, 0, err
+	}
+	orphaned = tag.RowsAffected()
+
+	tag, err = db.Exec(ctx, `
+		UPDATE comments SET orphaned_at = NULL
+		WHERE news_id = ANY($1) AND orphaned_at IS NOT NULL
+	`, present)
+	if err != nil {
+		return orphaned, 0, err
+	}
+	return orphaned, tag.RowsAffected(), nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"comments_service/middleware"
+)
+
+// fakeNewsService отвечает на /internal/news/exists: существуют новости из exists
+func fakeNewsService(t *testing.T, exists map[string]bool, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/internal/news/exists" {
+			t.Errorf("путь %q, want /internal/news/exists", r.URL.Path)
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		resp := map[string]bool{}
+		for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+			resp[id] = exists[id]
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestNewsCheckerExists(t *testing.T) {
+	srv, calls := fakeNewsService(t, map[string]bool{"1": true}, http.StatusOK)
+	c := newNewsChecker(srv.URL+"/", time.Hour)
+
+	tests := []struct {
+		newsID int
+		want   bool
+		calls  int32 // обращений к сервису новостей после проверки
+	}{
+		{newsID: 1, want: true, calls: 1},
+		{newsID: 1, want: true, calls: 1}, // из кэша
+		{newsID: 2, want: false, calls: 2},
+		{newsID: 2, want: false, calls: 2},
+	}
+	for i, tt := range tests {
+		got, err := c.Exists(context.Background(), tt.newsID)
+		if err != nil {
+			t.Fatalf("проверка %d: %v", i, err)
+		}
+		if got != tt.want || calls.Load() != tt.calls {
+			t.Errorf("проверка %d: Exists(%d) = %v, обращений %d, want %v и %d",
+				i, tt.newsID, got, calls.Load(), tt.want, tt.calls)
+		}
+	}
+
+	// Отсутствующая новость может появиться, поэтому помнится недолго
+	if ttl := time.Until(c.known[2].expires); ttl > missingNewsTTL {
+		t.Errorf("отсутствующая новость кэшируется на %s, want не больше %s", ttl, missingNewsTTL)
+	}
+}
+
+func TestNewsCheckerError(t *testing.T) {
+	srv, _ := fakeNewsService(t, nil, http.StatusInternalServerError)
+	c := newNewsChecker(srv.URL, time.Hour)
+	if _, err := c.Exists(context.Background(), 1); err == nil {
+		t.Error("ошибка сервиса новостей не возвращена")
+	}
+	if _, ok := c.known[1]; ok {
+		t.Error("ответ с ошибкой закэширован")
+	}
+}
+
+func TestNewsCheckerRequestID(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(middleware.RequestIDHeader)
+		w.Write([]byte(`{"1": true}`))
+	}))
+	defer srv.Close()
+
+	h := middleware.RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		newNewsChecker(srv.URL, time.Hour).Exists(r.Context(), 1)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/api/comments", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "req-1" {
+		t.Errorf("X-Request-ID = %q, want req-1", got)
+	}
+}
+
+// useNewsService подменяет проверку новостей для обработчиков
+func useNewsService(t *testing.T, url string) {
+	t.Helper()
+	old := newsIndex
+	newsIndex = newNewsChecker(url, time.Hour)
+	t.Cleanup(func() { newsIndex = old })
+}
+
+func TestCreateCommentMissingNews(t *testing.T) {
+	// Комментарий к несуществующей новости отклоняется до записи в базу
+	srv, _ := fakeNewsService(t, map[string]bool{"1": true}, http.StatusOK)
+	useNewsService(t, srv.URL)
+
+	rec := serve(handleComments, http.MethodPost, "/api/comments", `{"news_id": 2, "content": "текст"}`, 7, "user")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("статус %d, want 404", rec.Code)
+	}
+}
+
+func TestCreateCommentNewsCheck(t *testing.T) {
+	useTestDB(t)
+	srv, _ := fakeNewsService(t, map[string]bool{"1": true}, http.StatusOK)
+	down, _ := fakeNewsService(t, nil, http.StatusServiceUnavailable)
+
+	tests := []struct {
+		name string
+		url  string
+		body string
+		want int
+	}{
+		{name: "новость существует", url: srv.URL, body: `{"news_id": 1, "content": "текст"}`, want: 201},
+		{name: "новости нет", url: srv.URL, body: `{"news_id": 2, "content": "текст"}`, want: 404},
+		// Проверит периодическая сверка
+		{name: "сервис новостей недоступен", url: down.URL, body: `{"news_id": 2, "content": "текст"}`, want: 201},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsService(t, tt.url)
+			if rec := serve(handleComments, http.MethodPost, "/api/comments", tt.body, 7, "user"); rec.Code != tt.want {
+				t.Errorf("статус %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestReconcileComments(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+	exists := map[string]bool{"1": true}
+	srv, _ := fakeNewsService(t, exists, http.StatusOK)
+	checker := newNewsChecker(srv.URL, time.Hour)
+
+	insertComment(t, nil, 7, statusApproved) // новость 1
+	if _, err := db.Exec(ctx, `
+		INSERT INTO comments (news_id, content, status) VALUES (2, 'текст', 'approved'), (2, 'текст', 'approved')
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	orphaned, restored, err := reconcileComments(ctx, checker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orphaned != 2 || restored != 0 {
+		t.Errorf("помечено %d, восстановлено %d, want 2 и 0", orphaned, restored)
+	}
+	counts, err := getCommentCounts(ctx, []int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["1"] != 1 || counts["2"] != 0 {
+		t.Errorf("счетчики %v, want 1:1 2:0", counts)
+	}
+
+	// Новость нашлась - комментарии возвращаются
+	exists["2"] = true
+	if orphaned, restored, err = reconcileComments(ctx, checker); err != nil {
+		t.Fatal(err)
+	}
+	if orphaned != 0 || restored != 2 {
+		t.Errorf("помечено %d, восстановлено %d, want 0 и 2", orphaned, restored)
+	}
+}
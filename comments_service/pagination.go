@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Порядок комментариев
+const (
+	sortNew = "new"
+	sortOld = "old"
+	// sortTop - сначала лучшие по голосам (rank, интервал Уилсона)
+	sortTop = "top"
+	// sortReports - сначала комментарии с наибольшим числом жалоб (для модераторов)
+	sortReports = "reports"
+)
+
+const (
+	// Размер страницы по умолчанию и максимальный
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// rankedCommentsSQL - видимые комментарии новости $1 с числом прямых ответов.
+// Столбцы vote_rank, created_at и id используются для сортировки и курсора
+const rankedCommentsSQL = `
+	ranked AS (
+		SELECT ` + commentSelectSQL + `
+		FROM comments c
+		WHERE c.news_id = $1 AND ` + visibleCommentSQL + `
+	)`
+
+// pageParams - параметры страницы: ?sort=...&limit=50&cursor=...
+type pageParams struct {
+	order  string
+	limit  int
+	cursor *pageCursor
+}
+
+// pageCursor - позиция последнего комментария страницы. Клиенту передается
+// в непрозрачном виде (base64 от JSON)
+type pageCursor struct {
+	Sort        string    `json:"s"`
+	Rank        float64   `json:"w,omitempty"`
+	ReportCount int       `json:"c,omitempty"`
+	CreatedAt   time.Time `json:"t"`
+	ID          int       `json:"i"`
+}
+
+// errBadPageParams - ошибка в параметрах страницы, текст показывается клиенту
+type errBadPageParams struct{ msg string }
+
+func (e errBadPageParams) Error() string { return e.msg }
+
+// parsePageParams разбирает параметры страницы. sorts - допустимые значения
+// sort, первое используется по умолчанию
+func parsePageParams(query url.Values, sorts ...string) (pageParams, error) {
+	p := pageParams{order: query.Get("sort"), limit: defaultPageLimit}
+
+	if p.order == "" {
+		p.order = sorts[0]
+	}
+	if !slices.Contains(sorts, p.order) {
+		return p, errBadPageParams{fmt.Sprintf("Неизвестная сортировка, допустимы %s", strings.Join(sorts, ", "))}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageLimit {
+			return p, errBadPageParams{fmt.Sprintf("limit должен быть от 1 до %d", maxPageLimit)}
+		}
+		p.limit = n
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil || c.Sort != p.order {
+			return p, errBadPageParams{"Неверный курсор"}
+		}
+		p.cursor = c
+	}
+	return p, nil
+}
+
+// orderBy - порядок строк ranked для сортировки order
+func orderBy(order string) string {
+	switch order {
+	case sortOld:
+		return "created_at ASC, id ASC"
+	case sortTop:
+		return "vote_rank DESC, created_at DESC, id DESC"
+	case sortReports:
+		return "report_count DESC, id DESC"
+	default:
+		return "created_at DESC, id DESC"
+	}
+}
+
+// cursorCondition возвращает условие "строка после курсора" и дописывает его аргументы
+func cursorCondition(c *pageCursor, args []any) (string, []any) {
+	if c == nil {
+		return "TRUE", args
+	}
+	n := len(args)
+	switch c.Sort {
+	case sortOld:
+		return fmt.Sprintf("(created_at, id) > ($%d, $%d)", n+1, n+2), append(args, c.CreatedAt, c.ID)
+	case sortTop:
+		return fmt.Sprintf("(vote_rank, created_at, id) < ($%d, $%d, $%d)", n+1, n+2, n+3),
+			append(args, c.Rank, c.CreatedAt, c.ID)
+	case sortReports:
+		return fmt.Sprintf("(report_count, id) < ($%d, $%d)", n+1, n+2), append(args, c.ReportCount, c.ID)
+	default:
+		return fmt.Sprintf("(created_at, id) < ($%d, $%d)", n+1, n+2), append(args, c.CreatedAt, c.ID)
+	}
+}
+
+func encodeCursor(order string, c Comment) string {
+	return pageCursor{Sort: order, Rank: c.Rank, CreatedAt: c.CreatedAt, ID: c.ID}.encode()
+}
+
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if c.ID <= 0 {
+		return nil, errors.New("пустой курсор")
+	}
+	return &c, nil
+}
+
+// lessComment сравнивает комментарии так же, как orderBy
+func lessComment(a, b Comment, order string) bool {
+	switch order {
+	case sortOld:
+		a, b = b, a
+	case sortTop:
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+	}
+	if a.CreatedAt.Equal(b.CreatedAt) {
+		return a.ID > b.ID
+	}
+	return a.CreatedAt.After(b.CreatedAt)
+}
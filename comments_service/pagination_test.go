@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePageParams(t *testing.T) {
+	valid := pageCursor{Sort: sortNew, CreatedAt: time.Now(), ID: 7}.encode()
+	tests := []struct {
+		name      string
+		query     string
+		wantOrder string
+		wantLimit int
+		wantID    int // ID курсора, 0 - без курсора
+		wantErr   string
+	}{
+		{name: "по умолчанию", query: "", wantOrder: sortNew, wantLimit: defaultPageLimit},
+		{name: "сортировка и limit", query: "sort=top&limit=10", wantOrder: sortTop, wantLimit: 10},
+		{name: "максимальный limit", query: "limit=200", wantOrder: sortNew, wantLimit: maxPageLimit},
+		{name: "курсор", query: "cursor=" + valid, wantOrder: sortNew, wantLimit: defaultPageLimit, wantID: 7},
+		{name: "неизвестная сортировка", query: "sort=random", wantErr: "Неизвестная сортировка"},
+		{name: "limit не число", query: "limit=abc", wantErr: "limit должен быть"},
+		{name: "нулевой limit", query: "limit=0", wantErr: "limit должен быть"},
+		{name: "limit больше максимума", query: "limit=201", wantErr: "limit должен быть"},
+		{name: "курсор другой сортировки", query: "sort=old&cursor=" + valid, wantErr: "Неверный курсор"},
+		{name: "не base64", query: "cursor=***", wantErr: "Неверный курсор"},
+		{name: "не JSON", query: "cursor=" + "bm90IGpzb24", wantErr: "Неверный курсор"},
+		{name: "курсор без ID", query: "cursor=" + pageCursor{Sort: sortNew}.encode(), wantErr: "Неверный курсор"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := parsePageParams(query, sortNew, sortOld, sortTop)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ошибка %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.order != tt.wantOrder || p.limit != tt.wantLimit {
+				t.Errorf("sort=%s limit=%d, want sort=%s limit=%d", p.order, p.limit, tt.wantOrder, tt.wantLimit)
+			}
+			gotID := 0
+			if p.cursor != nil {
+				gotID = p.cursor.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("ID курсора %d, want %d", gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	c := Comment{ID: 42, CreatedAt: created, Rank: 0.8125}
+	got, err := decodeCursor(encodeCursor(sortTop, c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Sort != sortTop || got.ID != 42 || got.Rank != 0.8125 || !got.CreatedAt.Equal(created) {
+		t.Errorf("курсор %+v не совпадает с исходным комментарием", got)
+	}
+}
+
+func TestCursorCondition(t *testing.T) {
+	created := time.Now()
+	tests := []struct {
+		cursor   *pageCursor
+		want     string
+		wantArgs int
+	}{
+		{cursor: nil, want: "TRUE", wantArgs: 2},
+		{cursor: &pageCursor{Sort: sortNew, CreatedAt: created, ID: 1}, want: "(created_at, id) < ($3, $4)", wantArgs: 4},
+		{cursor: &pageCursor{Sort: sortOld, CreatedAt: created, ID: 1}, want: "(created_at, id) > ($3, $4)", wantArgs: 4},
+		{cursor: &pageCursor{Sort: sortTop, Rank: 0.5, CreatedAt: created, ID: 1}, want: "(vote_rank, created_at, id) < ($3, $4, $5)", wantArgs: 5},
+		{cursor: &pageCursor{Sort: sortReports, ReportCount: 3, ID: 1}, want: "(report_count, id) < ($3, $4)", wantArgs: 4},
+	}
+	for _, tt := range tests {
+		cond, args := cursorCondition(tt.cursor, []any{1, 2})
+		if cond != tt.want || len(args) != tt.wantArgs {
+			t.Errorf("условие %q с %d аргументами, want %q с %d", cond, len(args), tt.want, tt.wantArgs)
+		}
+	}
+}
+
+func TestLessComment(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	comments := []Comment{
+		{ID: 1, CreatedAt: base, Rank: 0.2},
+		{ID: 2, CreatedAt: base.Add(time.Minute), Rank: 0.9},
+		{ID: 3, CreatedAt: base.Add(time.Minute), Rank: 0.2},
+		{ID: 4, CreatedAt: base.Add(2 * time.Minute), Rank: 0},
+	}
+	// Порядок должен совпадать с orderBy: при равном времени решает id
+	tests := []struct {
+		order string
+		want  []int
+	}{
+		{order: sortNew, want: []int{4, 3, 2, 1}},
+		{order: sortOld, want: []int{1, 2, 3, 4}},
+		{order: sortTop, want: []int{2, 3, 1, 4}},
+	}
+	for _, tt := range tests {
+		sorted := append([]Comment(nil), comments...)
+		sort.Slice(sorted, func(i, j int) bool { return lessComment(sorted[i], sorted[j], tt.order) })
+		var got []int
+		for _, c := range sorted {
+			got = append(got, c.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("sort=%s: порядок %v, want %v", tt.order, got, tt.want)
+		}
+	}
+}
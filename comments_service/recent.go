@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"comments_service/middleware"
+)
+
+// Размер выборки последних комментариев для сервиса цензуры
+const (
+	defaultRecentLimit = 500
+	maxRecentLimit     = 5000
+)
+
+// internalTokenHeader - заголовок с общим секретом внутренних эндпоинтов
+const internalTokenHeader = "X-Internal-Token"
+
+// internalToken - общий секрет с сервисом цензуры (INTERNAL_TOKEN). Пока он
+// не задан, внутренние эндпоинты отвечают 403
+var internalToken string
+
+// RecentComment - комментарий для повторной проверки правилами цензуры
+type RecentComment struct {
+	ID        int       `json:"id"`
+	NewsID    int       `json:"news_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// handleRecentComments - GET /internal/comments/recent?limit=500: последние
+// неудаленные комментарии с исходным текстом, в том числе скрытые модерацией.
+// Через шлюз недоступен и требует заголовок X-Internal-Token: сервис цензуры
+// проверяет на них новый набор правил перед применением
+func handleRecentComments(w http.ResponseWriter, r *http.Request) {
+	if !checkInternalToken(r) {
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultRecentLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxRecentLimit {
+			http.Error(w, fmt.Sprintf("limit должен быть от 1 до %d", maxRecentLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	comments, err := getRecentComments(r.Context(), limit)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка получения комментариев")
+		http.Error(w, "Ошибка получения комментариев", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comments)
+}
+
+// checkInternalToken сравнивает заголовок X-Internal-Token с internalToken
+func checkInternalToken(r *http.Request) bool {
+	token := r.Header.Get(internalTokenHeader)
+	return internalToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(internalToken)) == 1
+}
+
+func getRecentComments(ctx context.Context, limit int) ([]RecentComment, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, news_id, content, created_at
+		FROM comments
+		WHERE deleted_at IS NULL AND orphaned_at IS NULL
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []RecentComment{}
+	for rows.Next() {
+		var c RecentComment
+		if err := rows.Scan(&c.ID, &c.NewsID, &c.Content, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckInternalToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string // INTERNAL_TOKEN
+		header string
+		want   bool
+	}{
+		{name: "верный секрет", secret: "s3cret", header: "s3cret", want: true},
+		{name: "секрет не задан", secret: "", header: ""},
+		{name: "нет заголовка", secret: "s3cret", header: ""},
+		{name: "неверный секрет", secret: "s3cret", header: "guess"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := internalToken
+			internalToken = tt.secret
+			defer func() { internalToken = old }()
+
+			req := httptest.NewRequest(http.MethodGet, "/internal/comments/recent", nil)
+			if tt.header != "" {
+				req.Header.Set(internalTokenHeader, tt.header)
+			}
+			if got := checkInternalToken(req); got != tt.want {
+				t.Errorf("checkInternalToken = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecentCommentsForbidden(t *testing.T) {
+	old := internalToken
+	internalToken = "s3cret"
+	defer func() { internalToken = old }()
+
+	// Роль модератора без секрета доступа не дает
+	req := httptest.NewRequest(http.MethodGet, "/internal/comments/recent", nil)
+	req.Header.Set("X-User-Role", "moderator")
+	rec := httptest.NewRecorder()
+	handleRecentComments(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("статус %d, want 403", rec.Code)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"comments_service/middleware"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// reportReasons - допустимые коды причин жалобы
+var reportReasons = []string{"spam", "abuse", "off_topic", "misinformation", "other"}
+
+// autoHideReason - причина модерации у комментария, скрытого по жалобам
+const autoHideReason = "Скрыт автоматически по жалобам пользователей"
+
+// reportHideThreshold - число жалоб разных пользователей, после которого
+// одобренный комментарий скрывается и попадает в очередь модерации
+var reportHideThreshold = 5
+
+var errAlreadyReported = errors.New("жалоба уже отправлена")
+
+// Тело запроса жалобы на комментарий
+type ReportRequest struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details,omitempty"`
+}
+
+// Ответ на жалобу. news_id нужен шлюзу для сброса кэша новости
+type ReportResponse struct {
+	CommentID   int    `json:"comment_id"`
+	NewsID      int    `json:"news_id"`
+	Status      string `json:"status"`
+	ReportCount int    `json:"report_count"`
+}
+
+// Комментарий с открытыми жалобами
+type ReportedComment struct {
+	ModeratedComment
+	ReportCount int `json:"report_count"`
+	// Reasons - число открытых жалоб по каждой причине
+	Reasons map[string]int `json:"reasons"`
+}
+
+// reportedSelectSQL - moderatedSelectSQL, число и причины открытых жалоб в порядке scanFields
+const reportedSelectSQL = moderatedSelectSQL + `,
+	c.report_count,
+	COALESCE((
+		SELECT json_object_agg(reason, n)
+		FROM (
+			SELECT reason, count(*) AS n
+			FROM comment_reports
+			WHERE comment_id = c.id AND resolved_at IS NULL
+			GROUP BY reason
+		) r
+	), '{}'::json) AS reasons`
+
+func (rc *ReportedComment) scanFields() []any {
+	return append(rc.ModeratedComment.scanFields(), &rc.ReportCount, &rc.Reasons)
+}
+
+// handleReportComment - POST /api/comments/{id}/report
+func handleReportComment(w http.ResponseWriter, r *http.Request, commentID int) {
+	who, ok := actorFromRequest(r)
+	if !ok {
+		http.Error(w, "Требуется аутентификация", http.StatusUnauthorized)
+		return
+	}
+
+	var req ReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+		return
+	}
+	if !slices.Contains(reportReasons, req.Reason) {
+		http.Error(w, fmt.Sprintf("Неизвестная причина жалобы, допустимы %s", strings.Join(reportReasons, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := reportComment(r.Context(), commentID, who, req.Reason, strings.TrimSpace(req.Details))
+	if err != nil {
+		switch {
+		case errors.Is(err, errAlreadyReported):
+			http.Error(w, "Вы уже пожаловались на этот комментарий", http.StatusConflict)
+		case errors.Is(err, errOwnComment):
+			http.Error(w, "Нельзя пожаловаться на свой комментарий", http.StatusBadRequest)
+		case errors.Is(err, errWrongStatus):
+			http.Error(w, "Комментарий уже скрыт", http.StatusConflict)
+		default:
+			writeModifyError(w, r, err, "Ошибка отправки жалобы")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleReportedComments - GET /api/moderation/reports: комментарии с открытыми
+// жалобами, больше всего жалоб первыми. Фильтры ?status=, news_id
+func handleReportedComments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	if who, ok := actorFromRequest(r); !ok || !who.isModerator() {
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	conds := []string{"c.report_count > 0", "c.orphaned_at IS NULL"}
+	var args []any
+	switch status := query.Get("status"); status {
+	case "":
+	case statusPending, statusApproved, statusRejected, statusHidden:
+		args = append(args, status)
+		conds = append(conds, fmt.Sprintf("c.status = $%d", len(args)))
+	default:
+		http.Error(w, "Неизвестный статус", http.StatusBadRequest)
+		return
+	}
+	if v := query.Get("news_id"); v != "" {
+		newsID, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Неверный news_id", http.StatusBadRequest)
+			return
+		}
+		args = append(args, newsID)
+		conds = append(conds, fmt.Sprintf("c.news_id = $%d", len(args)))
+	}
+
+	query.Set("sort", sortReports)
+	page, err := parsePageParams(query, sortReports)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	items, next, err := getReportedComments(r.Context(), conds, args, page)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка получения жалоб")
+		http.Error(w, "Ошибка получения жалоб", http.StatusInternalServerError)
+		return
+	}
+
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// reportComment сохраняет жалобу пользователя. Повторная жалоба того же
+// пользователя отклоняется. При достижении порога одобренный комментарий
+// скрывается и попадает в очередь модерации
+func reportComment(ctx context.Context, commentID int, who actor, reason, details string) (*ReportResponse, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		authorID  *int
+		deletedAt *time.Time
+		resp      = ReportResponse{CommentID: commentID}
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT news_id, author_id, deleted_at, status, report_count
+		FROM comments WHERE id = $1 FOR UPDATE
+	`, commentID).Scan(&resp.NewsID, &authorID, &deletedAt, &resp.Status, &resp.ReportCount)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errCommentNotFound
+		}
+		return nil, err
+	}
+	switch {
+	case deletedAt != nil:
+		return nil, errCommentDeleted
+	case authorID != nil && *authorID == who.userID:
+		return nil, errOwnComment
+	case resp.Status == statusHidden:
+		return nil, errWrongStatus
+	case resp.Status != statusApproved:
+		// Неодобренные комментарии пользователям не показываются
+		return nil, errCommentNotFound
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO comment_reports (comment_id, reporter_id, reason, details)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (comment_id, reporter_id) DO NOTHING
+	`, commentID, who.userID, reason, details)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, errAlreadyReported
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE comments SET report_count = report_count + 1
+		WHERE id = $1
+		RETURNING report_count
+	`, commentID).Scan(&resp.ReportCount)
+	if err != nil {
+		return nil, err
+	}
+
+	// Скрытый комментарий остается в ветке с заглушкой, а пустой moderated_by
+	// держит его в очереди модерации до решения модератора
+	if resp.ReportCount >= reportHideThreshold {
+		resp.Status = statusHidden
+		if _, err := tx.Exec(ctx, `
+			UPDATE comments
+			SET status = $2, moderation_reason = $3, moderated_by = NULL, moderated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+		`, commentID, resp.Status, autoHideReason); err != nil {
+			return nil, err
+		}
+	}
+	return &resp, tx.Commit(ctx)
+}
+
+func getReportedComments(ctx context.Context, conds []string, args []any, page pageParams) ([]ReportedComment, string, error) {
+	cursorCond, args := cursorCondition(page.cursor, args)
+	args = append(args, page.limit+1)
+	rows, err := db.Query(ctx, `
+		SELECT `+reportedSelectSQL+`
+		FROM comments c
+		WHERE `+strings.Join(conds, " AND ")+` AND `+cursorCond+`
+		ORDER BY `+orderBy(sortReports)+`
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	items := []ReportedComment{}
+	for rows.Next() {
+		var rc ReportedComment
+		if err := rows.Scan(rc.scanFields()...); err != nil {
+			return nil, "", err
+		}
+		items = append(items, rc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(items) > page.limit {
+		items = items[:page.limit]
+		last := items[len(items)-1]
+		next = pageCursor{Sort: sortReports, ReportCount: last.ReportCount, ID: last.ID}.encode()
+	}
+	return items, next, nil
+}
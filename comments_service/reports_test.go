@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestReportsBeforeDB(t *testing.T) {
+	// Запросы отклоняются до обращения к базе
+	tests := []struct {
+		name   string
+		h      http.HandlerFunc
+		method string
+		path   string
+		body   string
+		userID int
+		role   string
+		want   int
+	}{
+		{name: "жалоба без пользователя", h: handleComment, method: http.MethodPost, path: "/api/comments/1/report", body: `{"reason":"spam"}`, want: 401},
+		{name: "жалоба - неверное тело", h: handleComment, method: http.MethodPost, path: "/api/comments/1/report", body: `{`, userID: 8, role: "user", want: 400},
+		{name: "жалоба - неизвестная причина", h: handleComment, method: http.MethodPost, path: "/api/comments/1/report", body: `{"reason":"boring"}`, userID: 8, role: "user", want: 400},
+		{name: "жалоба - метод", h: handleComment, method: http.MethodGet, path: "/api/comments/1/report", userID: 8, role: "user", want: 405},
+		{name: "список жалоб - пользователь", h: handleReportedComments, method: http.MethodGet, path: "/api/moderation/reports", userID: 8, role: "user", want: 403},
+		{name: "список жалоб - метод", h: handleReportedComments, method: http.MethodPost, path: "/api/moderation/reports", userID: 9, role: roleModerator, want: 405},
+		{name: "список жалоб - неизвестный статус", h: handleReportedComments, method: http.MethodGet, path: "/api/moderation/reports?status=x", userID: 9, role: roleModerator, want: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(tt.h, tt.method, tt.path, tt.body, tt.userID, tt.role); rec.Code != tt.want {
+				t.Errorf("статус %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportAutoHide(t *testing.T) {
+	useTestDB(t)
+	old := reportHideThreshold
+	reportHideThreshold = 3
+	defer func() { reportHideThreshold = old }()
+
+	id := insertComment(t, nil, 7, statusApproved)
+	path := fmt.Sprintf("/api/comments/%d/report", id)
+
+	// Жалобы разных пользователей скрывают комментарий на третьей
+	steps := []struct {
+		name     string
+		reporter int
+		want     int
+		status   string // статус в ответе
+		count    int
+	}{
+		{name: "первая жалоба", reporter: 8, want: 201, status: statusApproved, count: 1},
+		{name: "своя жалоба", reporter: 7, want: 400},
+		{name: "повторная жалоба", reporter: 8, want: 409},
+		{name: "вторая жалоба", reporter: 9, want: 201, status: statusApproved, count: 2},
+		{name: "порог достигнут", reporter: 10, want: 201, status: statusHidden, count: 3},
+		{name: "комментарий уже скрыт", reporter: 11, want: 409},
+	}
+	for _, st := range steps {
+		rec := serve(handleComment, http.MethodPost, path, `{"reason":"spam"}`, st.reporter, "user")
+		if rec.Code != st.want {
+			t.Fatalf("%s: статус %d, want %d: %s", st.name, rec.Code, st.want, rec.Body)
+		}
+		if st.want != http.StatusCreated {
+			continue
+		}
+		var resp ReportResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status != st.status || resp.ReportCount != st.count || resp.NewsID != 1 {
+			t.Errorf("%s: ответ %+v, want status=%s report_count=%d news_id=1", st.name, resp, st.status, st.count)
+		}
+	}
+
+	// Скрытый по жалобам комментарий ждет решения модератора
+	var (
+		reason      string
+		moderatedBy *int
+	)
+	if err := db.QueryRow(context.Background(), `
+		SELECT moderation_reason, moderated_by FROM comments WHERE id = $1
+	`, id).Scan(&reason, &moderatedBy); err != nil {
+		t.Fatal(err)
+	}
+	if reason != autoHideReason || moderatedBy != nil {
+		t.Errorf("moderation_reason=%q moderated_by=%v, want %q и NULL", reason, moderatedBy, autoHideReason)
+	}
+
+	rec := serve(handleReportedComments, http.MethodGet, "/api/moderation/reports", "", 9, roleModerator)
+	var reported []ReportedComment
+	if err := json.NewDecoder(rec.Body).Decode(&reported); err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != 1 || reported[0].ReportCount != 3 || reported[0].Reasons["spam"] != 3 {
+		t.Errorf("жалобы %+v, want одна запись с 3 жалобами spam", reported)
+	}
+}
+
+func TestReportUnpublished(t *testing.T) {
+	useTestDB(t)
+
+	deleted := insertComment(t, nil, 7, statusApproved)
+	if _, err := db.Exec(context.Background(), `UPDATE comments SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1`, deleted); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		id   int
+		want int
+	}{
+		{name: "ожидает модерации", id: insertComment(t, nil, 7, statusPending), want: 404},
+		{name: "отклонен", id: insertComment(t, nil, 7, statusRejected), want: 404},
+		{name: "удален", id: deleted, want: 409},
+		{name: "не существует", id: 999999, want: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := fmt.Sprintf("/api/comments/%d/report", tt.id)
+			if rec := serve(handleComment, http.MethodPost, path, `{"reason":"abuse"}`, 8, "user"); rec.Code != tt.want {
+				t.Errorf("статус %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+const (
+	// Глубина дерева по умолчанию и максимальная (уровень 1 - корневые комментарии)
+	defaultTreeDepth = 5
+	maxTreeDepth     = 10
+	// maxTreeNodes ограничивает размер ответа: при limit на каждом уровне
+	// число узлов растет как limit^depth. Глубокие уровни отбрасываются первыми
+	maxTreeNodes = 2000
+)
+
+// Узел дерева комментариев
+type CommentNode struct {
+	Comment
+	Depth   int            `json:"depth"`
+	Replies []*CommentNode `json:"replies"`
+}
+
+// getCommentTree возвращает комментарии новости деревом не глубже depth уровней.
+// Корни - комментарии верхнего уровня или ответы на parentID; они разбиваются
+// на страницы курсором, на остальных уровнях берется не больше page.limit ответов.
+// Второе значение - курсор следующей страницы корней
+func getCommentTree(ctx context.Context, newsID int, parentID *int, depth int, page pageParams) ([]*CommentNode, string, error) {
+	args := []any{newsID, page.limit + 1, page.limit, depth, maxTreeNodes}
+	rootCond := "parent_id IS NULL"
+	if parentID != nil {
+		args = append(args, *parentID)
+		rootCond = fmt.Sprintf("parent_id = $%d", len(args))
+	}
+	cursorCond, args := cursorCondition(page.cursor, args)
+	order := orderBy(page.order)
+
+	// Корней берется на один больше, чтобы узнать, есть ли следующая страница
+	rows, err := db.Query(ctx, `
+		WITH RECURSIVE `+rankedCommentsSQL+`,
+		thread AS (
+			(
+				SELECT ranked.*, 1 AS depth
+				FROM ranked
+				WHERE `+rootCond+` AND `+cursorCond+`
+				ORDER BY `+order+`
+				LIMIT $2
+			)
+			UNION ALL
+			SELECT child.*, t.depth + 1
+			FROM thread t
+			CROSS JOIN LATERAL (
+				SELECT * FROM ranked
+				WHERE ranked.parent_id = t.id
+				ORDER BY `+order+`
+				LIMIT $3
+			) child
+			WHERE t.depth < $4
+		)
+		SELECT `+commentColumnsSQL+`, depth
+		FROM thread
+		ORDER BY depth
+		LIMIT $5
+	`, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var nodes []*CommentNode
+	for rows.Next() {
+		n := &CommentNode{Replies: []*CommentNode{}}
+		if err := rows.Scan(append(n.scanFields(), &n.Depth)...); err != nil {
+			return nil, "", err
+		}
+		nodes = append(nodes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	roots := buildTree(nodes, page.order)
+	next := ""
+	if len(roots) > page.limit {
+		roots = roots[:page.limit]
+		next = encodeCursor(page.order, roots[len(roots)-1].Comment)
+	}
+	return roots, next, nil
+}
+
+// buildTree раскладывает узлы по родителям и сортирует каждый уровень
+func buildTree(nodes []*CommentNode, order string) []*CommentNode {
+	byID := make(map[int]*CommentNode, len(nodes))
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+
+	roots := []*CommentNode{}
+	for _, n := range nodes {
+		if n.Depth == 1 {
+			roots = append(roots, n)
+			continue
+		}
+		if parent, ok := byID[*n.ParentID]; ok {
+			parent.Replies = append(parent.Replies, n)
+		}
+	}
+
+	sortLevel(roots, order)
+	for _, n := range nodes {
+		sortLevel(n.Replies, order)
+	}
+	return roots
+}
+
+func sortLevel(level []*CommentNode, order string) {
+	sort.Slice(level, func(i, j int) bool {
+		return lessComment(level[i].Comment, level[j].Comment, order)
+	})
+}
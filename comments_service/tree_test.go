@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func node(id int, parent *int, depth int, created time.Time) *CommentNode {
+	return &CommentNode{
+		Comment: Comment{ID: id, ParentID: parent, CreatedAt: created},
+		Depth:   depth,
+		Replies: []*CommentNode{},
+	}
+}
+
+// shape записывает дерево как "id(ответы) id(ответы)"
+func shape(level []*CommentNode) string {
+	s := ""
+	for i, n := range level {
+		if i > 0 {
+			s += " "
+		}
+		s += strconv.Itoa(n.ID)
+		if len(n.Replies) > 0 {
+			s += "(" + shape(n.Replies) + ")"
+		}
+	}
+	return s
+}
+
+func TestBuildTree(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(min int) time.Time { return base.Add(time.Duration(min) * time.Minute) }
+	one, two, three, five, nine := 1, 2, 3, 5, 9
+
+	// Узлы приходят из запроса отсортированными только по глубине
+	nodes := func() []*CommentNode {
+		return []*CommentNode{
+			node(1, nil, 1, at(0)),
+			node(2, nil, 1, at(10)),
+			node(3, &one, 2, at(1)),
+			node(4, &one, 2, at(5)),
+			node(5, &two, 2, at(11)),
+			node(6, &three, 3, at(2)),
+			node(7, &five, 3, at(12)),
+			// Родитель 9 не попал в выборку (обрезан лимитом узлов)
+			node(8, &nine, 3, at(20)),
+		}
+	}
+
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{order: sortNew, want: "2(5(7)) 1(4 3(6))"},
+		{order: sortOld, want: "1(3(6) 4) 2(5(7))"},
+	}
+	for _, tt := range tests {
+		if got := shape(buildTree(nodes(), tt.order)); got != tt.want {
+			t.Errorf("sort=%s: дерево %q, want %q", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestBuildTreeReplyRoots(t *testing.T) {
+	// Корни - ответы на комментарий 1 (?parent_id=1), у них есть ParentID
+	one, two := 1, 2
+	now := time.Now()
+	nodes := []*CommentNode{
+		node(2, &one, 1, now),
+		node(3, &one, 1, now.Add(time.Second)),
+		node(4, &two, 2, now),
+	}
+	if got, want := shape(buildTree(nodes, sortOld)), "2(4) 3"; got != want {
+		t.Errorf("дерево %q, want %q", got, want)
+	}
+}
+
+func TestBuildTreeEmpty(t *testing.T) {
+	roots := buildTree(nil, sortNew)
+	if roots == nil || len(roots) != 0 {
+		t.Errorf("buildTree(nil) = %v, want пустой срез", roots)
+	}
+}
+
+func TestBuildTreeRejectedParent(t *testing.T) {
+	// Отклоненный комментарий 2 с опубликованным ответом приходит заглушкой,
+	// ответ 3 остается в ветке под ним
+	one, two := 1, 2
+	now := time.Now()
+	rejected := node(2, &one, 2, now)
+	rejected.Status = statusRejected
+	rejected.Content = hiddenPlaceholder
+	nodes := []*CommentNode{
+		node(1, nil, 1, now),
+		rejected,
+		node(3, &two, 3, now),
+	}
+	roots := buildTree(nodes, sortOld)
+	if got, want := shape(roots), "1(2(3))"; got != want {
+		t.Fatalf("дерево %q, want %q", got, want)
+	}
+	if got := roots[0].Replies[0].Content; got != hiddenPlaceholder {
+		t.Errorf("текст отклоненного комментария %q, want %q", got, hiddenPlaceholder)
+	}
+}
+
+func TestCommentTreeRejectedMiddle(t *testing.T) {
+	useTestDB(t)
+	root := insertComment(t, nil, 7, statusApproved)
+	middle := insertComment(t, &root, 8, statusApproved)
+	leaf := insertComment(t, &middle, 9, statusApproved)
+	lone := insertComment(t, &root, 10, statusApproved)
+
+	// Модератор скрывает и затем отклоняет middle и lone; у lone ответов нет
+	for _, id := range []int{middle, lone} {
+		for _, action := range []string{"hide", "reject"} {
+			path := fmt.Sprintf("/api/moderation/comments/%d/%s", id, action)
+			if rec := serve(handleModerationAction, http.MethodPost, path, `{"reason":"причина"}`, 11, roleModerator); rec.Code != http.StatusOK {
+				t.Fatalf("%s %d: статус %d: %s", action, id, rec.Code, rec.Body)
+			}
+		}
+	}
+
+	rec := serve(handleComments, http.MethodGet, "/api/comments?news_id=1&format=tree&sort=old", "", 0, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("статус %d: %s", rec.Code, rec.Body)
+	}
+	var roots []*CommentNode
+	if err := json.NewDecoder(rec.Body).Decode(&roots); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := shape(roots), fmt.Sprintf("%d(%d(%d))", root, middle, leaf); got != want {
+		t.Fatalf("дерево %q, want %q", got, want)
+	}
+	m := roots[0].Replies[0]
+	if m.Content != hiddenPlaceholder || m.AuthorID != nil || m.ReplyCount != 1 {
+		t.Errorf("отклоненный комментарий %+v, want заглушка без автора с одним ответом", m.Comment)
+	}
+	if roots[0].ReplyCount != 1 {
+		t.Errorf("reply_count корня %d, want 1", roots[0].ReplyCount)
+	}
+	if l := m.Replies[0]; l.Content != "текст" {
+		t.Errorf("текст ответа %q, want исходный", l.Content)
+	}
+
+	// Заглушка не считается, ответ под ней - считается
+	counts, err := getCommentCounts(context.Background(), []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["1"] != 2 {
+		t.Errorf("комментариев %d, want 2", counts["1"])
+	}
+}
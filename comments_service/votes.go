@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// reactionKinds - допустимые реакции на комментарий
+var reactionKinds = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+// Тело запроса голосования: 1 - за, -1 - против
+type VoteRequest struct {
+	Value int `json:"value"`
+}
+
+// Итоги голосования и реакций после изменения. news_id нужен шлюзу для сброса кэша новости
+type VoteSummary struct {
+	CommentID int            `json:"comment_id"`
+	NewsID    int            `json:"news_id"`
+	Upvotes   int            `json:"upvotes"`
+	Downvotes int            `json:"downvotes"`
+	Reactions map[string]int `json:"reactions"`
+	// Vote и MyReactions - голос и реакции текущего пользователя
+	Vote        int      `json:"vote"`
+	MyReactions []string `json:"my_reactions"`
+}
+
+// handleVote - PUT и DELETE /api/comments/{id}/vote
+func handleVote(w http.ResponseWriter, r *http.Request, commentID int) {
+	who, ok := actorFromRequest(r)
+	if !ok {
+		http.Error(w, "Требуется аутентификация", http.StatusUnauthorized)
+		return
+	}
+
+	var value int
+	switch r.Method {
+	case http.MethodPut:
+		var req VoteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Value != 1 && req.Value != -1) {
+			http.Error(w, "Неверное тело запроса, value должен быть 1 или -1", http.StatusBadRequest)
+			return
+		}
+		value = req.Value
+	case http.MethodDelete:
+	default:
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	summary, err := voteComment(r.Context(), commentID, who, value)
+	writeVoteResult(w, r, summary, err)
+}
+
+// handleReaction - PUT и DELETE /api/comments/{id}/reactions/{reaction}
+func handleReaction(w http.ResponseWriter, r *http.Request, commentID int, reaction string) {
+	who, ok := actorFromRequest(r)
+	if !ok {
+		http.Error(w, "Требуется аутентификация", http.StatusUnauthorized)
+		return
+	}
+	if !slices.Contains(reactionKinds, reaction) {
+		http.Error(w, fmt.Sprintf("Неизвестная реакция, допустимы %s", strings.Join(reactionKinds, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	var add bool
+	switch r.Method {
+	case http.MethodPut:
+		add = true
+	case http.MethodDelete:
+	default:
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	summary, err := reactComment(r.Context(), commentID, who, reaction, add)
+	writeVoteResult(w, r, summary, err)
+}
+
+func writeVoteResult(w http.ResponseWriter, r *http.Request, summary *VoteSummary, err error) {
+	if err != nil {
+		if errors.Is(err, errOwnComment) {
+			http.Error(w, "Нельзя голосовать за свой комментарий", http.StatusBadRequest)
+			return
+		}
+		writeModifyError(w, r, err, "Ошибка голосования")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// lockVotable блокирует строку комментария до конца транзакции. Голосовать
+// можно только за опубликованные чужие комментарии
+func lockVotable(ctx context.Context, tx pgx.Tx, commentID int, who actor) error {
+	var (
+		authorID  *int
+		deletedAt *time.Time
+		status    string
+	)
+	err := tx.QueryRow(ctx, `
+		SELECT author_id, deleted_at, status FROM comments WHERE id = $1 FOR UPDATE
+	`, commentID).Scan(&authorID, &deletedAt, &status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errCommentNotFound
+		}
+		return err
+	}
+	switch {
+	case status != statusApproved:
+		return errCommentNotFound
+	case deletedAt != nil:
+		return errCommentDeleted
+	case authorID != nil && *authorID == who.userID:
+		return errOwnComment
+	}
+	return nil
+}
+
+// voteComment ставит, меняет (value = 1 или -1) или снимает (value = 0) голос
+// пользователя. Счетчики комментария меняются в той же транзакции
+func voteComment(ctx context.Context, commentID int, who actor, value int) (*VoteSummary, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockVotable(ctx, tx, commentID, who); err != nil {
+		return nil, err
+	}
+
+	var previous int
+	err = tx.QueryRow(ctx, `
+		SELECT value FROM comment_votes WHERE comment_id = $1 AND user_id = $2
+	`, commentID, who.userID).Scan(&previous)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	if value == 0 {
+		_, err = tx.Exec(ctx, `
+			DELETE FROM comment_votes WHERE comment_id = $1 AND user_id = $2
+		`, commentID, who.userID)
+	} else {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO comment_votes (comment_id, user_id, value) VALUES ($1, $2, $3)
+			ON CONFLICT (comment_id, user_id) DO UPDATE SET value = EXCLUDED.value, created_at = CURRENT_TIMESTAMP
+		`, commentID, who.userID, value)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	up, down := voteDelta(previous, value)
+	if up != 0 || down != 0 {
+		if _, err := tx.Exec(ctx, `
+			UPDATE comments SET upvotes = upvotes + $2, downvotes = downvotes + $3 WHERE id = $1
+		`, commentID, up, down); err != nil {
+			return nil, err
+		}
+	}
+
+	summary, err := getVoteSummary(ctx, tx, commentID, who)
+	if err != nil {
+		return nil, err
+	}
+	return summary, tx.Commit(ctx)
+}
+
+// voteDelta - изменение счетчиков "за" и "против" при замене голоса previous на value
+func voteDelta(previous, value int) (up, down int) {
+	count := func(v, want int) int {
+		if v == want {
+			return 1
+		}
+		return 0
+	}
+	return count(value, 1) - count(previous, 1), count(value, -1) - count(previous, -1)
+}
+
+// reactComment добавляет или снимает реакцию пользователя. У пользователя может
+// быть несколько разных реакций, но каждая - не больше одного раза
+func reactComment(ctx context.Context, commentID int, who actor, reaction string, add bool) (*VoteSummary, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockVotable(ctx, tx, commentID, who); err != nil {
+		return nil, err
+	}
+
+	delta := -1
+	query := `DELETE FROM comment_reactions WHERE comment_id = $1 AND user_id = $2 AND reaction = $3`
+	if add {
+		delta = 1
+		query = `
+			INSERT INTO comment_reactions (comment_id, user_id, reaction) VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING`
+	}
+	tag, err := tx.Exec(ctx, query, commentID, who.userID, reaction)
+	if err != nil {
+		return nil, err
+	}
+
+	// Повторная реакция или снятие отсутствующей счетчики не меняют
+	if tag.RowsAffected() > 0 {
+		if _, err := tx.Exec(ctx, `
+			UPDATE comments SET reactions = CASE
+				WHEN COALESCE((reactions->>$2::TEXT)::INTEGER, 0) + $3::INTEGER <= 0 THEN reactions - $2::TEXT
+				ELSE jsonb_set(reactions, ARRAY[$2::TEXT], to_jsonb(COALESCE((reactions->>$2::TEXT)::INTEGER, 0) + $3::INTEGER))
+			END
+			WHERE id = $1
+		`, commentID, reaction, delta); err != nil {
+			return nil, err
+		}
+	}
+
+	summary, err := getVoteSummary(ctx, tx, commentID, who)
+	if err != nil {
+		return nil, err
+	}
+	return summary, tx.Commit(ctx)
+}
+
+func getVoteSummary(ctx context.Context, tx pgx.Tx, commentID int, who actor) (*VoteSummary, error) {
+	s := VoteSummary{CommentID: commentID}
+	err := tx.QueryRow(ctx, `
+		SELECT c.news_id, c.upvotes, c.downvotes, c.reactions,
+			COALESCE((SELECT value FROM comment_votes WHERE comment_id = c.id AND user_id = $2), 0),
+			ARRAY(SELECT reaction FROM comment_reactions WHERE comment_id = c.id AND user_id = $2 ORDER BY reaction)
+		FROM comments c
+		WHERE c.id = $1
+	`, commentID, who.userID).Scan(&s.NewsID, &s.Upvotes, &s.Downvotes, &s.Reactions, &s.Vote, &s.MyReactions)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestVoteDelta(t *testing.T) {
+	tests := []struct {
+		previous, value int
+		up, down        int
+	}{
+		{previous: 0, value: 1, up: 1, down: 0},
+		{previous: 0, value: -1, up: 0, down: 1},
+		{previous: 1, value: 1, up: 0, down: 0},
+		{previous: 1, value: -1, up: -1, down: 1},
+		{previous: -1, value: 1, up: 1, down: -1},
+		{previous: 1, value: 0, up: -1, down: 0},
+		{previous: -1, value: 0, up: 0, down: -1},
+		{previous: 0, value: 0, up: 0, down: 0},
+	}
+	for _, tt := range tests {
+		up, down := voteDelta(tt.previous, tt.value)
+		if up != tt.up || down != tt.down {
+			t.Errorf("voteDelta(%d, %d) = %d, %d, want %d, %d", tt.previous, tt.value, up, down, tt.up, tt.down)
+		}
+	}
+}
@@ -46,6 +46,9 @@ EOSQL
 			comments_db)
 				create_user_and_database $db comments_user comments_password
 				;;
+			users_db)
+				create_user_and_database $db users_user users_password
+				;;
 			*)
 				echo "Unknown database: $db"
 				;;
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"news_aggregator/news_service/tracing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Параметры проверки новостей сервисом цензуры
+const (
+	// censorBatchSize - сколько новостей отправляется в одном запросе
+	censorBatchSize = 100
+	// maxCensorBatches - сколько запросов делается за цикл загрузки; остальные
+	// новости проверяются в следующих циклах
+	maxCensorBatches = 50
+	censorTimeout    = 30 * time.Second
+)
+
+// Профиль правил сервиса цензуры для новостей
+const censorProfile = "news"
+
+var (
+	// censorshipServiceURL - адрес сервиса цензуры; пусто - новости не проверяются
+	censorshipServiceURL string
+
+	censorClient = &http.Client{Timeout: censorTimeout, Transport: tracing.Transport(http.DefaultTransport)}
+)
+
+// censorVerdict - вердикт сервиса цензуры по одному тексту
+type censorVerdict struct {
+	Status string  `json:"status"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+// censorEnabled сообщает, проверяются ли новости сервисом цензуры
+func censorEnabled() bool {
+	return censorshipServiceURL != ""
+}
+
+// newsStatusFromVerdict переводит вердикт в статус новости: отклоненные
+// скрываются, сомнительные ждут решения редактора
+func newsStatusFromVerdict(status string) string {
+	switch status {
+	case "rejected":
+		return newsStatusHidden
+	case "review", "masked":
+		return newsStatusFlagged
+	}
+	return newsStatusVisible
+}
+
+// censorPendingNews проверяет новости, ожидающие проверки, и выставляет им
+// статус по вердикту. Если сервис цензуры недоступен, новости остаются
+// в ожидании до следующего цикла
+func censorPendingNews(ctx context.Context) {
+	total := map[string]int{}
+	for range maxCensorBatches {
+		ids, texts, err := pendingNews(ctx, censorBatchSize)
+		if err != nil {
+			logger.WithError(err).Error("Ошибка получения новостей для проверки")
+			return
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		verdicts, err := censorTexts(ctx, texts)
+		if err != nil {
+			logger.WithError(err).WithField("pending", len(ids)).Warn("Новости не проверены сервисом цензуры, повтор в следующем цикле")
+			return
+		}
+		for i, v := range verdicts {
+			status := newsStatusFromVerdict(v.Status)
+			if _, err := db.Exec(ctx, `
+				UPDATE news SET moderation_status = $2, moderation_reason = NULLIF($3, ''), moderation_score = $4
+				WHERE id = $1 AND moderation_status = 'pending'
+			`, ids[i], status, v.Reason, v.Score); err != nil {
+				logger.WithError(err).WithField("news_id", ids[i]).Error("Ошибка сохранения статуса новости")
+				return
+			}
+			total[status]++
+		}
+	}
+	if len(total) > 0 {
+		logger.WithFields(logrus.Fields{
+			"visible": total[newsStatusVisible],
+			"flagged": total[newsStatusFlagged],
+			"hidden":  total[newsStatusHidden],
+		}).Info("Новости проверены сервисом цензуры")
+	}
+}
+
+// releasePendingNews показывает новости, оставшиеся непроверенными после
+// отключения проверки: без сервиса цензуры новости публикуются сразу
+func releasePendingNews(ctx context.Context) error {
+	tag, err := db.Exec(ctx, `UPDATE news SET moderation_status = 'visible' WHERE moderation_status = 'pending'`)
+	if err != nil {
+		return err
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		logger.WithField("count", n).Warn("Проверка новостей отключена, непроверенные новости опубликованы")
+	}
+	return nil
+}
+
+// pendingNews возвращает до limit новостей, ожидающих проверки, и их тексты
+// для сервиса цензуры: заголовок, описание и ссылку на источник (по ней
+// правила находят запрещенные источники)
+func pendingNews(ctx context.Context, limit int) ([]int, []string, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, title, description, source_link
+		FROM news
+		WHERE moderation_status = 'pending'
+		ORDER BY id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var (
+		ids   []int
+		texts []string
+	)
+	for rows.Next() {
+		var (
+			id                       int
+			title, description, link string
+		)
+		if err := rows.Scan(&id, &title, &description, &link); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		texts = append(texts, strings.Join([]string{title, description, link}, "\n\n"))
+	}
+	return ids, texts, rows.Err()
+}
+
+// censorTexts проверяет тексты пакетным запросом к сервису цензуры
+func censorTexts(ctx context.Context, texts []string) ([]censorVerdict, error) {
+	body, err := json.Marshal(map[string][]string{"texts": texts})
+	if err != nil {
+		return nil, err
+	}
+	u := strings.TrimRight(censorshipServiceURL, "/") + "/api/censor/batch?profile=" + url.QueryEscape(censorProfile)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := censorClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("неожиданный статус ответа сервиса цензуры: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Results []censorVerdict `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Results) != len(texts) {
+		return nil, fmt.Errorf("сервис цензуры вернул %d вердиктов на %d текстов", len(result.Results), len(texts))
+	}
+	return result.Results, nil
+}
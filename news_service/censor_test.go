@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewsStatusFromVerdict(t *testing.T) {
+	tests := map[string]string{
+		"approved": newsStatusVisible,
+		"rejected": newsStatusHidden,
+		"review":   newsStatusFlagged,
+		"masked":   newsStatusFlagged,
+		"":         newsStatusVisible,
+	}
+	for verdict, want := range tests {
+		if got := newsStatusFromVerdict(verdict); got != want {
+			t.Errorf("newsStatusFromVerdict(%q) = %q, want %q", verdict, got, want)
+		}
+	}
+}
+
+// fakeCensorship подменяет сервис цензуры: тексты со словом "запрещено"
+// отклоняются, со словом "сомнительно" - на проверку
+func fakeCensorship(t *testing.T) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/censor/batch" || r.URL.Query().Get("profile") != censorProfile {
+			t.Errorf("запрос %s, want /api/censor/batch?profile=%s", r.URL, censorProfile)
+		}
+		var req struct {
+			Texts []string `json:"texts"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("тело запроса: %v", err)
+		}
+		results := make([]censorVerdict, len(req.Texts))
+		for i, text := range req.Texts {
+			results[i] = censorVerdict{Status: "approved"}
+			switch {
+			case strings.Contains(text, "запрещено"):
+				results[i] = censorVerdict{Status: "rejected", Score: 1, Reason: "Запрещенная тема"}
+			case strings.Contains(text, "сомнительно"):
+				results[i] = censorVerdict{Status: "review", Score: 0.5, Reason: "Подозрительная ссылка"}
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"results": results})
+	}))
+	t.Cleanup(srv.Close)
+
+	old := censorshipServiceURL
+	censorshipServiceURL = srv.URL
+	t.Cleanup(func() { censorshipServiceURL = old })
+}
+
+func TestCensorTexts(t *testing.T) {
+	fakeCensorship(t)
+
+	verdicts, err := censorTexts(context.Background(), []string{"обычная новость", "запрещено", "сомнительно"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range verdicts {
+		got = append(got, v.Status)
+	}
+	if want := "approved rejected review"; strings.Join(got, " ") != want {
+		t.Errorf("вердикты %v, want %s", got, want)
+	}
+}
+
+func TestCensorTextsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		h    http.HandlerFunc
+	}{
+		{name: "ошибка сервиса", h: func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Неизвестный профиль", http.StatusBadRequest)
+		}},
+		{name: "вердиктов меньше, чем текстов", h: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"results": [{"status": "approved"}]}`))
+		}},
+		{name: "неверный ответ", h: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`не json`))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.h)
+			defer srv.Close()
+			old := censorshipServiceURL
+			censorshipServiceURL = srv.URL
+			defer func() { censorshipServiceURL = old }()
+
+			if _, err := censorTexts(context.Background(), []string{"a", "b"}); err == nil {
+				t.Error("ошибка не возвращена")
+			}
+		})
+	}
+}
+
+// useTestDB подключает сервис к тестовой базе TEST_DATABASE_URL, создает
+// таблицы из init.sql и очищает их. Без переменной тест пропускается
+func useTestDB(t *testing.T) {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL не задан")
+	}
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("подключение к базе: %v", err)
+	}
+	oldDB, oldLogger := db, logger
+	db, logger = pool, logrus.New()
+	logger.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		db, logger = oldDB, oldLogger
+		pool.Close()
+	})
+
+	schema, err := os.ReadFile("../init.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := db.Exec(ctx, string(schema)); err != nil {
+		t.Fatalf("создание таблиц: %v", err)
+	}
+	if err := migrateModeration(ctx); err != nil {
+		t.Fatalf("миграция: %v", err)
+	}
+	if _, err := db.Exec(ctx, `TRUNCATE news, rss_feeds RESTART IDENTITY CASCADE`); err != nil {
+		t.Fatalf("очистка таблиц: %v", err)
+	}
+}
+
+func TestIngestCensorsNews(t *testing.T) {
+	useTestDB(t)
+	fakeCensorship(t)
+
+	items := []Item{
+		{Title: "Обычная новость", Description: "текст", Link: "https://example.com/1"},
+		{Title: "Новость", Description: "запрещено", Link: "https://example.com/2"},
+		{Title: "Новость", Description: "сомнительно", Link: "https://example.com/3"},
+	}
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, `<rss><channel><title>Лента</title>`)
+		for _, it := range items {
+			fmt.Fprintf(w, `<item><title>%s</title><description>%s</description><pubDate>Mon, 02 Jan 2006 15:04:05 +0300</pubDate><link>%s</link></item>`,
+				it.Title, it.Description, it.Link)
+		}
+		fmt.Fprint(w, `</channel></rss>`)
+	}))
+	defer feed.Close()
+
+	if err := fetchAndSaveFeed(db, logger, []string{feed.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"https://example.com/1": newsStatusVisible,
+		"https://example.com/2": newsStatusHidden,
+		"https://example.com/3": newsStatusFlagged,
+	}
+	rows, err := db.Query(context.Background(), `SELECT source_link, moderation_status, moderation_reason FROM news`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var (
+			link, status string
+			reason       *string
+		)
+		if err := rows.Scan(&link, &status, &reason); err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if status != want[link] {
+			t.Errorf("%s: статус %q, want %q", link, status, want[link])
+		}
+		if status == newsStatusHidden && (reason == nil || *reason != "Запрещенная тема") {
+			t.Errorf("%s: причина %v, want причина сервиса цензуры", link, reason)
+		}
+	}
+	if n != len(want) {
+		t.Errorf("сохранено %d новостей, want %d", n, len(want))
+	}
+
+	// Скрытая новость для сервиса комментариев не существует
+	rec := httptest.NewRecorder()
+	handleNewsExists(rec, httptest.NewRequest(http.MethodGet, "/internal/news/exists?ids=1,2,3", nil))
+	var exists map[string]bool
+	if err := json.NewDecoder(rec.Body).Decode(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if !exists["1"] || exists["2"] || exists["3"] {
+		t.Errorf("exists = %v, want только новость 1", exists)
+	}
+}
+
+func TestIngestCensorshipUnavailable(t *testing.T) {
+	useTestDB(t)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	old := censorshipServiceURL
+	censorshipServiceURL = down.URL
+	defer func() { censorshipServiceURL = old }()
+
+	ctx := context.Background()
+	if _, err := db.Exec(ctx, `
+		INSERT INTO news (title, description, publication_date, source_link, moderation_status)
+		VALUES ('Новость', 'запрещено', now(), 'https://example.com/1', 'pending')
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Новость остается в ожидании до следующего цикла
+	censorPendingNews(ctx)
+	var status string
+	if err := db.QueryRow(ctx, `SELECT moderation_status FROM news`).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status != newsStatusPending {
+		t.Errorf("статус %q, want pending", status)
+	}
+}
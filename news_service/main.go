@@ -10,15 +10,19 @@ import (
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"news_aggregator/news_service/middleware"
+	"news_aggregator/news_service/tracing"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
 )
 
 // RSS представляет структуру RSS-ленты
@@ -78,6 +82,11 @@ var (
 		Buckets: prometheus.DefBuckets,
 	})
 
+	ingestCyclesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ingest_cycles_total",
+		Help: "Total number of completed RSS ingest cycles",
+	})
+
 	httpRequestDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "http_request_duration_seconds",
@@ -90,12 +99,53 @@ var (
 	// Глобальные переменные
 	db     *pgxpool.Pool
 	logger *logrus.Logger
+	ingest ingestState
+
+	// HTTP-клиент для загрузки лент с трассировкой исходящих запросов
+	feedClient = &http.Client{Transport: tracing.Transport(http.DefaultTransport)}
 )
 
+// ingestState хранит сведения о завершенных циклах загрузки RSS-лент.
+// Потребители (например, кэш шлюза) сравнивают номер цикла, чтобы узнать о новых данных
+type ingestState struct {
+	mu          sync.RWMutex
+	cycle       int64
+	completedAt time.Time
+	newItems    int64
+}
+
+// IngestStatus - ответ эндпоинта /api/ingest/status
+type IngestStatus struct {
+	Cycle       int64      `json:"cycle"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	NewItems    int64      `json:"new_items"`
+}
+
+func (s *ingestState) complete(newItems int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cycle++
+	s.completedAt = time.Now()
+	s.newItems = newItems
+	return s.cycle
+}
+
+func (s *ingestState) status() IngestStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st := IngestStatus{Cycle: s.cycle, NewItems: s.newItems}
+	if !s.completedAt.IsZero() {
+		completedAt := s.completedAt
+		st.CompletedAt = &completedAt
+	}
+	return st
+}
+
 func init() {
 	// Регистрируем метрики
 	prometheus.MustRegister(newsTotal)
 	prometheus.MustRegister(feedFetchDuration)
+	prometheus.MustRegister(ingestCyclesTotal)
 	prometheus.MustRegister(httpRequestDuration)
 }
 
@@ -116,9 +166,19 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// Настраиваем трассировку
+	shutdownTracing, err := tracing.Init(context.Background(), "news_service")
+	if err != nil {
+		logger.Fatalf("Unable to set up tracing: %v", err)
+	}
+
 	// Подключаемся к базе данных
-	var err error
-	db, err = pgxpool.New(context.Background(), os.Getenv("DATABASE_URL"))
+	poolConfig, err := pgxpool.ParseConfig(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		logger.Fatalf("Invalid database URL: %v", err)
+	}
+	poolConfig.ConnConfig.Tracer = tracing.QueryTracer{}
+	db, err = pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		logger.Fatalf("Unable to connect to database: %v", err)
 	}
@@ -132,6 +192,19 @@ func main() {
 	}
 	logger.Info("Successfully connected to database")
 
+	// Поля модерации новостей
+	if err := migrateModeration(ctx); err != nil {
+		logger.Fatalf("Unable to migrate database: %v", err)
+	}
+
+	// Проверка новостей сервисом цензуры необязательна
+	censorshipServiceURL = os.Getenv("CENSORSHIP_SERVICE_URL")
+	if censorEnabled() {
+		logger.WithField("url", censorshipServiceURL).Info("Новости проверяются сервисом цензуры")
+	} else if err := releasePendingNews(ctx); err != nil {
+		logger.Fatalf("Unable to release pending news: %v", err)
+	}
+
 	// Читаем config.json
 	var config Config
 	configFile, err := os.Open("config.json")
@@ -155,13 +228,18 @@ func main() {
 	// Добавляем обработчики
 	mux.HandleFunc("/api/news", handleNewsList)
 	mux.HandleFunc("/api/news/", handleNewsDetail)
+	mux.HandleFunc("/api/ingest/status", handleIngestStatus)
+	mux.HandleFunc("/api/moderation/news", handleNewsModerationQueue)
+	mux.HandleFunc("/api/moderation/news/", handleNewsModerationAction)
+	mux.HandleFunc("/internal/news/exists", handleNewsExists)
 	mux.HandleFunc("/health", handleHealth)
 	mux.Handle("/metrics", promhttp.Handler())
 
 	// Применяем middleware
-	handler := middleware.RequestIDMiddleware(mux)
-	handler = middleware.LoggingMiddleware(handler, logger)
+	handler := middleware.LoggingMiddleware(mux, logger)
+	handler = middleware.RequestIDMiddleware(handler)
 	handler = middleware.MetricsMiddleware(handler, httpRequestDuration)
+	handler = tracing.Middleware(handler, "news_service", tracing.MuxRoute(mux))
 
 	server := &http.Server{
 		Addr:         ":8080",
@@ -179,13 +257,13 @@ func main() {
 		}
 	}()
 
-	// Первая загрузка новостей сразу при старте
-	if err := fetchAndSaveFeed(db, logger, config.RSSFeeds); err != nil {
-		logger.Errorf("Error fetching feed: %v", err)
-	}
-
-	// Запускаем периодическое обновление новостей
+	// Первая загрузка новостей сразу при старте, затем периодическое обновление.
+	// Загрузка идет в фоне, чтобы не задерживать запуск сервиса
 	go func() {
+		if err := fetchAndSaveFeed(db, logger, config.RSSFeeds); err != nil {
+			logger.Errorf("Error fetching feed: %v", err)
+		}
+
 		ticker := time.NewTicker(time.Duration(config.PollInterval) * time.Minute)
 		defer ticker.Stop()
 		for {
@@ -211,6 +289,11 @@ func main() {
 		logger.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Отправляем оставшиеся спаны
+	if err := shutdownTracing(ctx); err != nil {
+		logger.WithError(err).Warn("Error shutting down tracing")
+	}
+
 	logger.Info("Server stopped")
 }
 
@@ -237,18 +320,97 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleIngestStatus сообщает номер последнего завершенного цикла загрузки лент
+func handleIngestStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ingest.status())
+}
+
+// maxExistsBatch - сколько ID можно проверить за один запрос
+const maxExistsBatch = 100
+
+// handleNewsExists - GET /internal/news/exists?ids=1,2,3: какие из новостей
+// существуют и видны читателям. Новость на модерации или скрытая редактором
+// считается отсутствующей, чтобы к ней нельзя было оставить комментарий.
+// Используется сервисом комментариев, наружу шлюз его не отдает
+func handleNewsExists(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var ids []int
+	for _, v := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			http.Error(w, "Неверный ID новости", http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) > maxExistsBatch {
+		http.Error(w, fmt.Sprintf("Можно проверить не больше %d новостей", maxExistsBatch), http.StatusBadRequest)
+		return
+	}
+
+	exists := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		exists[strconv.Itoa(id)] = false
+	}
+	rows, err := db.Query(r.Context(), `SELECT id FROM news WHERE id = ANY($1) AND moderation_status = 'visible'`, ids)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context(), logger).WithError(err).Error("Ошибка проверки новостей")
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			middleware.LoggerFromContext(r.Context(), logger).WithError(err).Error("Ошибка проверки новостей")
+			http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+		exists[strconv.Itoa(id)] = true
+	}
+	if err := rows.Err(); err != nil {
+		middleware.LoggerFromContext(r.Context(), logger).WithError(err).Error("Ошибка проверки новостей")
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exists)
+}
+
+// fetchAndSaveFeed загружает все ленты параллельно и ждет завершения цикла
 func fetchAndSaveFeed(db *pgxpool.Pool, logger *logrus.Logger, feeds []string) error {
 	start := time.Now()
 	defer func() {
 		feedFetchDuration.Observe(time.Since(start).Seconds())
 	}()
 
+	// Весь цикл загрузки - один трейс: HTTP-запросы к лентам и запросы к БД внутри него
+	cycleCtx, span := otel.Tracer("news_service").Start(context.Background(), "ingest.cycle")
+	defer span.End()
+
+	var (
+		wg       sync.WaitGroup
+		newItems int64
+	)
 	for _, feedURL := range feeds {
+		wg.Add(1)
 		go func(url string) {
+			defer wg.Done()
 			var lastErr error
 			for attempt := 1; attempt <= maxRetries; attempt++ {
 				// Создаем контекст с таймаутом
-				ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+				ctx, cancel := context.WithTimeout(cycleCtx, httpTimeout)
 				defer cancel()
 
 				// Создаем запрос с контекстом
@@ -259,7 +421,7 @@ func fetchAndSaveFeed(db *pgxpool.Pool, logger *logrus.Logger, feeds []string) e
 				}
 
 				// Выполняем запрос
-				resp, err := http.DefaultClient.Do(req)
+				resp, err := feedClient.Do(req)
 				if err != nil {
 					lastErr = err
 					logger.WithError(err).WithFields(logrus.Fields{
@@ -321,10 +483,11 @@ func fetchAndSaveFeed(db *pgxpool.Pool, logger *logrus.Logger, feeds []string) e
 				}
 
 				// Если все успешно, сохраняем данные
-				if err := saveFeedData(db, url, rss); err != nil {
+				if inserted, err := saveFeedData(cycleCtx, db, url, rss); err != nil {
 					logger.WithError(err).WithField("url", url).Error("Ошибка сохранения данных")
 					newsTotal.Inc()
 				} else {
+					atomic.AddInt64(&newItems, int64(inserted))
 					newsTotal.Inc()
 				}
 				return
@@ -338,12 +501,27 @@ func fetchAndSaveFeed(db *pgxpool.Pool, logger *logrus.Logger, feeds []string) e
 			newsTotal.Inc()
 		}(feedURL)
 	}
+	wg.Wait()
+
+	// Новые новости скрыты до проверки; проверяем их до завершения цикла,
+	// чтобы шлюз сбросил кэш уже с итоговыми статусами
+	if censorEnabled() {
+		censorPendingNews(cycleCtx)
+	}
+
+	cycle := ingest.complete(newItems)
+	ingestCyclesTotal.Inc()
+	logger.WithFields(logrus.Fields{
+		"cycle":     cycle,
+		"new_items": newItems,
+	}).Info("Цикл загрузки RSS-лент завершен")
 
 	return nil
 }
 
 // saveFeedData сохраняет данные из RSS-ленты в базу данных
-func saveFeedData(db *pgxpool.Pool, feedURL string, rss RSS) error {
+// и возвращает количество добавленных новостей
+func saveFeedData(ctx context.Context, db *pgxpool.Pool, feedURL string, rss RSS) (int, error) {
 	// Определяем источник на основе URL
 	var sourceName string
 	switch {
@@ -362,37 +540,42 @@ func saveFeedData(db *pgxpool.Pool, feedURL string, rss RSS) error {
 	}
 
 	// Создаем транзакцию
-	tx, err := db.Begin(context.Background())
+	tx, err := db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("ошибка создания транзакции: %v", err)
+		return 0, fmt.Errorf("ошибка создания транзакции: %v", err)
 	}
-	defer tx.Rollback(context.Background())
+	defer tx.Rollback(ctx)
 
 	// Получаем или создаем источник
 	var sourceID int
-	err = tx.QueryRow(context.Background(), `
+	err = tx.QueryRow(ctx, `
 		INSERT INTO sources (name)
 		VALUES ($1)
 		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
 		RETURNING id
 	`, sourceName).Scan(&sourceID)
 	if err != nil {
-		return fmt.Errorf("ошибка сохранения источника %s: %v", sourceName, err)
+		return 0, fmt.Errorf("ошибка сохранения источника %s: %v", sourceName, err)
 	}
 
 	// Сохраняем или обновляем ленту
 	var feedID int
-	err = tx.QueryRow(context.Background(), `
+	err = tx.QueryRow(ctx, `
 		INSERT INTO rss_feeds (url, source_id)
 		VALUES ($1, $2)
 		ON CONFLICT (url) DO UPDATE SET source_id = EXCLUDED.source_id
 		RETURNING id
 	`, feedURL, sourceID).Scan(&feedID)
 	if err != nil {
-		return fmt.Errorf("ошибка сохранения ленты %s: %v", feedURL, err)
+		return 0, fmt.Errorf("ошибка сохранения ленты %s: %v", feedURL, err)
 	}
 
-	// Сохраняем новости
+	// Сохраняем новости. С проверкой сервисом цензуры новость скрыта до вердикта
+	status := newsStatusVisible
+	if censorEnabled() {
+		status = newsStatusPending
+	}
+	inserted := 0
 	for _, item := range rss.Channel.Items {
 		pubDate, err := time.Parse(time.RFC1123Z, item.PubDate)
 		if err != nil {
@@ -404,25 +587,27 @@ func saveFeedData(db *pgxpool.Pool, feedURL string, rss RSS) error {
 			continue
 		}
 
-		_, err = tx.Exec(context.Background(), `
-			INSERT INTO news (title, description, publication_date, source_link, rss_feed_id)
-			VALUES ($1, $2, $3, $4, $5)
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO news (title, description, publication_date, source_link, rss_feed_id, moderation_status)
+			VALUES ($1, $2, $3, $4, $5, $6)
 			ON CONFLICT (source_link) DO NOTHING
-		`, item.Title, item.Description, pubDate, item.Link, feedID)
+		`, item.Title, item.Description, pubDate, item.Link, feedID, status)
 		if err != nil {
 			logger.WithError(err).WithFields(logrus.Fields{
 				"url":   feedURL,
 				"title": item.Title,
 			}).Warn("Ошибка сохранения новости")
+			continue
 		}
+		inserted += int(tag.RowsAffected())
 	}
 
 	// Завершаем транзакцию
-	if err := tx.Commit(context.Background()); err != nil {
-		return fmt.Errorf("ошибка завершения транзакции: %v", err)
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ошибка завершения транзакции: %v", err)
 	}
 
-	return nil
+	return inserted, nil
 }
 
 func handleNewsList(w http.ResponseWriter, r *http.Request) {
@@ -447,12 +632,13 @@ func handleNewsList(w http.ResponseWriter, r *http.Request) {
 	// Вычисляем смещение
 	offset := (page - 1) * pageSize
 
-	// Формируем базовый запрос
+	// Формируем базовый запрос: читателям видны только новости со статусом visible
 	baseQuery := `
 		SELECT n.id, n.title, n.description, n.publication_date, n.source_link, s.name as source_name
 		FROM news n
 		JOIN rss_feeds rf ON n.rss_feed_id = rf.id
 		JOIN sources s ON rf.source_id = s.id
+		WHERE n.moderation_status = 'visible'
 	`
 
 	// Формируем базовый запрос для подсчета общего количества
@@ -461,6 +647,7 @@ func handleNewsList(w http.ResponseWriter, r *http.Request) {
 		FROM news n
 		JOIN rss_feeds rf ON n.rss_feed_id = rf.id
 		JOIN sources s ON rf.source_id = s.id
+		WHERE n.moderation_status = 'visible'
 	`
 
 	var query, countQuery string
@@ -468,8 +655,8 @@ func handleNewsList(w http.ResponseWriter, r *http.Request) {
 	argCount := 1
 
 	if search != "" {
-		query = baseQuery + " WHERE n.title ILIKE $" + strconv.Itoa(argCount)
-		countQuery = baseCountQuery + " WHERE n.title ILIKE $" + strconv.Itoa(argCount)
+		query = baseQuery + " AND n.title ILIKE $" + strconv.Itoa(argCount)
+		countQuery = baseCountQuery + " AND n.title ILIKE $" + strconv.Itoa(argCount)
 		args = append(args, "%"+search+"%")
 		argCount++
 	} else {
@@ -484,7 +671,7 @@ func handleNewsList(w http.ResponseWriter, r *http.Request) {
 	var totalItems int
 	err = db.QueryRow(r.Context(), countQuery, args[:argCount-1]...).Scan(&totalItems)
 	if err != nil {
-		logger.WithError(err).Error("Ошибка получения общего количества записей")
+		middleware.LoggerFromContext(r.Context(), logger).WithError(err).Error("Ошибка получения общего количества записей")
 		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
 		return
 	}
@@ -541,15 +728,15 @@ func handleNewsDetail(w http.ResponseWriter, r *http.Request) {
 
 	newsID := parts[3]
 	var news News
-	err := db.QueryRow(context.Background(), `
+	err := db.QueryRow(r.Context(), `
 		SELECT n.id, n.title, n.description, n.publication_date, n.source_link, s.name as source_name
 		FROM news n
 		JOIN rss_feeds rf ON n.rss_feed_id = rf.id
 		JOIN sources s ON rf.source_id = s.id
-		WHERE n.id = $1
+		WHERE n.id = $1 AND n.moderation_status = 'visible'
 	`, newsID).Scan(&news.ID, &news.Title, &news.Description, &news.PublicationDate, &news.SourceLink, &news.SourceName)
 	if err != nil {
-		logger.WithError(err).Error("Ошибка получения деталей новости")
+		middleware.LoggerFromContext(r.Context(), logger).WithError(err).Error("Ошибка получения деталей новости")
 		http.Error(w, "Новость не найдена", http.StatusNotFound)
 		return
 	}
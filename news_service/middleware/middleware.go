@@ -5,10 +5,12 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Ключ для хранения ID запроса в контексте
@@ -19,8 +21,31 @@ const (
 	RequestIDHeader = "X-Request-ID"
 	// RequestIDContextKey is the context key for the request ID
 	RequestIDContextKey RequestIDKey = "request_id"
+	// maxRequestIDLength - максимальная длина принимаемого ID запроса
+	maxRequestIDLength = 128
 )
 
+// RequestIDFromContext возвращает ID запроса из контекста
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDContextKey).(string)
+	return id
+}
+
+// LoggerFromContext возвращает запись логгера с ID запроса и трассировки
+func LoggerFromContext(ctx context.Context, logger *logrus.Logger) *logrus.Entry {
+	entry := logrus.NewEntry(logger)
+	if id := RequestIDFromContext(ctx); id != "" {
+		entry = entry.WithField("request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		entry = entry.WithFields(logrus.Fields{
+			"trace_id": sc.TraceID().String(),
+			"span_id":  sc.SpanID().String(),
+		})
+	}
+	return entry
+}
+
 // responseWriter - обертка для http.ResponseWriter для перехвата статус-кода
 type responseWriter struct {
 	http.ResponseWriter
@@ -51,12 +76,13 @@ func MetricsMiddleware(next http.Handler, duration *prometheus.HistogramVec) htt
 		duration.WithLabelValues(
 			r.Method,
 			r.URL.Path,
-			string(rw.statusCode),
+			strconv.Itoa(rw.statusCode),
 		).Observe(time.Since(start).Seconds())
 	})
 }
 
-// LoggingMiddleware создает middleware для логирования HTTP-запросов
+// LoggingMiddleware создает middleware для логирования HTTP-запросов.
+// Должен подключаться внутри RequestIDMiddleware
 func LoggingMiddleware(next http.Handler, logger *logrus.Logger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -72,7 +98,7 @@ func LoggingMiddleware(next http.Handler, logger *logrus.Logger) http.Handler {
 
 		// Логируем информацию о запросе
 		duration := time.Since(start)
-		logger.WithFields(logrus.Fields{
+		LoggerFromContext(r.Context(), logger).WithFields(logrus.Fields{
 			"method":     r.Method,
 			"path":       r.URL.Path,
 			"status":     rw.statusCode,
@@ -83,24 +109,43 @@ func LoggingMiddleware(next http.Handler, logger *logrus.Logger) http.Handler {
 	})
 }
 
-// RequestIDMiddleware создает middleware для добавления request ID
+// RequestIDMiddleware создает middleware для добавления request ID.
+// ID, пришедший от шлюза, сохраняется; иначе генерируется новый
 func RequestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Генерируем уникальный ID запроса
-		requestID := generateRequestID()
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = generateRequestID()
+			r.Header.Set(RequestIDHeader, requestID)
+		}
 
 		// Добавляем ID в заголовок ответа
-		w.Header().Set("X-Request-ID", requestID)
+		w.Header().Set(RequestIDHeader, requestID)
 
 		// Добавляем ID в контекст запроса
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, "request_id", requestID)
+		ctx := context.WithValue(r.Context(), RequestIDContextKey, requestID)
 		r = r.WithContext(ctx)
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// validRequestID отсекает пустые, слишком длинные и небезопасные для логов ID
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // generateRequestID генерирует уникальный ID запроса
 func generateRequestID() string {
 	b := make([]byte, 16)
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"news_aggregator/news_service/middleware"
+	"news_aggregator/news_service/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Статусы модерации новости. В публичном списке показываются только visible
+const (
+	newsStatusVisible = "visible"
+	// newsStatusPending - новость ждет проверки сервисом цензуры
+	newsStatusPending = "pending"
+	// newsStatusFlagged - сервис цензуры счел новость сомнительной, решает редактор
+	newsStatusFlagged = "flagged"
+	newsStatusHidden  = "hidden"
+)
+
+// Роли редакторов (заголовок X-User-Role от шлюза)
+const (
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// newsModerationActions - действие редактора, итоговый статус и нужна ли причина
+var newsModerationActions = map[string]struct {
+	status string
+	reason bool
+}{
+	"show": {status: newsStatusVisible},
+	"hide": {status: newsStatusHidden, reason: true},
+}
+
+var errNewsNotFound = errors.New("новость не найдена")
+
+// ModeratedNews - новость с данными модерации
+type ModeratedNews struct {
+	News
+	Status      string     `json:"moderation_status"`
+	Reason      *string    `json:"moderation_reason,omitempty"`
+	Score       *float64   `json:"moderation_score,omitempty"`
+	ModeratedBy *int       `json:"moderated_by,omitempty"`
+	ModeratedAt *time.Time `json:"moderated_at,omitempty"`
+}
+
+// ModeratedNewsList - страница очереди модерации новостей
+type ModeratedNewsList struct {
+	Items      []ModeratedNews           `json:"items"`
+	Pagination models.PaginationResponse `json:"pagination"`
+}
+
+// NewsModerationRequest - тело запроса действия редактора
+type NewsModerationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// migrateModeration добавляет в таблицу новостей поля модерации.
+// Уже загруженные новости остаются видимыми
+func migrateModeration(ctx context.Context) error {
+	_, err := db.Exec(ctx, `
+		ALTER TABLE news ADD COLUMN IF NOT EXISTS moderation_status TEXT NOT NULL DEFAULT 'visible'
+			CHECK (moderation_status IN ('visible', 'pending', 'flagged', 'hidden'));
+		ALTER TABLE news ADD COLUMN IF NOT EXISTS moderation_reason TEXT;
+		ALTER TABLE news ADD COLUMN IF NOT EXISTS moderation_score REAL;
+		ALTER TABLE news ADD COLUMN IF NOT EXISTS moderated_by INTEGER;
+		ALTER TABLE news ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMPTZ;
+		CREATE INDEX IF NOT EXISTS idx_news_moderation_status ON news(moderation_status, publication_date);
+	`)
+	return err
+}
+
+// isEditor проверяет роль пользователя. Шлюз тоже проверяет роль,
+// но сервис не полагается только на него
+func isEditor(r *http.Request) bool {
+	role := r.Header.Get("X-User-Role")
+	return role == roleModerator || role == roleAdmin
+}
+
+// handleNewsModerationQueue - GET /api/moderation/news: новости, скрытые
+// от читателей. По умолчанию - ждущие решения редактора (flagged) и скрытые
+// сервисом цензуры; ?status= выбирает один статус. Новые первыми
+func handleNewsModerationQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	if !isEditor(r) {
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+		return
+	}
+
+	var (
+		cond string
+		args []any
+	)
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+		cond = "(n.moderation_status = 'flagged' OR (n.moderation_status = 'hidden' AND n.moderated_by IS NULL))"
+	case newsStatusVisible, newsStatusPending, newsStatusFlagged, newsStatusHidden:
+		args = append(args, status)
+		cond = "n.moderation_status = $1"
+	default:
+		http.Error(w, "Неизвестный статус", http.StatusBadRequest)
+		return
+	}
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if err != nil || pageSize < 1 || pageSize > maxPageSize {
+		pageSize = defaultPageSize
+	}
+
+	var totalItems int
+	if err := db.QueryRow(r.Context(), `SELECT COUNT(*) FROM news n WHERE `+cond, args...).Scan(&totalItems); err != nil {
+		middleware.LoggerFromContext(r.Context(), logger).WithError(err).Error("Ошибка получения очереди модерации новостей")
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	args = append(args, pageSize, (page-1)*pageSize)
+	rows, err := db.Query(r.Context(), moderatedNewsSelectSQL+`
+		WHERE `+cond+`
+		ORDER BY n.publication_date DESC
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context(), logger).WithError(err).Error("Ошибка получения очереди модерации новостей")
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	list := ModeratedNewsList{Items: []ModeratedNews{}}
+	for rows.Next() {
+		var n ModeratedNews
+		if err := rows.Scan(n.scanFields()...); err != nil {
+			middleware.LoggerFromContext(r.Context(), logger).WithError(err).Error("Ошибка получения очереди модерации новостей")
+			http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+		list.Items = append(list.Items, n)
+	}
+	if err := rows.Err(); err != nil {
+		middleware.LoggerFromContext(r.Context(), logger).WithError(err).Error("Ошибка получения очереди модерации новостей")
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+	list.Pagination = models.PaginationResponse{
+		TotalItems:   totalItems,
+		TotalPages:   (totalItems + pageSize - 1) / pageSize,
+		CurrentPage:  page,
+		ItemsPerPage: pageSize,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// handleNewsModerationAction - POST /api/moderation/news/{id}/show и
+// /api/moderation/news/{id}/hide (с причиной): решение редактора
+func handleNewsModerationAction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/moderation/news/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	action, ok := newsModerationActions[parts[1]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	if !isEditor(r) {
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+		return
+	}
+	editorID, err := strconv.Atoi(r.Header.Get("X-User-ID"))
+	if err != nil {
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+		return
+	}
+	newsID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Неверный ID новости", http.StatusBadRequest)
+		return
+	}
+
+	var req NewsModerationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+			return
+		}
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if action.reason && req.Reason == "" {
+		http.Error(w, "Требуется причина", http.StatusBadRequest)
+		return
+	}
+
+	news, err := moderateNews(r.Context(), newsID, editorID, action.status, req.Reason)
+	if errors.Is(err, errNewsNotFound) {
+		http.Error(w, "Новость не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		middleware.LoggerFromContext(r.Context(), logger).WithError(err).Error("Ошибка модерации новости")
+		http.Error(w, "Ошибка модерации новости", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(news)
+}
+
+// moderatedNewsSelectSQL - выборка новости с полями модерации в порядке scanFields
+const moderatedNewsSelectSQL = `
+	SELECT n.id, n.title, n.description, n.publication_date, n.source_link, s.name,
+		n.moderation_status, n.moderation_reason, n.moderation_score, n.moderated_by, n.moderated_at
+	FROM news n
+	JOIN rss_feeds rf ON n.rss_feed_id = rf.id
+	JOIN sources s ON rf.source_id = s.id`
+
+func (n *ModeratedNews) scanFields() []any {
+	return []any{&n.ID, &n.Title, &n.Description, &n.PublicationDate, &n.SourceLink, &n.SourceName,
+		&n.Status, &n.Reason, &n.Score, &n.ModeratedBy, &n.ModeratedAt}
+}
+
+// moderateNews выставляет новости статус от имени редактора
+func moderateNews(ctx context.Context, newsID, editorID int, status, reason string) (*ModeratedNews, error) {
+	tag, err := db.Exec(ctx, `
+		UPDATE news
+		SET moderation_status = $2, moderation_reason = NULLIF($3, ''), moderated_by = $4, moderated_at = NOW()
+		WHERE id = $1
+	`, newsID, status, reason, editorID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, errNewsNotFound
+	}
+
+	var n ModeratedNews
+	err = db.QueryRow(ctx, moderatedNewsSelectSQL+` WHERE n.id = $1`, newsID).Scan(n.scanFields()...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errNewsNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"users_service/middleware"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// apiKeyPrefix отличает API-ключи от других токенов
+const apiKeyPrefix = "na_"
+
+// API-ключ партнерского приложения. Сам ключ возвращается только при выпуске
+type APIKey struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	RateLimit *float64   `json:"rate_limit,omitempty"`
+	Burst     *int       `json:"burst,omitempty"`
+	OwnerID   *int       `json:"owner_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Key       string     `json:"key,omitempty"`
+}
+
+// Тело запроса выпуска ключа. Пустые лимиты - значения шлюза по умолчанию
+type APIKeyRequest struct {
+	Name      string   `json:"name"`
+	RateLimit *float64 `json:"rate_limit,omitempty"`
+	Burst     *int     `json:"burst,omitempty"`
+}
+
+// Тело запроса проверки ключа шлюзом
+type VerifyKeyRequest struct {
+	Key string `json:"key"`
+}
+
+// handleAPIKeys - GET /api/keys (список) и POST /api/keys (выпуск), только администратор
+func handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("X-User-Role") != roleAdmin {
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		keys, err := listAPIKeys(r.Context())
+		if err != nil {
+			middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка получения ключей")
+			http.Error(w, "Ошибка получения ключей", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(keys)
+		return
+	}
+
+	var req APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		http.Error(w, "Название ключа должно содержать от 1 до 100 символов", http.StatusBadRequest)
+		return
+	}
+	if (req.RateLimit != nil && *req.RateLimit <= 0) || (req.Burst != nil && *req.Burst <= 0) {
+		http.Error(w, "Лимиты должны быть положительными", http.StatusBadRequest)
+		return
+	}
+
+	var ownerID *int
+	if id, err := strconv.Atoi(r.Header.Get("X-User-ID")); err == nil {
+		ownerID = &id
+	}
+	key, err := createAPIKey(r.Context(), req, ownerID)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка выпуска ключа")
+		http.Error(w, "Ошибка выпуска ключа", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// handleRevokeAPIKey отзывает ключ: DELETE /api/keys/{id} (только администратор)
+func handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 4 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("X-User-Role") != roleAdmin {
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+		return
+	}
+
+	keyID, err := strconv.Atoi(parts[3])
+	if err != nil {
+		http.Error(w, "Неверный ID ключа", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := db.Exec(r.Context(), `
+		UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND revoked_at IS NULL
+	`, keyID)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка отзыва ключа")
+		http.Error(w, "Ошибка отзыва ключа", http.StatusInternalServerError)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		http.Error(w, "Ключ не найден", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleVerifyAPIKey - POST /internal/api-keys/verify, вызывается шлюзом.
+// Путь не публикуется через таблицу маршрутов шлюза
+func handleVerifyAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req VerifyKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Key == "" {
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+		return
+	}
+
+	key, err := getActiveAPIKey(r.Context(), req.Key)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Ключ не найден", http.StatusNotFound)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка проверки ключа")
+		http.Error(w, "Ошибка проверки ключа", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
+
+func createAPIKey(ctx context.Context, req APIKeyRequest, ownerID *int) (*APIKey, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	key := APIKey{Key: secret}
+	err := db.QueryRow(ctx, `
+		INSERT INTO api_keys (name, key_prefix, key_hash, rate_limit, burst, owner_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, name, key_prefix, rate_limit, burst, owner_id, created_at
+	`, req.Name, secret[:len(apiKeyPrefix)+6], hashToken(secret), req.RateLimit, req.Burst, ownerID).Scan(
+		&key.ID, &key.Name, &key.Prefix, &key.RateLimit, &key.Burst, &key.OwnerID, &key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func listAPIKeys(ctx context.Context) ([]APIKey, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, name, key_prefix, rate_limit, burst, owner_id, created_at, revoked_at
+		FROM api_keys
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var k APIKey
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &k.RateLimit, &k.Burst, &k.OwnerID, &k.CreatedAt, &k.RevokedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func getActiveAPIKey(ctx context.Context, secret string) (*APIKey, error) {
+	var k APIKey
+	err := db.QueryRow(ctx, `
+		SELECT id, name, key_prefix, rate_limit, burst, owner_id, created_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL
+	`, hashToken(secret)).Scan(&k.ID, &k.Name, &k.Prefix, &k.RateLimit, &k.Burst, &k.OwnerID, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
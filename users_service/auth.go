@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// tokenIssuerName - значение iss в access-токенах, шлюз проверяет его
+const tokenIssuerName = "news_aggregator"
+
+var errInvalidRefreshToken = errors.New("недействительный refresh-токен")
+
+// dummyHash используется при входе несуществующего пользователя,
+// чтобы проверка пароля занимала столько же времени
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// Claims - содержимое access-токена. Шлюз разбирает ту же структуру
+type Claims struct {
+	Username string `json:"name"`
+	Role     string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// tokenIssuer выпускает access-токены (JWT HS256) и refresh-токены
+type tokenIssuer struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func (t *tokenIssuer) accessToken(user User, now time.Time) (string, error) {
+	claims := Claims{
+		Username: user.Username,
+		Role:     user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuerName,
+			Subject:   strconv.Itoa(user.ID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(t.accessTTL)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.secret)
+}
+
+// execer - общий интерфейс пула соединений и транзакции
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// issueTokens выпускает пару токенов и сохраняет хэш refresh-токена
+func issueTokens(ctx context.Context, q execer, user User) (*TokenResponse, error) {
+	now := time.Now()
+	access, err := tokens.accessToken(user, now)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	_, err = q.Exec(ctx, `
+		INSERT INTO refresh_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, user.ID, hashToken(refresh), now.Add(tokens.refreshTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(tokens.accessTTL.Seconds()),
+		User:         user,
+	}, nil
+}
+
+// rotateRefreshToken отзывает предъявленный refresh-токен и выпускает новую пару
+func rotateRefreshToken(ctx context.Context, token string) (*TokenResponse, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		tokenID   int
+		revokedAt *time.Time
+		expiresAt time.Time
+		user      User
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT t.id, t.revoked_at, t.expires_at, u.id, u.username, u.role, u.created_at
+		FROM refresh_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1
+		FOR UPDATE OF t
+	`, hashToken(token)).Scan(&tokenID, &revokedAt, &expiresAt, &user.ID, &user.Username, &user.Role, &user.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if revokedAt != nil {
+		// Повторное предъявление отозванного токена - признак утечки:
+		// отзываем все активные токены пользователя
+		_, err = tx.Exec(ctx, `
+			UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND revoked_at IS NULL
+		`, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+		return nil, errInvalidRefreshToken
+	}
+	if time.Now().After(expiresAt) {
+		return nil, errInvalidRefreshToken
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1
+	`, tokenID); err != nil {
+		return nil, err
+	}
+
+	resp, err := issueTokens(ctx, tx, user)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword сравнивает пароль с bcrypt-хэшем. Пустой хэш (пользователь
+// не найден) сравнивается с фиктивным и всегда дает false
+func checkPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken - в базе хранятся только хэши refresh-токенов
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
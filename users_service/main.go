@@ -0,0 +1,494 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"users_service/middleware"
+	"users_service/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// Роли пользователей
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// Пользователь
+type User struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Тело запроса регистрации и входа
+type CredentialsRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Тело запроса обновления и отзыва refresh-токена
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Тело запроса смены роли
+type RoleRequest struct {
+	Role string `json:"role"`
+}
+
+// Ответ с парой токенов
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	User         User   `json:"user"`
+}
+
+var (
+	db     *pgxpool.Pool
+	tokens *tokenIssuer
+
+	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]{3,32}$`)
+)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72 // ограничение bcrypt
+)
+
+func main() {
+	// Настройка логгера
+	logrus.SetFormatter(&logrus.JSONFormatter{})
+	logrus.SetOutput(os.Stdout)
+	logrus.SetLevel(logrus.InfoLevel)
+
+	// Настройка трассировки
+	if _, err := tracing.Init(context.Background(), "users_service"); err != nil {
+		log.Fatalf("Ошибка настройки трассировки: %v", err)
+	}
+
+	// Настройка выпуска токенов
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		log.Fatalf("Не задан JWT_SECRET")
+	}
+	tokens = &tokenIssuer{
+		secret:     []byte(secret),
+		accessTTL:  envDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		refreshTTL: envDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+	}
+
+	// Инициализация подключения к базе данных
+	dbHost := os.Getenv("DB_HOST")
+	dbPort := os.Getenv("DB_PORT")
+	dbUser := os.Getenv("DB_USER")
+	dbPassword := os.Getenv("DB_PASSWORD")
+	dbName := os.Getenv("DB_NAME")
+
+	if dbHost == "" {
+		dbHost = "localhost"
+	}
+	if dbPort == "" {
+		dbPort = "5432"
+	}
+	if dbUser == "" {
+		dbUser = "postgres"
+	}
+	if dbPassword == "" {
+		dbPassword = "postgres"
+	}
+	if dbName == "" {
+		dbName = "users_db"
+	}
+
+	connString := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		dbUser, dbPassword, dbHost, dbPort, dbName)
+
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		log.Fatalf("Неверные параметры подключения к базе данных: %v", err)
+	}
+	poolConfig.ConnConfig.Tracer = tracing.QueryTracer{}
+
+	db, err = pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		log.Fatalf("Не удалось подключиться к базе данных: %v", err)
+	}
+	defer db.Close()
+
+	// Создание таблиц, если они не существуют
+	if err := createTables(); err != nil {
+		log.Fatalf("Ошибка создания таблиц: %v", err)
+	}
+
+	// Создание администратора из переменных окружения
+	if err := ensureAdmin(os.Getenv("ADMIN_USERNAME"), os.Getenv("ADMIN_PASSWORD")); err != nil {
+		log.Fatalf("Ошибка создания администратора: %v", err)
+	}
+
+	// Настройка HTTP-обработчиков с middleware
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", handleHealth)
+	mux.HandleFunc("/api/auth/register", handleRegister)
+	mux.HandleFunc("/api/auth/login", handleLogin)
+	mux.HandleFunc("/api/auth/refresh", handleRefresh)
+	mux.HandleFunc("/api/auth/logout", handleLogout)
+	mux.HandleFunc("/api/users/me", handleMe)
+	mux.HandleFunc("/api/users/", handleUserRole)
+	mux.HandleFunc("/api/keys", handleAPIKeys)
+	mux.HandleFunc("/api/keys/", handleRevokeAPIKey)
+	mux.HandleFunc("/internal/api-keys/verify", handleVerifyAPIKey)
+
+	// Применение middleware
+	handler := middleware.LoggingMiddleware(mux)
+	handler = middleware.RequestIDMiddleware(handler)
+	handler = tracing.Middleware(handler, "users_service", tracing.MuxRoute(mux))
+
+	log.Printf("Запуск сервиса пользователей на порту :8084")
+	if err := http.ListenAndServe(":8084", handler); err != nil {
+		log.Fatalf("Ошибка запуска сервера: %v", err)
+	}
+}
+
+func createTables() error {
+	_, err := db.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS users (
+			id SERIAL PRIMARY KEY,
+			username TEXT NOT NULL,
+			password_hash TEXT NOT NULL,
+			role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin')),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (lower(username));
+
+		CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token_hash TEXT NOT NULL UNIQUE,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			revoked_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id SERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			key_prefix TEXT NOT NULL,
+			key_hash TEXT NOT NULL UNIQUE,
+			rate_limit DOUBLE PRECISION,
+			burst INTEGER,
+			owner_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			revoked_at TIMESTAMP WITH TIME ZONE
+		);
+	`)
+	return err
+}
+
+// ensureAdmin создает администратора, если заданы имя и пароль и такого пользователя еще нет
+func ensureAdmin(username, password string) error {
+	if username == "" || password == "" {
+		return nil
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(context.Background(), `
+		INSERT INTO users (username, password_hash, role)
+		VALUES ($1, $2, 'admin')
+		ON CONFLICT ((lower(username))) DO NOTHING
+	`, username, hash)
+	return err
+}
+
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write([]byte(`{"status": "healthy"}`))
+	}
+}
+
+func handleRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CredentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+		return
+	}
+	if !usernamePattern.MatchString(req.Username) {
+		http.Error(w, "Имя пользователя должно содержать от 3 до 32 латинских букв, цифр или _", http.StatusBadRequest)
+		return
+	}
+	if len(req.Password) < minPasswordLength || len(req.Password) > maxPasswordLength {
+		http.Error(w, fmt.Sprintf("Пароль должен содержать от %d до %d символов", minPasswordLength, maxPasswordLength), http.StatusBadRequest)
+		return
+	}
+
+	user, err := createUser(r.Context(), req.Username, req.Password)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			http.Error(w, "Имя пользователя уже занято", http.StatusConflict)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка создания пользователя")
+		http.Error(w, "Ошибка создания пользователя", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
+
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CredentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+		return
+	}
+
+	user, hash, err := getUserByUsername(r.Context(), req.Username)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка получения пользователя")
+		http.Error(w, "Ошибка входа", http.StatusInternalServerError)
+		return
+	}
+	// Пароль проверяется и для несуществующего пользователя,
+	// чтобы время ответа не выдавало наличие учетной записи
+	if !checkPassword(hash, req.Password) || user == nil {
+		http.Error(w, "Неверное имя пользователя или пароль", http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := issueTokens(r.Context(), db, *user)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка выпуска токенов")
+		http.Error(w, "Ошибка входа", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := rotateRefreshToken(r.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, errInvalidRefreshToken) {
+			http.Error(w, "Недействительный refresh-токен", http.StatusUnauthorized)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка обновления токенов")
+		http.Error(w, "Ошибка обновления токенов", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+		return
+	}
+
+	_, err := db.Exec(r.Context(), `
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND revoked_at IS NULL
+	`, hashToken(req.RefreshToken))
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка отзыва токена")
+		http.Error(w, "Ошибка выхода", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMe возвращает текущего пользователя. ID передает шлюз в заголовке X-User-ID
+func handleMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.Header.Get("X-User-ID"))
+	if err != nil {
+		http.Error(w, "Требуется аутентификация", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := getUserByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Пользователь не найден", http.StatusNotFound)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка получения пользователя")
+		http.Error(w, "Ошибка получения пользователя", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// handleUserRole меняет роль пользователя: PUT /api/users/{id}/role (только администратор)
+func handleUserRole(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 5 || parts[4] != "role" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPut {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("X-User-Role") != roleAdmin {
+		http.Error(w, "Недостаточно прав", http.StatusForbidden)
+		return
+	}
+
+	userID, err := strconv.Atoi(parts[3])
+	if err != nil {
+		http.Error(w, "Неверный ID пользователя", http.StatusBadRequest)
+		return
+	}
+
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверное тело запроса", http.StatusBadRequest)
+		return
+	}
+	if req.Role != roleUser && req.Role != roleModerator && req.Role != roleAdmin {
+		http.Error(w, "Неизвестная роль", http.StatusBadRequest)
+		return
+	}
+
+	var user User
+	err = db.QueryRow(r.Context(), `
+		UPDATE users SET role = $2 WHERE id = $1
+		RETURNING id, username, role, created_at
+	`, userID, req.Role).Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Пользователь не найден", http.StatusNotFound)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Ошибка смены роли")
+		http.Error(w, "Ошибка смены роли", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func createUser(ctx context.Context, username, password string) (*User, error) {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+	err = db.QueryRow(ctx, `
+		INSERT INTO users (username, password_hash)
+		VALUES ($1, $2)
+		RETURNING id, username, role, created_at
+	`, username, hash).Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// getUserByUsername возвращает пользователя и хэш его пароля
+func getUserByUsername(ctx context.Context, username string) (*User, string, error) {
+	var user User
+	var hash string
+	err := db.QueryRow(ctx, `
+		SELECT id, username, role, created_at, password_hash
+		FROM users
+		WHERE lower(username) = lower($1)
+	`, username).Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt, &hash)
+	if err != nil {
+		return nil, "", err
+	}
+	return &user, hash, nil
+}
+
+func getUserByID(ctx context.Context, id int) (*User, error) {
+	var user User
+	err := db.QueryRow(ctx, `
+		SELECT id, username, role, created_at
+		FROM users
+		WHERE id = $1
+	`, id).Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+		logrus.Warnf("Неверное значение %s=%q, используется %s", key, v, def)
+	}
+	return def
+}
@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Init настраивает глобальный провайдер трассировки и распространение
+// контекста в формате W3C traceparent. Экспортер выбирается переменной
+// OTEL_TRACES_EXPORTER: "otlp" (адрес коллектора - OTEL_EXPORTER_OTLP_ENDPOINT),
+// "stdout" или "none" (по умолчанию). Возвращает функцию остановки провайдера
+func Init(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")) {
+	case "", "none":
+		// Спаны не записываются, но входящий traceparent передается дальше
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout", "console":
+		exporter, err = stdouttrace.New()
+	default:
+		return nil, fmt.Errorf("неизвестный экспортер трассировки %q", os.Getenv("OTEL_TRACES_EXPORTER"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания экспортера трассировки: %v", err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания ресурса трассировки: %v", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// RouteFunc возвращает шаблон маршрута запроса (например, "/api/news/")
+// или пустую строку, если маршрут не найден
+type RouteFunc func(r *http.Request) string
+
+// MuxRoute возвращает RouteFunc по шаблонам маршрутизатора mux
+func MuxRoute(mux *http.ServeMux) RouteFunc {
+	return func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+}
+
+// Middleware создает серверный спан для каждого HTTP-запроса. Спан называется
+// по шаблону маршрута, а не по пути: в путях есть ID, и имена спанов
+// не должны зависеть от них. route может быть nil - тогда в имени только метод
+func Middleware(next http.Handler, serviceName string, route RouteFunc) http.Handler {
+	return otelhttp.NewHandler(next, serviceName,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if route != nil {
+				if pattern := route(r); pattern != "" {
+					return r.Method + " " + pattern
+				}
+			}
+			return r.Method
+		}),
+	)
+}
+
+// Transport оборачивает транспорт клиентскими спанами и передачей traceparent.
+// Спан называется по методу и сервису назначения
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Host
+		}),
+	)
+}